@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/alerts"
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// alertSnapshot prices the assets the rules watch and, once every holding
+// is priced, adds the total and each asset's weight by registry key.
+func alertSnapshot(client *api.Client, assets []string) alerts.Snapshot {
+	s := alerts.Snapshot{
+		Time:   time.Now(),
+		Prices: make(map[string]decimal.Decimal, len(assets)),
+	}
+	for _, asset := range assets {
+		if price, ok := client.PriceOf(asset); ok {
+			s.Prices[asset] = price
+		}
+	}
+
+	if weights, total, ok := client.Weights(); ok {
+		s.Total = total
+		s.Weights = weights
+	}
+	return s
+}
+
+// ringBell sounds the terminal bell; the alert itself is shown by the
+// display.
+func ringBell(context.Context, alerts.Alert) error {
+	_, err := fmt.Fprint(os.Stdout, "\a")
+	return err
+}
+
+// notifiers always ring the bell and, when configured, post to the webhook
+// and send mail.
+func notifiers(cfg *config.Config) []alerts.Notifier {
+	out := []alerts.Notifier{alerts.NotifierFunc(ringBell)}
+	if cfg.WebhookURL != "" {
+		out = append(out, alerts.NewWebhook(cfg.WebhookURL, cfg.WebhookSecret))
+	}
+	if cfg.SMTPAddr != "" {
+		out = append(out, &alerts.SMTP{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPTo,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+		})
+	}
+	return out
+}
+
+// emitStateChange reports losing the connection and getting it back. Going
+// stale is left to the reconnect that usually follows.
+func emitStateChange(engine *alerts.Engine, from, to models.ConnectionState) {
+	switch {
+	case to == models.StateReconnecting && from != models.StateReconnecting:
+		engine.Emit(alerts.EventDisconnected, "Lost the connection to Kraken, reconnecting", time.Now())
+	case to == models.StateConnected && from == models.StateReconnecting:
+		engine.Emit(alerts.EventReconnected, "Reconnected to Kraken", time.Now())
+	}
+}
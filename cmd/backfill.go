@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/backfill"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+)
+
+// ohlcPause spaces out the OHLC requests, which count against the public
+// rate limit.
+const ohlcPause = time.Second
+
+type backfillFlags struct {
+	envFile string
+	dryRun  bool
+}
+
+func parseBackfillFlags(args []string) *backfillFlags {
+	f := &backfillFlags{}
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "Print the daily snapshots as CSV instead of storing them")
+	fs.Parse(args)
+	return f
+}
+
+// runBackfill rebuilds daily snapshots from before the tracker was first run,
+// replaying the ledger into balances and valuing them at daily closes.
+func runBackfill(ctx context.Context, args []string) error {
+	f := parseBackfillFlags(args)
+
+	client, entries, _, err := loadLedger(ctx, f.envFile)
+	if err != nil {
+		return err
+	}
+	cfg := client.Config
+	if cfg.SnapshotDir == "" && !f.dryRun {
+		return errNoSnapshotDir
+	}
+	if len(entries) == 0 {
+		fmt.Println("The ledger is empty, there is nothing to backfill.")
+		return nil
+	}
+
+	opts := backfill.Options{
+		Registry: client.Registry(),
+		Quote:    client.QuoteAsset(),
+		End:      time.Now(),
+	}
+	since := ledgerDay(entries[0].Time).Add(-24 * time.Hour)
+	closes, err := fetchCloses(ctx, client, backfill.Pairs(entries, opts), since)
+	if err != nil {
+		return err
+	}
+
+	points, warnings := backfill.Build(entries, closes, opts)
+	if f.dryRun {
+		err = snapshot.WriteCSV(os.Stdout, points)
+	} else {
+		err = storeBackfill(cfg.SnapshotDir, points)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(warnings) > 0 {
+		fmt.Fprintln(os.Stderr, "\nWarnings:")
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "  - %s\n", w)
+		}
+	}
+	if since.Before(time.Now().AddDate(0, 0, -api.OHLCLimit)) {
+		fmt.Fprintf(os.Stderr, "\nKraken only serves the last %d daily closes, earlier days could not be priced.\n", api.OHLCLimit)
+	}
+	return nil
+}
+
+// fetchCloses downloads the daily candles of each pair from since on.
+func fetchCloses(ctx context.Context, client *api.Client, pairs []models.AssetPair, since time.Time) (backfill.Closes, error) {
+	closes := make(backfill.Closes, len(pairs))
+	for i, pair := range pairs {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(ohlcPause):
+			}
+		}
+		candles, err := client.FetchOHLC(ctx, pair.Altname, 24*time.Hour, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch daily closes for %s: %w", pair.WsName, err)
+		}
+		closes.Add(pair.WsName, candles)
+	}
+	return closes, nil
+}
+
+func storeBackfill(dir string, points []snapshot.Snapshot) error {
+	store, err := snapshot.Open(dir, snapshot.DefaultOptions())
+	if err != nil {
+		return fmt.Errorf("failed to open snapshots: %w", err)
+	}
+	defer store.Close()
+
+	written, err := store.Backfill(points)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d of %d daily snapshots", written, len(points))
+	if skipped := len(points) - written; skipped > 0 {
+		fmt.Printf(", the other %d overlap recorded history and were left alone", skipped)
+	}
+	fmt.Println(".")
+	return nil
+}
+
+func ledgerDay(seconds float64) time.Time {
+	return time.Unix(int64(seconds), 0).UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/accounting"
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/backfill"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+type gainsFlags struct {
+	envFile       string
+	method        string
+	year          int
+	csvPath       string
+	allowUnpriced bool
+}
+
+func parseGainsFlags(args []string) *gainsFlags {
+	f := &gainsFlags{}
+	fs := flag.NewFlagSet("gains", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.StringVar(&f.method, "method", "fifo", "Lot matching method: fifo, lifo, hifo, average or all")
+	fs.IntVar(&f.year, "year", 0, "Tax year to report (default all years)")
+	fs.StringVar(&f.csvPath, "csv", "", "Write disposals as CSV to this file, or - for stdout")
+	fs.BoolVar(&f.allowUnpriced, "allow-unpriced", false, "Book deposits, rewards and swaps without a daily close at a zero or carried-over cost basis")
+	fs.Parse(args)
+	return f
+}
+
+// runGains computes realized gains from the Kraken ledger and prints a
+// summary per method and year.
+func runGains(ctx context.Context, args []string) error {
+	f := parseGainsFlags(args)
+
+	methods := accounting.Methods
+	if f.method != "all" {
+		method, err := accounting.ParseMethod(f.method)
+		if err != nil {
+			return err
+		}
+		methods = []accounting.Method{method}
+	}
+
+	client, entries, opts, err := loadLedger(ctx, f.envFile)
+	if err != nil {
+		return err
+	}
+	if opts.Prices, err = dailyCloses(ctx, client, entries); err != nil {
+		return err
+	}
+
+	reports := make([]*accounting.Report, 0, len(methods))
+	for _, method := range methods {
+		report, err := accounting.Realize(entries, method, opts)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	for _, report := range reports {
+		if err := checkPriced(client.Registry(), report, f.year, f.allowUnpriced); err != nil {
+			return err
+		}
+	}
+
+	// Keep stdout clean for the CSV when it is written there.
+	var summary io.Writer = os.Stdout
+	if f.csvPath == "-" {
+		summary = os.Stderr
+	}
+	fmt.Fprintf(summary, "Realized gains in %s\n\n", client.QuoteCurrency())
+	if err := accounting.WriteSummary(summary, reports, f.year); err != nil {
+		return err
+	}
+
+	return writeCSV(f.csvPath, func(w io.Writer) error {
+		return accounting.WriteCSV(w, reports, f.year)
+	})
+}
+
+// loadLedger connects to the REST API and fetches the full ledger, with
+// accounting options for the configured quote currency.
+func loadLedger(ctx context.Context, envFile string) (*api.Client, []models.LedgerEntry, accounting.Options, error) {
+	var opts accounting.Options
+
+	cfg, err := config.LoadConfig(envFile)
+	if err != nil {
+		return nil, nil, opts, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, opts, err
+	}
+
+	client := api.NewClient(cfg)
+	if err := client.LoadRegistry(ctx); err != nil {
+		return nil, nil, opts, fmt.Errorf("failed to load asset registry: %w", err)
+	}
+	entries, err := client.FetchLedgers(ctx)
+	if err != nil {
+		return nil, nil, opts, fmt.Errorf("failed to fetch ledger: %w", err)
+	}
+
+	registry := client.Registry()
+	quote := client.QuoteAsset()
+	opts = accounting.Options{
+		Quote:     quote,
+		Normalize: func(asset string) string { return registry.ResolveFor(asset, quote) },
+	}
+	return client, entries, opts, nil
+}
+
+// dailyCloses fetches the daily closes that value the ledger's deposits,
+// staking rewards and crypto-to-crypto swaps in the quote currency. Days
+// older than Kraken serves fall back to the stored daily snapshots, when
+// there are any.
+func dailyCloses(ctx context.Context, client *api.Client, entries []models.LedgerEntry) (accounting.PriceSource, error) {
+	opts := backfill.Options{Registry: client.Registry(), Quote: client.QuoteAsset()}
+	if len(entries) == 0 {
+		return backfill.Prices{Closes: backfill.Closes{}, Opts: opts}, nil
+	}
+
+	since := ledgerDay(entries[0].Time).Add(-24 * time.Hour)
+	closes, err := fetchCloses(ctx, client, backfill.Pairs(entries, opts), since)
+	if err != nil {
+		return nil, err
+	}
+	prices := firstPrice{backfill.Prices{Closes: closes, Opts: opts}}
+
+	if dir := client.Config.SnapshotDir; dir != "" {
+		days, err := snapshotDays(dir, since, client.Registry().DisplayName)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, days)
+	}
+	return prices, nil
+}
+
+// firstPrice asks each source in turn.
+type firstPrice []accounting.PriceSource
+
+func (p firstPrice) PriceAt(asset string, at time.Time) (decimal.Decimal, bool) {
+	for _, source := range p {
+		if price, ok := source.PriceAt(asset, at); ok {
+			return price, true
+		}
+	}
+	return decimal.Zero, false
+}
+
+// dayPrices values an asset at the stored daily snapshot of the same UTC
+// day, and only that day: a price from another day is no better than none.
+type dayPrices struct {
+	days map[time.Time]snapshot.Prices
+	name func(string) string
+}
+
+func snapshotDays(dir string, since time.Time, name func(string) string) (dayPrices, error) {
+	store, err := snapshot.Open(dir, snapshot.DefaultOptions())
+	if err != nil {
+		return dayPrices{}, fmt.Errorf("failed to open snapshots: %w", err)
+	}
+	defer store.Close()
+
+	points, err := store.Query(snapshot.Day, since, time.Time{})
+	if err != nil {
+		return dayPrices{}, err
+	}
+	days := dayPrices{days: make(map[time.Time]snapshot.Prices, len(points)), name: name}
+	for _, point := range points {
+		day := point.Time.UTC().Truncate(24 * time.Hour)
+		days.days[day] = snapshot.Prices{point}
+	}
+	return days, nil
+}
+
+func (p dayPrices) PriceAt(asset string, at time.Time) (decimal.Decimal, bool) {
+	day, ok := p.days[at.UTC().Truncate(24*time.Hour)]
+	if !ok {
+		return decimal.Zero, false
+	}
+	return day.PriceAt(p.name(asset), at)
+}
+
+// checkPriced refuses a report whose figures for year, 0 for every year,
+// rest on entries booked without a price, which would understate income
+// and misstate gains, unless allow is set.
+func checkPriced(registry *api.Registry, report *accounting.Report, year int, allow bool) error {
+	unpriced := report.UnpricedIn(year)
+	if allow || len(unpriced) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(unpriced))
+	for i, asset := range unpriced {
+		names[i] = registry.DisplayName(asset)
+	}
+	return fmt.Errorf("no daily close or snapshot for %s on some ledger dates (Kraken serves the last %d days); "+
+		"pass -allow-unpriced to book those entries at a zero or carried-over cost basis",
+		strings.Join(names, ", "), api.OHLCLimit)
+}
+
+// writeCSV writes to path, to stdout for "-", or nowhere for "".
+func writeCSV(path string, write func(io.Writer) error) error {
+	switch path {
+	case "":
+		return nil
+	case "-":
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
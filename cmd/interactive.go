@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/ui"
+)
+
+const (
+	// bookRefresh is how often the open detail pane's order book is
+	// fetched again, and bookDepth how many levels of each side.
+	bookRefresh = 2 * time.Second
+	bookDepth   = 5
+)
+
+// handleKeys applies key presses to the display until one asks to quit,
+// then cancels the tracker. A key that opens the detail pane on another
+// pair asks for its order book at once.
+func handleKeys(ctx context.Context, quit context.CancelFunc, terminal *ui.Terminal, display *ui.Display, books chan<- struct{}) {
+	for key := range terminal.Keys(ctx) {
+		pair := display.DetailPair()
+		if display.HandleKey(key) {
+			quit()
+			return
+		}
+		if next := display.DetailPair(); next != "" && next != pair {
+			select {
+			case books <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// watchOrderBook keeps the order book of the asset in the detail pane fresh
+// while the pane is open.
+func watchOrderBook(ctx context.Context, client *api.Client, display *ui.Display, books <-chan struct{}, logger *log.Logger) {
+	ticker := time.NewTicker(bookRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-books:
+		}
+
+		pair := display.DetailPair()
+		if pair == "" {
+			continue
+		}
+		book, err := client.FetchOrderBook(ctx, pair, bookDepth)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Printf("Failed to fetch order book for %s: %v\n", pair, err)
+			}
+			continue
+		}
+		display.SetOrderBook(book)
+		display.Refresh()
+	}
+}
+
+// openInteractive switches to the interactive display when stdin and
+// stdout are a terminal. Log lines are written through the terminal so they
+// keep to raw mode's line endings; the client and the alert engine log
+// through the same logger.
+func openInteractive(ctx context.Context, quit context.CancelFunc, client *api.Client, logger *log.Logger) (*ui.Display, *ui.Terminal, bool) {
+	terminal, err := ui.OpenTerminal()
+	if err != nil {
+		return nil, nil, false
+	}
+	logger.SetOutput(terminal)
+
+	display := ui.NewDisplayWithWriter(terminal, terminal.Width())
+	display.SetInteractive(true)
+	books := make(chan struct{}, 1)
+	go handleKeys(ctx, quit, terminal, display, books)
+	go watchOrderBook(ctx, client, display, books, logger)
+	return display, terminal, true
+}
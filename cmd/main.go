@@ -1,27 +1,41 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/umit144/kraken-portfolio/internal/alerts"
 	"github.com/umit144/kraken-portfolio/internal/api"
 	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/performance"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
 	"github.com/umit144/kraken-portfolio/internal/ui"
 )
 
+// recentFills is how many filled orders the display lists.
+const recentFills = 5
+
 type flags struct {
 	envFile string
 	debug   bool
+	static  bool
 }
 
-func parseFlags() *flags {
+func parseFlags(args []string) *flags {
 	f := &flags{}
-	flag.StringVar(&f.envFile, "env", ".env", "Path to env file")
-	flag.BoolVar(&f.debug, "debug", false, "Enable debug logging")
-	flag.Parse()
+	fs := flag.NewFlagSet("kraken-portfolio", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.BoolVar(&f.debug, "debug", false, "Enable debug logging")
+	fs.BoolVar(&f.static, "static", false, "Print a plain table on every update instead of the interactive view")
+	fs.Parse(args)
 	return f
 }
 
@@ -32,21 +46,10 @@ func setupLogger(debug bool) *log.Logger {
 	return log.New(os.Stdout, "", 0)
 }
 
-func setupSignalHandler(client *api.Client, logger *log.Logger) {
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		sig := <-sigCh
-		logger.Printf("\nReceived signal: %v\n", sig)
-		if err := client.Close(); err != nil {
-			logger.Printf("Error closing client: %v\n", err)
-		}
-		os.Exit(0)
-	}()
-}
+func run(ctx context.Context, f *flags, logger *log.Logger) error {
+	ctx, quit := context.WithCancel(ctx)
+	defer quit()
 
-func run(f *flags, logger *log.Logger) error {
 	cfg, err := config.LoadConfig(f.envFile)
 	if err != nil {
 		return err
@@ -56,25 +59,119 @@ func run(f *flags, logger *log.Logger) error {
 		return err
 	}
 
-	client := api.NewClient(cfg)
-	if err := client.Connect(); err != nil {
+	rules, err := alerts.ParseRules(cfg.AlertRules)
+	if err != nil {
+		return fmt.Errorf("invalid KRAKEN_ALERTS: %w", err)
+	}
+	if _, err := performance.ParsePeriod(cfg.PerformancePeriod, time.Now()); err != nil {
+		return fmt.Errorf("invalid KRAKEN_PERFORMANCE_PERIOD: %w", err)
+	}
+
+	client := api.NewClient(cfg, api.WithLogger(logger))
+	engine := alerts.NewEngine(rules, alerts.Options{
+		Hysteresis: cfg.AlertHysteresis,
+		Cooldown:   cfg.AlertCooldown,
+		Normalize:  client.ResolveAsset,
+		Logger:     logger,
+	}, notifiers(cfg)...)
+	client.Watch(engine.Assets()...)
+	client.OnError = func(err error) {
+		engine.Emit(alerts.EventAPIError, err.Error(), time.Now())
+	}
+	go engine.Run(ctx)
+	if err := client.Connect(ctx); err != nil {
 		return err
 	}
 	defer client.Close()
 
+	if err := client.LoadCostBasis(ctx); err != nil {
+		logger.Printf("Cost basis unavailable: %v\n", err)
+	}
+	if err := client.LoadOrders(ctx); err != nil {
+		logger.Printf("Open orders unavailable: %v\n", err)
+	}
+	tracker := &performanceTracker{}
+	if cfg.SnapshotDir != "" {
+		store, err := snapshot.Open(cfg.SnapshotDir, snapshot.DefaultOptions())
+		if err != nil {
+			logger.Printf("Snapshots unavailable: %v\n", err)
+		} else {
+			defer store.Close()
+			go recordSnapshots(ctx, client, store, cfg.SnapshotInterval, logger)
+			go tracker.Run(ctx, client, store, logger)
+		}
+	}
+
 	display := ui.NewDisplay()
-	setupSignalHandler(client, logger)
+	if !f.static {
+		if interactive, terminal, ok := openInteractive(ctx, quit, client, logger); ok {
+			defer terminal.Close()
+			display = interactive
+		}
+	}
+	display.SetQuoteCurrency(client.QuoteCurrency(), client.QuoteDecimals())
+	display.SetDriftTolerance(cfg.DriftTolerance)
+	render := func(assets []models.AssetValue) {
+		display.SetOrders(client.OpenOrderViews(), client.RecentFills(recentFills))
+		display.SetPerformance(tracker.Summary())
+		display.RenderPortfolio(assets)
+	}
+	lastState := client.State()
+	display.SetConnectionState(lastState)
+	client.OnStateChange = func(state models.ConnectionState) {
+		logger.Printf("Connection state: %v\n", state)
+		emitStateChange(engine, lastState, state)
+		lastState = state
+		display.SetConnectionState(state)
+		render(client.GetAssetValues())
+	}
+	if len(rules) > 0 {
+		client.OnPriceUpdate = func() {
+			for _, alert := range engine.Evaluate(alertSnapshot(client, engine.Assets())) {
+				display.SetAlert(alert.Time.Format("15:04:05") + " " + alert.Message)
+			}
+		}
+	}
 
 	logger.Println("Connected to Kraken. Press Ctrl+C to exit.")
-	client.StartStreaming(display.RenderPortfolio)
-	return nil
+	err = client.StartStreaming(ctx, render)
+	if errors.Is(err, context.Canceled) {
+		logger.Println("\nShutting down...")
+		return nil
+	}
+	return err
+}
+
+// commands are the subcommands run instead of the streaming display.
+var commands = map[string]func(context.Context, []string) error{
+	"gains":       runGains,
+	"report":      runReport,
+	"rebalance":   runRebalance,
+	"order":       runOrder,
+	"cancel":      runCancel,
+	"snapshots":   runSnapshots,
+	"performance": runPerformance,
+	"backfill":    runBackfill,
 }
 
 func main() {
-	flags := parseFlags()
-	logger := setupLogger(flags.debug)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	if err := run(flags, logger); err != nil {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			logger := setupLogger(false)
+			if err := command(ctx, args[1:]); err != nil {
+				logger.Fatalf("Error: %v\n", err)
+			}
+			return
+		}
+	}
+
+	flags := parseFlags(args)
+	logger := setupLogger(flags.debug)
+	if err := run(ctx, flags, logger); err != nil {
 		logger.Fatalf("Error: %v\n", err)
 	}
 }
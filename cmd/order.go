@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+var errOrderUsage = errors.New("usage: order buy|sell -pair PAIR -volume VOLUME [-price PRICE]")
+
+type orderFlags struct {
+	envFile string
+	pair    string
+	volume  string
+	price   string
+}
+
+func parseOrderFlags(args []string) *orderFlags {
+	f := &orderFlags{}
+	fs := flag.NewFlagSet("order", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.StringVar(&f.pair, "pair", "", "Pair to trade, e.g. XBTUSD")
+	fs.StringVar(&f.volume, "volume", "", "Order volume in the base asset")
+	fs.StringVar(&f.price, "price", "", "Limit price; a market order when empty")
+	fs.Parse(args)
+	return f
+}
+
+// loadTradingClient refuses to go further unless trading is enabled, so no
+// order flow ever reaches Kraken with it off.
+func loadTradingClient(envFile string) (*api.Client, error) {
+	cfg, err := config.LoadConfig(envFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if !cfg.TradingEnabled {
+		return nil, api.ErrTradingDisabled
+	}
+	return api.NewClient(cfg), nil
+}
+
+// runOrder has Kraken validate the order first, shows its description and
+// only places it once the user confirms.
+func runOrder(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "buy" && args[0] != "sell") {
+		return errOrderUsage
+	}
+	side := args[0]
+	f := parseOrderFlags(args[1:])
+	if f.pair == "" || f.volume == "" {
+		return errOrderUsage
+	}
+
+	req := models.OrderRequest{Pair: strings.ToUpper(f.pair), Type: side, OrderType: "market"}
+	var err error
+	if req.Volume, err = decimal.Parse(f.volume); err != nil || req.Volume.Sign() <= 0 {
+		return fmt.Errorf("invalid -volume %q", f.volume)
+	}
+	if f.price != "" {
+		req.OrderType = "limit"
+		if req.Price, err = decimal.Parse(f.price); err != nil || req.Price.Sign() <= 0 {
+			return fmt.Errorf("invalid -price %q", f.price)
+		}
+	}
+
+	client, err := loadTradingClient(f.envFile)
+	if err != nil {
+		return err
+	}
+
+	req.Validate = true
+	result, err := client.AddOrder(ctx, req)
+	if err != nil {
+		return fmt.Errorf("order rejected: %w", err)
+	}
+	fmt.Printf("Kraken validated: %s\n", result.Descr.Order)
+
+	if !confirm(os.Stdin, os.Stdout, "Place this order?") {
+		fmt.Println("Not placed.")
+		return nil
+	}
+
+	req.Validate = false
+	result, err = client.AddOrder(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to place order: %w", err)
+	}
+	fmt.Printf("Placed: %s (%s)\n", result.Descr.Order, strings.Join(result.TxID, ", "))
+	return nil
+}
+
+// runCancel cancels one order by txid, or every open order with -all, after
+// confirmation.
+func runCancel(ctx context.Context, args []string) error {
+	var envFile string
+	var all bool
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	fs.StringVar(&envFile, "env", ".env", "Path to env file")
+	fs.BoolVar(&all, "all", false, "Cancel every open order")
+	fs.Parse(args)
+
+	txid := fs.Arg(0)
+	if all == (txid != "") {
+		return errors.New("usage: cancel TXID | cancel -all")
+	}
+
+	client, err := loadTradingClient(envFile)
+	if err != nil {
+		return err
+	}
+
+	prompt := fmt.Sprintf("Cancel order %s?", txid)
+	if all {
+		prompt = "Cancel ALL open orders?"
+	}
+	if !confirm(os.Stdin, os.Stdout, prompt) {
+		fmt.Println("Nothing cancelled.")
+		return nil
+	}
+
+	var count int
+	if all {
+		count, err = client.CancelAll(ctx)
+	} else {
+		count, err = client.CancelOrder(ctx, txid)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Cancelled %d order(s).\n", count)
+	return nil
+}
+
+// confirm reads one line and accepts only "yes".
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprintf(out, "%s Type 'yes' to confirm: ", prompt)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	return strings.TrimSpace(strings.ToLower(answer)) == "yes"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/performance"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+const (
+	// performanceRefresh is how often the display's returns are recomputed
+	// and ledgerRefresh how often new ledger entries are fetched.
+	performanceRefresh = time.Minute
+	ledgerRefresh      = time.Hour
+)
+
+type performanceFlags struct {
+	envFile  string
+	period   string
+	riskFree string
+}
+
+func parsePerformanceFlags(args []string) *performanceFlags {
+	f := &performanceFlags{}
+	fs := flag.NewFlagSet("performance", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.StringVar(&f.period, "period", "", "Period to measure: 30d, 12w, 1y, ytd or all (defaults to KRAKEN_PERFORMANCE_PERIOD)")
+	fs.StringVar(&f.riskFree, "riskfree", "", "Annual risk-free rate in percent for the Sharpe ratio (defaults to KRAKEN_RISK_FREE_RATE)")
+	fs.Parse(args)
+	return f
+}
+
+// runPerformance measures returns from the stored snapshots, with deposits
+// and withdrawals from the Kraken ledger taken out.
+func runPerformance(ctx context.Context, args []string) error {
+	f := parsePerformanceFlags(args)
+
+	client, entries, opts, err := loadLedger(ctx, f.envFile)
+	if err != nil {
+		return err
+	}
+	cfg := client.Config
+	if cfg.SnapshotDir == "" {
+		return errNoSnapshotDir
+	}
+
+	period := cfg.PerformancePeriod
+	if f.period != "" {
+		period = f.period
+	}
+	from, err := performance.ParsePeriod(period, time.Now())
+	if err != nil {
+		return err
+	}
+	riskFree := cfg.RiskFreeRate
+	if f.riskFree != "" {
+		if riskFree, err = decimal.Parse(f.riskFree); err != nil {
+			return fmt.Errorf("invalid -riskfree %q", f.riskFree)
+		}
+	}
+
+	store, err := snapshot.Open(cfg.SnapshotDir, snapshot.DefaultOptions())
+	if err != nil {
+		return fmt.Errorf("failed to open snapshots: %w", err)
+	}
+	defer store.Close()
+
+	history, err := loadHistory(store, from)
+	if err != nil {
+		return err
+	}
+	flows, warnings := performance.Flows(entries, ledgerPrices(client, history, opts.Normalize))
+	result, err := performance.Analyze(performance.FromSnapshots(history), flows, performance.Options{RiskFree: riskFree})
+	if err != nil {
+		return err
+	}
+	return performance.WriteReport(os.Stdout, result, client.QuoteCurrency(), warnings)
+}
+
+// loadHistory reads the finest series that reaches back to from, ending
+// with the latest minute so the period runs up to now.
+func loadHistory(store *snapshot.Store, from time.Time) ([]snapshot.Snapshot, error) {
+	// Coarse series start with too few points while the store is young,
+	// so fall back to finer ones.
+	resolutions := []snapshot.Resolution{snapshot.Day, snapshot.Hour, snapshot.Minute}
+	if span := time.Since(from); !from.IsZero() && span <= 2*24*time.Hour {
+		resolutions = resolutions[2:]
+	} else if !from.IsZero() && span <= 60*24*time.Hour {
+		resolutions = resolutions[1:]
+	}
+
+	var history []snapshot.Snapshot
+	for _, r := range resolutions {
+		points, err := store.Query(r, from, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		history = points
+		if len(history) >= 2 {
+			break
+		}
+	}
+
+	latest, err := store.Query(snapshot.Minute, time.Now().Add(-time.Hour), time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if n := len(latest); n > 0 && (len(history) == 0 || latest[n-1].Time.After(history[len(history)-1].Time)) {
+		history = append(history, latest[n-1])
+	}
+	return history, nil
+}
+
+// ledgerPrices prices ledger assets from the snapshots, trying the name the
+// asset is shown under and then the asset it is valued as, so staked
+// variants fall back to their base asset.
+func ledgerPrices(client *api.Client, history []snapshot.Snapshot, normalize func(string) string) performance.PriceFunc {
+	registry := client.Registry()
+	prices := snapshot.Prices(history)
+	return func(asset string, at time.Time) (decimal.Decimal, bool) {
+		if price, ok := prices.PriceAt(registry.DisplayName(asset), at); ok {
+			return price, true
+		}
+		return prices.PriceAt(registry.DisplayName(normalize(asset)), at)
+	}
+}
+
+// performanceTracker recomputes the display's returns row in the
+// background; the render loop picks up the latest one.
+type performanceTracker struct {
+	mu      sync.Mutex
+	summary models.PerformanceSummary
+}
+
+func (t *performanceTracker) Summary() models.PerformanceSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.summary
+}
+
+func (t *performanceTracker) Run(ctx context.Context, client *api.Client, store *snapshot.Store, logger *log.Logger) {
+	cfg := client.Config
+	quote := client.QuoteAsset()
+	registry := client.Registry()
+	normalize := func(asset string) string { return registry.ResolveFor(asset, quote) }
+
+	var entries []models.LedgerEntry
+	var fetched time.Time
+	ticker := time.NewTicker(performanceRefresh)
+	defer ticker.Stop()
+
+	for {
+		if time.Since(fetched) >= ledgerRefresh {
+			if fresh, err := client.UpdateLedgers(ctx, entries); err != nil {
+				logger.Printf("Ledger unavailable for performance: %v\n", err)
+			} else {
+				entries = fresh
+			}
+			fetched = time.Now()
+		}
+
+		// Without the ledger, deposits would count as gains.
+		if entries != nil {
+			from, _ := performance.ParsePeriod(cfg.PerformancePeriod, time.Now())
+			if history, err := loadHistory(store, from); err == nil {
+				flows, _ := performance.Flows(entries, ledgerPrices(client, history, normalize))
+				result, err := performance.Analyze(performance.FromSnapshots(history), flows, performance.Options{RiskFree: cfg.RiskFreeRate})
+				if err == nil {
+					t.mu.Lock()
+					t.summary = result.Summary(cfg.PerformancePeriod)
+					t.mu.Unlock()
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/rebalance"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// defaultFeePercent is Kraken's taker fee at the lowest volume tier.
+const defaultFeePercent = "0.26"
+
+var errNoTargets = errors.New("no target allocation configured, set KRAKEN_TARGET_ALLOCATION")
+
+type rebalanceFlags struct {
+	envFile   string
+	fee       string
+	tolerance string
+	validate  bool
+}
+
+func parseRebalanceFlags(args []string) *rebalanceFlags {
+	f := &rebalanceFlags{}
+	fs := flag.NewFlagSet("rebalance", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.StringVar(&f.fee, "fee", defaultFeePercent, "Estimated fee per order, in percent")
+	fs.StringVar(&f.tolerance, "tolerance", "", "Drift in percentage points to leave alone (defaults to KRAKEN_DRIFT_TOLERANCE)")
+	fs.BoolVar(&f.validate, "validate", false, "Have Kraken validate each order without placing it")
+	fs.Parse(args)
+	return f
+}
+
+// runRebalance prints the market orders that would bring the portfolio back
+// to its target weights. It never places them; -validate only asks Kraken to
+// check each one.
+func runRebalance(ctx context.Context, args []string) error {
+	f := parseRebalanceFlags(args)
+
+	cfg, err := config.LoadConfig(f.envFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if len(cfg.TargetWeights) == 0 {
+		return errNoTargets
+	}
+
+	fee, err := decimal.Parse(f.fee)
+	if err != nil || fee.Sign() < 0 {
+		return fmt.Errorf("invalid -fee %q", f.fee)
+	}
+	tolerance := cfg.DriftTolerance
+	if f.tolerance != "" {
+		tolerance, err = decimal.Parse(f.tolerance)
+		if err != nil || tolerance.Sign() < 0 {
+			return fmt.Errorf("invalid -tolerance %q", f.tolerance)
+		}
+	}
+
+	client := api.NewClient(cfg)
+	if err := client.LoadRegistry(ctx); err != nil {
+		return fmt.Errorf("failed to load asset registry: %w", err)
+	}
+	if err := client.CheckTargets(); err != nil {
+		return err
+	}
+	if err := client.GetBalances(ctx); err != nil {
+		return fmt.Errorf("failed to get balances: %w", err)
+	}
+	if err := client.FetchPrices(ctx); err != nil {
+		return fmt.Errorf("failed to fetch prices: %w", err)
+	}
+
+	holdings, targets := buildHoldings(client, cfg.TargetWeights)
+	plan, err := rebalance.Build(holdings, rebalance.Options{
+		Quote:     client.QuoteAsset(),
+		Targets:   targets,
+		FeeRate:   fee.Div(decimal.NewFromInt(100), 8),
+		Tolerance: tolerance,
+	})
+	if err != nil {
+		return err
+	}
+	if err := rebalance.WritePlan(os.Stdout, plan, client.QuoteCurrency()); err != nil {
+		return err
+	}
+
+	if f.validate && len(plan.Orders) > 0 {
+		fmt.Println("\nValidation:")
+		for _, order := range plan.Orders {
+			result, err := client.AddOrder(ctx, models.OrderRequest{
+				Pair:      order.Pair,
+				Type:      order.Side,
+				OrderType: "market",
+				Volume:    order.Volume,
+				Validate:  true,
+			})
+			if err != nil {
+				fmt.Printf("  %s %s: %v\n", order.Side, order.Pair, err)
+				continue
+			}
+			fmt.Printf("  ok: %s\n", result.Descr.Order)
+		}
+	}
+	return nil
+}
+
+// buildHoldings groups balances by the asset they are priced as, so staked
+// and held variants count towards their asset's weight, and adds the target
+// assets not held yet. Only the spot balance can be sold.
+func buildHoldings(client *api.Client, weights map[string]decimal.Decimal) ([]rebalance.Holding, map[string]decimal.Decimal) {
+	registry := client.Registry()
+	quote := client.QuoteAsset()
+	prices := client.Prices()
+
+	byAsset := make(map[string]*rebalance.Holding)
+	holding := func(key string) *rebalance.Holding {
+		h, ok := byAsset[key]
+		if !ok {
+			h = &rebalance.Holding{Asset: key, Name: registry.DisplayName(key)}
+			if route, ok := registry.RouteFor(key, quote); ok {
+				h.Price = route.Price(prices)
+			}
+			if pair, ok := registry.PairFor(key, quote); ok {
+				h.Pair = pair
+			}
+			byAsset[key] = h
+		}
+		return h
+	}
+
+	for asset, balance := range client.Balances() {
+		key := registry.ResolveFor(asset, quote)
+		route, ok := registry.RouteFor(asset, quote)
+		if !ok {
+			continue
+		}
+		h := holding(key)
+		h.Value = h.Value.Add(balance.Mul(route.Price(prices)))
+		if asset == key {
+			h.Balance = h.Balance.Add(balance)
+		}
+	}
+
+	targets := make(map[string]decimal.Decimal, len(weights))
+	for name, weight := range weights {
+		key := registry.ResolveFor(name, quote)
+		targets[key] = weight
+		holding(key)
+	}
+
+	holdings := make([]rebalance.Holding, 0, len(byAsset))
+	for _, h := range byAsset {
+		holdings = append(holdings, *h)
+	}
+	return holdings, targets
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/accounting"
+)
+
+type reportFlags struct {
+	envFile       string
+	method        string
+	year          int
+	csvPath       string
+	allowUnpriced bool
+}
+
+func parseReportFlags(args []string) *reportFlags {
+	f := &reportFlags{}
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.StringVar(&f.method, "method", "fifo", "Lot matching method: fifo, lifo, hifo or average")
+	fs.IntVar(&f.year, "year", time.Now().Year()-1, "Tax year to report")
+	fs.StringVar(&f.csvPath, "csv", "", "Write the disposals as CSV to this file, or - for stdout")
+	fs.BoolVar(&f.allowUnpriced, "allow-unpriced", false, "Book deposits, rewards and swaps without a daily close at a zero or carried-over cost basis")
+	fs.Parse(args)
+	return f
+}
+
+// runReport prints a year's capital gains in a Form 8949 layout, built from
+// the Ledgers and TradesHistory endpoints.
+func runReport(ctx context.Context, args []string) error {
+	f := parseReportFlags(args)
+
+	method, err := accounting.ParseMethod(f.method)
+	if err != nil {
+		return err
+	}
+
+	client, entries, opts, err := loadLedger(ctx, f.envFile)
+	if err != nil {
+		return err
+	}
+	if opts.Prices, err = dailyCloses(ctx, client, entries); err != nil {
+		return err
+	}
+	trades, err := client.FetchTrades(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch trades: %w", err)
+	}
+
+	realized, err := accounting.Realize(entries, method, opts)
+	if err != nil {
+		return err
+	}
+	if err := checkPriced(client.Registry(), realized, f.year, f.allowUnpriced); err != nil {
+		return err
+	}
+	registry := client.Registry()
+	report := accounting.NewTaxReport(realized, f.year, client.QuoteCurrency(), trades, registry.DisplayName)
+
+	var table io.Writer = os.Stdout
+	if f.csvPath == "-" {
+		table = os.Stderr
+	}
+	if err := accounting.WriteForm8949(table, report); err != nil {
+		return err
+	}
+
+	return writeCSV(f.csvPath, func(w io.Writer) error {
+		return accounting.WriteTaxCSV(w, report)
+	})
+}
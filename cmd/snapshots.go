@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+)
+
+var errNoSnapshotDir = errors.New("snapshots are turned off, set KRAKEN_SNAPSHOT_DIR")
+
+// recordSnapshots stores the portfolio every interval until ctx is
+// cancelled, skipping ticks while some holding is still unpriced.
+func recordSnapshots(ctx context.Context, client *api.Client, store *snapshot.Store, interval time.Duration, logger *log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			snap, ok := snapshot.FromAssets(client.GetAssetValues(), client.QuoteCurrency(), now)
+			if !ok {
+				continue
+			}
+			if err := store.Append(snap); err != nil {
+				logger.Printf("Failed to store snapshot: %v\n", err)
+			}
+		}
+	}
+}
+
+type snapshotsFlags struct {
+	envFile    string
+	resolution string
+	since      time.Duration
+	csvPath    string
+}
+
+func parseSnapshotsFlags(args []string) *snapshotsFlags {
+	f := &snapshotsFlags{}
+	fs := flag.NewFlagSet("snapshots", flag.ExitOnError)
+	fs.StringVar(&f.envFile, "env", ".env", "Path to env file")
+	fs.StringVar(&f.resolution, "resolution", "1h", "Series to read: 1m, 1h or 1d")
+	fs.DurationVar(&f.since, "since", 7*24*time.Hour, "How far back to go, 0 for everything")
+	fs.StringVar(&f.csvPath, "csv", "-", "Write the points as CSV to this file, or - for stdout")
+	fs.Parse(args)
+	return f
+}
+
+// runSnapshots exports the stored snapshots for charting elsewhere. It only
+// reads the store, so it needs no connection to Kraken.
+func runSnapshots(ctx context.Context, args []string) error {
+	f := parseSnapshotsFlags(args)
+
+	resolution, err := snapshot.ParseResolution(f.resolution)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(f.envFile)
+	if err != nil {
+		return err
+	}
+	if cfg.SnapshotDir == "" {
+		return errNoSnapshotDir
+	}
+
+	store, err := snapshot.Open(cfg.SnapshotDir, snapshot.DefaultOptions())
+	if err != nil {
+		return fmt.Errorf("failed to open snapshots: %w", err)
+	}
+	defer store.Close()
+
+	var from time.Time
+	if f.since > 0 {
+		from = time.Now().Add(-f.since)
+	}
+	points, err := store.Query(resolution, from, time.Time{})
+	if err != nil {
+		return err
+	}
+	return writeCSV(f.csvPath, func(w io.Writer) error {
+		return snapshot.WriteCSV(w, points)
+	})
+}
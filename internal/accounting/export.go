@@ -0,0 +1,100 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var csvHeader = []string{
+	"method", "asset", "refid", "acquired", "disposed",
+	"quantity", "proceeds", "cost_basis", "gain",
+}
+
+// DisposalsIn returns the disposals in a calendar year, or all of them for
+// year 0.
+func (r *Report) DisposalsIn(year int) []Disposal {
+	if year == 0 {
+		return r.Disposals
+	}
+	var out []Disposal
+	for _, d := range r.Disposals {
+		if d.Disposed.Year() == year {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// WriteCSV writes one row per matched disposal. Amounts are exact; an
+// unknown acquisition date is left empty.
+func WriteCSV(w io.Writer, reports []*Report, year int) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		for _, d := range r.DisposalsIn(year) {
+			row := []string{
+				string(r.Method),
+				d.Asset,
+				d.RefID,
+				formatDate(d.Acquired),
+				formatDate(d.Disposed),
+				d.Quantity.String(),
+				d.Proceeds.String(),
+				d.Cost.String(),
+				d.Gain.String(),
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteSummary prints realized gains and reward income per year, one block
+// per method, so methods can be compared side by side.
+func WriteSummary(w io.Writer, reports []*Report, year int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "METHOD\tYEAR\tDISPOSALS\tPROCEEDS\tCOST BASIS\tGAIN\tINCOME\t")
+
+	for _, r := range reports {
+		for _, y := range r.Years {
+			if year != 0 && y.Year != year {
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n",
+				r.Method, y.Year, y.Disposals,
+				y.Proceeds.StringFixed(2), y.Cost.StringFixed(2),
+				y.Gain.StringFixed(2), y.Income.StringFixed(2))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Warnings come from the ledger, not the method, so every report has
+	// the same ones.
+	if len(reports) > 0 && len(reports[0].Warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range reports[0].Warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+	return nil
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayout)
+}
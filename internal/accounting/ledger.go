@@ -0,0 +1,423 @@
+package accounting
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// PriceSource prices an asset in the quote currency at a point in time. It
+// values crypto-to-crypto swaps, deposits and staking rewards, which the
+// ledger itself records without a price.
+type PriceSource interface {
+	PriceAt(asset string, at time.Time) (decimal.Decimal, bool)
+}
+
+type Options struct {
+	// Quote is the registry key of the currency gains are measured in,
+	// e.g. ZUSD.
+	Quote string
+	// Normalize maps ledger asset names to the asset lots are kept under,
+	// so staked and held variants such as DOT.S share DOT's lots.
+	Normalize func(asset string) string
+	// Prices is optional. Without it swaps carry their cost basis over to
+	// the asset received and deposits and rewards get a zero basis.
+	Prices PriceSource
+}
+
+// Disposal is the part of a sale matched against one lot. Acquired is zero
+// for units sold beyond what the ledger shows being acquired.
+type Disposal struct {
+	Asset    string
+	RefID    string
+	Acquired time.Time
+	Disposed time.Time
+	Quantity decimal.Decimal
+	Proceeds decimal.Decimal
+	Cost     decimal.Decimal
+	Gain     decimal.Decimal
+}
+
+// Income is a staking or other reward, valued when it was received.
+type Income struct {
+	Asset    string
+	RefID    string
+	Time     time.Time
+	Quantity decimal.Decimal
+	Value    decimal.Decimal
+}
+
+type YearSummary struct {
+	Year      int
+	Disposals int
+	Proceeds  decimal.Decimal
+	Cost      decimal.Decimal
+	Gain      decimal.Decimal
+	Income    decimal.Decimal
+}
+
+type Report struct {
+	Method    Method
+	Disposals []Disposal
+	Income    []Income
+	Years     []YearSummary
+	Open      map[string][]models.Lot
+	Warnings  []string
+
+	// unpriced holds, by year, the assets whose figures for that year rest
+	// on an entry Prices had no price for.
+	unpriced map[int]map[string]bool
+}
+
+// UnpricedIn lists the assets, by registry key, whose income or gains in
+// year rest on an entry that was booked without a market price: a reward
+// received that year, a swap made that year, or a disposal that year of
+// units from an unpriced deposit, reward or swap. Year 0 means any year.
+func (r *Report) UnpricedIn(year int) []string {
+	seen := make(map[string]bool)
+	var assets []string
+	for y, names := range r.unpriced {
+		if year != 0 && y != year {
+			continue
+		}
+		for asset := range names {
+			if !seen[asset] {
+				seen[asset] = true
+				assets = append(assets, asset)
+			}
+		}
+	}
+	sort.Strings(assets)
+	return assets
+}
+
+// Year returns the summary for a calendar year, zero if nothing happened
+// in it.
+func (r *Report) Year(year int) YearSummary {
+	for _, y := range r.Years {
+		if y.Year == year {
+			return y
+		}
+	}
+	return YearSummary{Year: year}
+}
+
+type book struct {
+	method   Method
+	opts     Options
+	lots     map[string][]models.Lot
+	report   *Report
+	warnings map[string]int
+	// unpricedLots are the TradeIDs of lots acquired without a price.
+	unpricedLots map[string]bool
+}
+
+// Realize replays ledger entries in time order and reports realized gains
+// with disposals matched to lots under method. Tax years are calendar years
+// in UTC.
+func Realize(entries []models.LedgerEntry, method Method, opts Options) (*Report, error) {
+	if _, err := ParseMethod(string(method)); err != nil {
+		return nil, err
+	}
+	if opts.Normalize == nil {
+		opts.Normalize = func(asset string) string { return asset }
+	}
+
+	b := &book{
+		method:       method,
+		opts:         opts,
+		lots:         make(map[string][]models.Lot),
+		report:       &Report{Method: method, unpriced: make(map[int]map[string]bool)},
+		warnings:     make(map[string]int),
+		unpricedLots: make(map[string]bool),
+	}
+
+	sorted := append([]models.LedgerEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Time != sorted[j].Time {
+			return sorted[i].Time < sorted[j].Time
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	groups := make(map[string][]models.LedgerEntry)
+	for _, e := range sorted {
+		if isTradeLeg(e) {
+			groups[e.RefID] = append(groups[e.RefID], e)
+		}
+	}
+
+	done := make(map[string]bool)
+	for _, e := range sorted {
+		if isTradeLeg(e) {
+			if !done[e.RefID] {
+				done[e.RefID] = true
+				b.trade(groups[e.RefID])
+			}
+			continue
+		}
+		b.entry(e)
+	}
+
+	b.finish()
+	return b.report, nil
+}
+
+// isTradeLeg reports whether e is one side of an exchange. Trades and the
+// spend/receive pairs of instant buys share a refid across their legs.
+func isTradeLeg(e models.LedgerEntry) bool {
+	switch e.Type {
+	case "trade", "spend", "receive":
+		return e.RefID != ""
+	}
+	return false
+}
+
+func isReward(e models.LedgerEntry) bool {
+	switch e.Type {
+	case "staking", "dividend":
+		return true
+	case "earn":
+		return e.Subtype == "reward"
+	}
+	return false
+}
+
+func (b *book) entry(e models.LedgerEntry) {
+	asset := b.opts.Normalize(e.Asset)
+	at := ledgerTime(e.Time)
+	net := e.Amount.Sub(e.Fee)
+	if asset == b.opts.Quote || net.IsZero() {
+		return
+	}
+
+	switch {
+	case isReward(e) && net.Sign() > 0:
+		value, ok := b.value(asset, net, at)
+		if !ok {
+			b.markUnpriced(asset, at)
+			b.unpricedLots[e.RefID] = true
+			b.warn("%s rewards have no price and were given a zero cost basis", asset)
+		}
+		b.acquire(asset, e.RefID, at, net, value)
+		b.report.Income = append(b.report.Income, Income{
+			Asset: asset, RefID: e.RefID, Time: at, Quantity: net, Value: value,
+		})
+	case e.Type == "deposit" && net.Sign() > 0:
+		value, ok := b.value(asset, net, at)
+		if !ok {
+			b.unpricedLots[e.RefID] = true
+			b.warn("%s deposits have no price and were given a zero cost basis", asset)
+		}
+		b.acquire(asset, e.RefID, at, net, value)
+	case e.Type == "transfer" || e.Type == "earn":
+		// Moves between spot and staking wallets net out once normalized.
+	case net.Sign() < 0:
+		// Withdrawals and fees leave without proceeds and realize nothing.
+		b.remove(asset, net.Neg())
+	default:
+		b.warn("%s %s entries were added at a zero cost basis", asset, e.Type)
+		b.acquire(asset, e.RefID, at, net, decimal.Zero)
+	}
+}
+
+// trade settles one exchange. Each leg's fee is charged in its own asset,
+// so the net change is amount minus fee on both sides.
+func (b *book) trade(legs []models.LedgerEntry) {
+	at := ledgerTime(legs[0].Time)
+	refID := legs[0].RefID
+
+	nets := make(map[string]decimal.Decimal)
+	var assets []string
+	for _, leg := range legs {
+		asset := b.opts.Normalize(leg.Asset)
+		if _, ok := nets[asset]; !ok {
+			assets = append(assets, asset)
+		}
+		nets[asset] = nets[asset].Add(leg.Amount.Sub(leg.Fee))
+	}
+
+	quoteNet := nets[b.opts.Quote]
+	var out, in []string
+	for _, asset := range assets {
+		if asset == b.opts.Quote {
+			continue
+		}
+		switch nets[asset].Sign() {
+		case -1:
+			out = append(out, asset)
+		case 1:
+			in = append(in, asset)
+		}
+	}
+
+	switch {
+	case len(out) == 0 && len(in) == 1 && quoteNet.Sign() < 0:
+		b.acquire(in[0], refID, at, nets[in[0]], quoteNet.Neg())
+	case len(out) == 1 && len(in) == 0 && quoteNet.Sign() > 0:
+		b.dispose(out[0], refID, at, nets[out[0]].Neg(), quoteNet)
+	case len(out) == 1 && len(in) == 1 && quoteNet.IsZero():
+		b.swap(out[0], in[0], refID, at, nets[out[0]].Neg(), nets[in[0]])
+	default:
+		for _, asset := range out {
+			b.remove(asset, nets[asset].Neg())
+		}
+		for _, asset := range in {
+			b.warn("%s was received in a trade that could not be priced and was given a zero cost basis", asset)
+			b.acquire(asset, refID, at, nets[asset], decimal.Zero)
+		}
+	}
+}
+
+// swap exchanges one asset for another. With a price it is a disposal at
+// market value; without one the cost basis moves across and no gain is
+// realized.
+func (b *book) swap(from, to, refID string, at time.Time, sold, bought decimal.Decimal) {
+	value, ok := b.value(from, sold, at)
+	if !ok {
+		value, ok = b.value(to, bought, at)
+	}
+	if ok {
+		b.dispose(from, refID, at, sold, value)
+		b.acquire(to, refID, at, bought, value)
+		return
+	}
+
+	b.markUnpriced(from, at)
+	b.unpricedLots[refID] = true
+	b.warn("%s to %s swaps have no price; the cost basis was carried over", from, to)
+	lots, matches := consume(b.lots[from], sold, b.method)
+	b.lots[from] = lots
+	cost := decimal.Zero
+	for _, m := range matches {
+		cost = cost.Add(m.Cost)
+	}
+	b.acquire(to, refID, at, bought, cost)
+}
+
+func (b *book) value(asset string, quantity decimal.Decimal, at time.Time) (decimal.Decimal, bool) {
+	if b.opts.Prices == nil {
+		return decimal.Zero, false
+	}
+	price, ok := b.opts.Prices.PriceAt(asset, at)
+	if !ok {
+		return decimal.Zero, false
+	}
+	return quantity.Mul(price), true
+}
+
+func (b *book) acquire(asset, refID string, at time.Time, quantity, cost decimal.Decimal) {
+	b.lots[asset] = append(b.lots[asset], models.Lot{
+		Asset:    asset,
+		TradeID:  refID,
+		Time:     at,
+		Quantity: quantity,
+		Cost:     cost,
+	})
+}
+
+func (b *book) remove(asset string, quantity decimal.Decimal) {
+	b.lots[asset], _ = consume(b.lots[asset], quantity, b.method)
+}
+
+// dispose realizes a sale, splitting the proceeds across the matched lots
+// by quantity. The last portion takes the remainder so the parts add up to
+// the proceeds exactly.
+func (b *book) dispose(asset, refID string, at time.Time, quantity, proceeds decimal.Decimal) {
+	lots, matches := consume(b.lots[asset], quantity, b.method)
+	b.lots[asset] = lots
+
+	left := proceeds
+	for i, m := range matches {
+		share := left
+		if i < len(matches)-1 {
+			share = proceeds.Mul(m.Quantity).Div(quantity, precision)
+		}
+		left = left.Sub(share)
+
+		if b.unpricedLots[m.Lot] {
+			b.markUnpriced(asset, at)
+		}
+		if m.Acquired.IsZero() {
+			b.warn("more %s was sold than the ledger shows being acquired; the excess has a zero cost basis", asset)
+		}
+		b.report.Disposals = append(b.report.Disposals, Disposal{
+			Asset:    asset,
+			RefID:    refID,
+			Acquired: m.Acquired,
+			Disposed: at,
+			Quantity: m.Quantity,
+			Proceeds: share,
+			Cost:     m.Cost,
+			Gain:     share.Sub(m.Cost),
+		})
+	}
+}
+
+func (b *book) warn(format string, args ...interface{}) {
+	b.warnings[fmt.Sprintf(format, args...)]++
+}
+
+func (b *book) finish() {
+	years := make(map[int]*YearSummary)
+	year := func(t time.Time) *YearSummary {
+		y, ok := years[t.Year()]
+		if !ok {
+			y = &YearSummary{Year: t.Year()}
+			years[t.Year()] = y
+		}
+		return y
+	}
+
+	for _, d := range b.report.Disposals {
+		y := year(d.Disposed)
+		y.Disposals++
+		y.Proceeds = y.Proceeds.Add(d.Proceeds)
+		y.Cost = y.Cost.Add(d.Cost)
+		y.Gain = y.Gain.Add(d.Gain)
+	}
+	for _, income := range b.report.Income {
+		y := year(income.Time)
+		y.Income = y.Income.Add(income.Value)
+	}
+
+	for _, y := range years {
+		b.report.Years = append(b.report.Years, *y)
+	}
+	sort.Slice(b.report.Years, func(i, j int) bool {
+		return b.report.Years[i].Year < b.report.Years[j].Year
+	})
+
+	b.report.Open = make(map[string][]models.Lot)
+	for asset, lots := range b.lots {
+		if len(lots) > 0 {
+			b.report.Open[asset] = lots
+		}
+	}
+
+	for msg, n := range b.warnings {
+		if n > 1 {
+			msg = fmt.Sprintf("%s (%d times)", msg, n)
+		}
+		b.report.Warnings = append(b.report.Warnings, msg)
+	}
+	sort.Strings(b.report.Warnings)
+}
+
+func (b *book) markUnpriced(asset string, at time.Time) {
+	year := b.report.unpriced[at.Year()]
+	if year == nil {
+		year = make(map[string]bool)
+		b.report.unpriced[at.Year()] = year
+	}
+	year[asset] = true
+}
+
+func ledgerTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
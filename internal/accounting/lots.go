@@ -0,0 +1,135 @@
+package accounting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// Method decides which open lots a disposal is matched against.
+type Method string
+
+const (
+	FIFO    Method = "fifo"
+	LIFO    Method = "lifo"
+	HIFO    Method = "hifo"
+	Average Method = "average"
+)
+
+// Divisions are rounded to this many decimals, beyond any asset precision
+// Kraken uses.
+const precision = 18
+
+var Methods = []Method{FIFO, LIFO, HIFO, Average}
+
+func ParseMethod(s string) (Method, error) {
+	for _, m := range Methods {
+		if strings.EqualFold(s, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown lot matching method %q, want one of fifo, lifo, hifo or average", s)
+}
+
+// match is the part of one lot a disposal used up, Lot being the lot's
+// TradeID. A zero Acquired marks units sold beyond what the lots held.
+type match struct {
+	Lot      string
+	Acquired time.Time
+	Quantity decimal.Decimal
+	Cost     decimal.Decimal
+}
+
+// consume takes quantity out of lots under method and returns what is left
+// and the portions used.
+func consume(lots []models.Lot, quantity decimal.Decimal, method Method) ([]models.Lot, []match) {
+	if method == Average {
+		return consumeAverage(lots, quantity)
+	}
+
+	order := make([]int, len(lots))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		x, y := lots[order[a]], lots[order[b]]
+		switch method {
+		case LIFO:
+			return x.Time.After(y.Time)
+		case HIFO:
+			// Compare unit costs without dividing: x.Cost/x.Qty > y.Cost/y.Qty.
+			return x.Cost.Mul(y.Quantity).GreaterThan(y.Cost.Mul(x.Quantity))
+		}
+		return x.Time.Before(y.Time)
+	})
+
+	var matches []match
+	remaining := quantity
+	for _, i := range order {
+		if remaining.Sign() <= 0 {
+			break
+		}
+		lot := &lots[i]
+		if lot.Quantity.Cmp(remaining) <= 0 {
+			matches = append(matches, match{Lot: lot.TradeID, Acquired: lot.Time, Quantity: lot.Quantity, Cost: lot.Cost})
+			remaining = remaining.Sub(lot.Quantity)
+			lot.Quantity, lot.Cost = decimal.Zero, decimal.Zero
+			continue
+		}
+
+		cost := lot.Cost.Mul(remaining).Div(lot.Quantity, precision)
+		matches = append(matches, match{Lot: lot.TradeID, Acquired: lot.Time, Quantity: remaining, Cost: cost})
+		lot.Quantity = lot.Quantity.Sub(remaining)
+		lot.Cost = lot.Cost.Sub(cost)
+		remaining = decimal.Zero
+	}
+	if remaining.Sign() > 0 {
+		matches = append(matches, match{Quantity: remaining, Cost: decimal.Zero})
+	}
+	return openLots(lots), matches
+}
+
+// consumeAverage takes the same fraction out of every lot, so the pool's
+// average cost per unit is unchanged.
+func consumeAverage(lots []models.Lot, quantity decimal.Decimal) ([]models.Lot, []match) {
+	held := decimal.Zero
+	for _, lot := range lots {
+		held = held.Add(lot.Quantity)
+	}
+
+	var matches []match
+	excess := quantity.Sub(held)
+	if quantity.Cmp(held) >= 0 {
+		for _, lot := range lots {
+			matches = append(matches, match{Lot: lot.TradeID, Acquired: lot.Time, Quantity: lot.Quantity, Cost: lot.Cost})
+		}
+		if excess.Sign() > 0 {
+			matches = append(matches, match{Quantity: excess, Cost: decimal.Zero})
+		}
+		return nil, matches
+	}
+
+	for i := range lots {
+		lot := &lots[i]
+		qty := lot.Quantity.Mul(quantity).Div(held, precision)
+		cost := lot.Cost.Mul(quantity).Div(held, precision)
+		matches = append(matches, match{Lot: lot.TradeID, Acquired: lot.Time, Quantity: qty, Cost: cost})
+		lot.Quantity = lot.Quantity.Sub(qty)
+		lot.Cost = lot.Cost.Sub(cost)
+	}
+	return openLots(lots), matches
+}
+
+func openLots(lots []models.Lot) []models.Lot {
+	out := lots[:0]
+	for _, lot := range lots {
+		if lot.Quantity.Sign() > 0 {
+			out = append(out, lot)
+		}
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// Quantities in descriptions are rounded to this many decimals.
+const descriptionDecimals = 8
+
+// LongTerm reports whether the asset was held for more than one year. Units
+// without a known acquisition date count as short-term.
+func (d Disposal) LongTerm() bool {
+	if d.Acquired.IsZero() {
+		return false
+	}
+	return d.Disposed.After(d.Acquired.AddDate(1, 0, 0))
+}
+
+func (d Disposal) Term() string {
+	if d.LongTerm() {
+		return "long"
+	}
+	return "short"
+}
+
+// TaxRow is a disposal with the trade it came from.
+type TaxRow struct {
+	Disposal
+	Description string
+	Pair        string
+	OrderTxID   string
+}
+
+type TaxTotals struct {
+	Proceeds decimal.Decimal
+	Cost     decimal.Decimal
+	Gain     decimal.Decimal
+}
+
+func (t *TaxTotals) add(d Disposal) {
+	t.Proceeds = t.Proceeds.Add(d.Proceeds)
+	t.Cost = t.Cost.Add(d.Cost)
+	t.Gain = t.Gain.Add(d.Gain)
+}
+
+// TaxReport lists a year's disposals split by holding period, as Form 8949
+// does.
+type TaxReport struct {
+	Year       int
+	Method     Method
+	Currency   string
+	ShortTerm  []TaxRow
+	LongTerm   []TaxRow
+	ShortTotal TaxTotals
+	LongTotal  TaxTotals
+	Warnings   []string
+}
+
+// NewTaxReport builds the report for year from realized gains. Trades from
+// TradesHistory supply the pair and order of each disposal, matched on the
+// ledger refid, and displayName turns asset keys into the names shown.
+func NewTaxReport(r *Report, year int, currency string, trades []models.Trade, displayName func(string) string) *TaxReport {
+	byID := make(map[string]models.Trade, len(trades))
+	for _, trade := range trades {
+		byID[trade.TxID] = trade
+	}
+
+	tr := &TaxReport{Year: year, Method: r.Method, Currency: currency, Warnings: r.Warnings}
+	for _, d := range r.DisposalsIn(year) {
+		row := TaxRow{
+			Disposal:    d,
+			Description: fmt.Sprintf("%s %s", d.Quantity.Round(descriptionDecimals).String(), displayName(d.Asset)),
+		}
+		if trade, ok := byID[d.RefID]; ok {
+			row.Pair = trade.Pair
+			row.OrderTxID = trade.OrderTxID
+		}
+
+		if d.LongTerm() {
+			tr.LongTerm = append(tr.LongTerm, row)
+			tr.LongTotal.add(d)
+		} else {
+			tr.ShortTerm = append(tr.ShortTerm, row)
+			tr.ShortTotal.add(d)
+		}
+	}
+	return tr
+}
+
+var taxCSVHeader = []string{
+	"description", "date_acquired", "date_sold", "proceeds", "cost_basis",
+	"gain", "term", "asset", "quantity", "refid", "order_txid", "pair",
+}
+
+// WriteTaxCSV writes short-term disposals followed by long-term ones, with
+// exact amounts.
+func WriteTaxCSV(w io.Writer, tr *TaxReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(taxCSVHeader); err != nil {
+		return err
+	}
+
+	for _, rows := range [][]TaxRow{tr.ShortTerm, tr.LongTerm} {
+		for _, row := range rows {
+			record := []string{
+				row.Description,
+				formatDate(row.Acquired),
+				formatDate(row.Disposed),
+				row.Proceeds.String(),
+				row.Cost.String(),
+				row.Gain.String(),
+				row.Term(),
+				row.Asset,
+				row.Quantity.String(),
+				row.RefID,
+				row.OrderTxID,
+				row.Pair,
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteForm8949 prints the report in the layout of IRS Form 8949: Part I for
+// short-term and Part II for long-term disposals, columns (a) to (h), and a
+// total per part. Amounts are rounded to cents.
+func WriteForm8949(w io.Writer, tr *TaxReport) error {
+	fmt.Fprintf(w, "Sales and Other Dispositions of Capital Assets - %d\n", tr.Year)
+	fmt.Fprintf(w, "Method: %s    Currency: %s\n", strings.ToUpper(string(tr.Method)), tr.Currency)
+
+	parts := []struct {
+		title string
+		rows  []TaxRow
+		total TaxTotals
+	}{
+		{"Part I - Short-Term (held one year or less)", tr.ShortTerm, tr.ShortTotal},
+		{"Part II - Long-Term (held more than one year)", tr.LongTerm, tr.LongTotal},
+	}
+
+	for _, part := range parts {
+		fmt.Fprintf(w, "\n%s\n\n", part.title)
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "(a) Description\t(b) Acquired\t(c) Sold\t(d) Proceeds\t(e) Cost basis\t(f) Code\t(g) Adjustment\t(h) Gain or (loss)\t")
+		for _, row := range part.rows {
+			acquired := formatDate(row.Acquired)
+			if acquired == "" {
+				acquired = "VARIOUS"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\t\t%s\t\n",
+				row.Description, acquired, formatDate(row.Disposed),
+				row.Proceeds.StringFixed(2), row.Cost.StringFixed(2), formatGain(row.Gain))
+		}
+		fmt.Fprintf(tw, "Totals\t\t\t%s\t%s\t\t\t%s\t\n",
+			part.total.Proceeds.StringFixed(2), part.total.Cost.StringFixed(2), formatGain(part.total.Gain))
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(tr.Warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range tr.Warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+	return nil
+}
+
+// formatGain shows losses in parentheses, as the form does.
+func formatGain(gain decimal.Decimal) string {
+	if gain.Sign() < 0 {
+		return "(" + gain.Abs().StringFixed(2) + ")"
+	}
+	return gain.StringFixed(2)
+}
@@ -0,0 +1,364 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+const (
+	// Alerts waiting for the engine, or for any one notifier, beyond this
+	// many are dropped.
+	queueSize = 64
+	// Move rules keep about this many price samples per window.
+	samplesPerWindow = 500
+	// Values in messages are rounded to this many decimals.
+	messageDecimals = 8
+)
+
+var hundred = decimal.NewFromInt(100)
+
+type Options struct {
+	// Hysteresis is how far, in percent of the threshold, the value must
+	// fall back before a rule that fired is armed again.
+	Hysteresis decimal.Decimal
+	// Cooldown is the least time between two alerts of the same rule.
+	Cooldown time.Duration
+	// Normalize maps a weight rule's asset to the key Snapshot.Weights uses,
+	// e.g. BTC to the registry key XXBT. Without it the asset is used as
+	// written.
+	Normalize func(asset string) string
+	// Logger records dropped alerts and failed notifications. It defaults
+	// to the standard logger.
+	Logger *log.Logger
+}
+
+// Snapshot is the portfolio as the rules see it. Prices are per unit in
+// the quote currency, keyed by upper-case asset name as the rules write it.
+// Weights are keyed as Options.Normalize maps rule assets. Total is zero and
+// Weights nil while some holding is still unpriced, so rules on them do not
+// fire on a partial portfolio.
+type Snapshot struct {
+	Time    time.Time
+	Prices  map[string]decimal.Decimal
+	Total   decimal.Decimal
+	Weights map[string]decimal.Decimal
+}
+
+type EventType string
+
+const (
+	EventAlert        EventType = "alert"
+	EventDisconnected EventType = "disconnected"
+	EventReconnected  EventType = "reconnected"
+	EventAPIError     EventType = "api_error"
+)
+
+// Alert is anything the notifiers are told about: a rule that fired, or a
+// connection or API event, which has no Rule or Value.
+type Alert struct {
+	Type    EventType
+	Rule    Rule
+	Value   decimal.Decimal
+	Time    time.Time
+	Message string
+}
+
+// Engine evaluates rules against each snapshot. A rule fires once when its
+// condition becomes true and is re-armed only after the value falls back
+// past the hysteresis band; even then it stays quiet for the cooldown.
+// Evaluate and Emit are safe for concurrent use and never wait on a
+// notifier.
+type Engine struct {
+	rules     []Rule
+	opts      Options
+	notifiers []Notifier
+
+	mu      sync.Mutex
+	states  []ruleState
+	windows map[string]time.Duration
+	history map[string][]sample
+	errors  map[string]time.Time
+
+	queue chan Alert
+}
+
+type ruleState struct {
+	fired bool
+	last  time.Time
+}
+
+type sample struct {
+	time  time.Time
+	price decimal.Decimal
+}
+
+func NewEngine(rules []Rule, opts Options, notifiers ...Notifier) *Engine {
+	e := &Engine{
+		rules:     rules,
+		opts:      opts,
+		notifiers: notifiers,
+		states:    make([]ruleState, len(rules)),
+		windows:   make(map[string]time.Duration),
+		history:   make(map[string][]sample),
+		errors:    make(map[string]time.Time),
+		queue:     make(chan Alert, queueSize),
+	}
+	if e.opts.Normalize == nil {
+		e.opts.Normalize = func(asset string) string { return asset }
+	}
+	if e.opts.Logger == nil {
+		e.opts.Logger = log.Default()
+	}
+	for _, rule := range rules {
+		if rule.Kind == Move && rule.Window > e.windows[rule.Asset] {
+			e.windows[rule.Asset] = rule.Window
+		}
+	}
+	return e
+}
+
+func (e *Engine) Rules() []Rule {
+	return append([]Rule(nil), e.rules...)
+}
+
+// Assets lists the assets whose prices the rules need.
+func (e *Engine) Assets() []string {
+	seen := make(map[string]bool)
+	var assets []string
+	for _, rule := range e.rules {
+		switch rule.Kind {
+		case Above, Below, Move:
+			if !seen[rule.Asset] {
+				seen[rule.Asset] = true
+				assets = append(assets, rule.Asset)
+			}
+		}
+	}
+	return assets
+}
+
+// Evaluate checks every rule against s, queues an alert for each that fires
+// and returns them.
+func (e *Engine) Evaluate(s Snapshot) []Alert {
+	e.mu.Lock()
+	e.record(s)
+
+	var fired []Alert
+	for i, rule := range e.rules {
+		value, ok := e.value(rule, s)
+		if !ok {
+			continue
+		}
+
+		state := &e.states[i]
+		if state.fired {
+			if e.rearmed(rule, value) {
+				state.fired = false
+			}
+			continue
+		}
+		if !triggered(rule, value) {
+			continue
+		}
+		if !state.last.IsZero() && s.Time.Sub(state.last) < e.opts.Cooldown {
+			continue
+		}
+
+		state.fired = true
+		state.last = s.Time
+		fired = append(fired, Alert{Type: EventAlert, Rule: rule, Value: value, Time: s.Time, Message: message(rule, value)})
+	}
+	e.mu.Unlock()
+
+	for _, alert := range fired {
+		e.enqueue(alert)
+	}
+	return fired
+}
+
+// Emit queues an event that does not come from a rule. The same API error
+// is passed on at most once per cooldown.
+func (e *Engine) Emit(eventType EventType, message string, at time.Time) {
+	if eventType == EventAPIError {
+		e.mu.Lock()
+		last, seen := e.errors[message]
+		quiet := seen && at.Sub(last) < e.opts.Cooldown
+		if !quiet {
+			e.errors[message] = at
+		}
+		e.mu.Unlock()
+		if quiet {
+			return
+		}
+	}
+	e.enqueue(Alert{Type: eventType, Time: at, Message: message})
+}
+
+func (e *Engine) enqueue(alert Alert) {
+	select {
+	case e.queue <- alert:
+	default:
+		e.opts.Logger.Printf("Alert queue full, dropping: %s", alert.Message)
+	}
+}
+
+// Run hands queued alerts to every notifier until ctx is cancelled. Each
+// notifier has a queue and goroutine of its own, so a slow or failing one
+// does not hold up the others; a failure is logged.
+func (e *Engine) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	queues := make([]chan Alert, len(e.notifiers))
+	for i, notifier := range e.notifiers {
+		queue := make(chan Alert, queueSize)
+		queues[i] = queue
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.deliver(ctx, notifier, queue)
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-e.queue:
+			for _, queue := range queues {
+				select {
+				case queue <- alert:
+				default:
+					e.opts.Logger.Printf("Alert notifier is behind, dropping: %s", alert.Message)
+				}
+			}
+		}
+	}
+}
+
+func (e *Engine) deliver(ctx context.Context, notifier Notifier, queue <-chan Alert) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-queue:
+			if err := notifier.Notify(ctx, alert); err != nil {
+				e.opts.Logger.Printf("Alert notification failed: %v", err)
+			}
+		}
+	}
+}
+
+// record keeps the price history move rules measure against, at most one
+// sample per window/samplesPerWindow.
+func (e *Engine) record(s Snapshot) {
+	for asset, window := range e.windows {
+		price, ok := s.Prices[asset]
+		if !ok || price.Sign() <= 0 {
+			continue
+		}
+
+		samples := e.history[asset]
+		cutoff := s.Time.Add(-window)
+		drop := 0
+		for drop < len(samples) && samples[drop].time.Before(cutoff) {
+			drop++
+		}
+		samples = samples[drop:]
+
+		if n := len(samples); n == 0 || s.Time.Sub(samples[n-1].time) >= window/samplesPerWindow {
+			samples = append(samples, sample{time: s.Time, price: price})
+		}
+		e.history[asset] = samples
+	}
+}
+
+func (e *Engine) value(rule Rule, s Snapshot) (decimal.Decimal, bool) {
+	switch rule.Kind {
+	case Above, Below:
+		price, ok := s.Prices[rule.Asset]
+		return price, ok && price.Sign() > 0
+	case Move:
+		price, ok := s.Prices[rule.Asset]
+		if !ok || price.Sign() <= 0 {
+			return decimal.Zero, false
+		}
+		cutoff := s.Time.Add(-rule.Window)
+		for _, sample := range e.history[rule.Asset] {
+			if !sample.time.Before(cutoff) {
+				return price.Sub(sample.price).Mul(hundred).Div(sample.price, 4), true
+			}
+		}
+		return decimal.Zero, false
+	case TotalAbove, TotalBelow:
+		return s.Total, s.Total.Sign() > 0
+	case WeightAbove:
+		return s.Weights[e.opts.Normalize(rule.Asset)], s.Weights != nil
+	}
+	return decimal.Zero, false
+}
+
+func triggered(rule Rule, value decimal.Decimal) bool {
+	switch rule.Kind {
+	case Above, TotalAbove, WeightAbove:
+		return value.GreaterThan(rule.Threshold)
+	case Below, TotalBelow:
+		return value.LessThan(rule.Threshold)
+	case Move:
+		return value.Abs().Cmp(rule.Threshold) >= 0
+	}
+	return false
+}
+
+func (e *Engine) rearmed(rule Rule, value decimal.Decimal) bool {
+	band := rule.Threshold.Mul(e.opts.Hysteresis).Div(hundred, messageDecimals)
+	switch rule.Kind {
+	case Above, TotalAbove, WeightAbove:
+		return value.LessThan(rule.Threshold.Sub(band))
+	case Below, TotalBelow:
+		return value.GreaterThan(rule.Threshold.Add(band))
+	case Move:
+		return value.Abs().LessThan(rule.Threshold.Sub(band))
+	}
+	return true
+}
+
+func message(rule Rule, value decimal.Decimal) string {
+	v := value.Round(messageDecimals).String()
+	switch rule.Kind {
+	case Above, Below:
+		return fmt.Sprintf("%s %s %s: %s", rule.Asset, rule.Kind, rule.Threshold.String(), v)
+	case Move:
+		sign := ""
+		if value.Sign() >= 0 {
+			sign = "+"
+		}
+		return fmt.Sprintf("%s moved %s%s%% within %s", rule.Asset, sign, value.StringFixed(2), formatWindow(rule.Window))
+	case TotalAbove:
+		return fmt.Sprintf("Portfolio total above %s: %s", rule.Threshold.String(), value.StringFixed(2))
+	case TotalBelow:
+		return fmt.Sprintf("Portfolio total below %s: %s", rule.Threshold.String(), value.StringFixed(2))
+	case WeightAbove:
+		return fmt.Sprintf("%s allocation above %s%%: %s%%", rule.Asset, rule.Threshold.String(), value.StringFixed(1))
+	}
+	return rule.String()
+}
+
+// formatWindow drops the zero units time.Duration prints, e.g. 1h0m0s
+// becomes 1h.
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,33 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Notifier delivers alerts and events. Each notifier's Notify is called
+// from a goroutine of its own, one alert at a time, so a slow notifier
+// delays only its own alerts.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+type NotifierFunc func(ctx context.Context, alert Alert) error
+
+func (f NotifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// WriterNotifier writes each alert as a timestamped line, e.g. to a log
+// file.
+type WriterNotifier struct {
+	W io.Writer
+}
+
+func (n WriterNotifier) Notify(_ context.Context, alert Alert) error {
+	_, err := fmt.Fprintf(n.W, "%s %s %s\n", alert.Time.Format(time.RFC3339), strings.ToUpper(string(alert.Type)), alert.Message)
+	return err
+}
@@ -0,0 +1,138 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+type Kind string
+
+const (
+	// Above and Below compare an asset's price in the quote currency.
+	Above Kind = "above"
+	Below Kind = "below"
+	// Move fires when the price changed by Threshold percent, either way,
+	// within Window.
+	Move Kind = "move"
+	// TotalAbove and TotalBelow compare the portfolio total.
+	TotalAbove Kind = "total_above"
+	TotalBelow Kind = "total_below"
+	// WeightAbove fires when an asset's share of the total exceeds
+	// Threshold percent.
+	WeightAbove Kind = "weight_above"
+)
+
+// Total is the name rules use for the portfolio as a whole.
+const Total = "TOTAL"
+
+type Rule struct {
+	Kind      Kind
+	Asset     string
+	Threshold decimal.Decimal
+	Window    time.Duration
+}
+
+// String gives the rule back in the form ParseRules reads.
+func (r Rule) String() string {
+	switch r.Kind {
+	case Above, TotalAbove:
+		return r.Asset + ">" + r.Threshold.String()
+	case Below, TotalBelow:
+		return r.Asset + "<" + r.Threshold.String()
+	case Move:
+		return fmt.Sprintf("%s~%s%%/%s", r.Asset, r.Threshold.String(), formatWindow(r.Window))
+	case WeightAbove:
+		return r.Asset + ">" + r.Threshold.String() + "%"
+	}
+	return string(r.Kind)
+}
+
+// ParseRules reads a comma-separated list of rules:
+//
+//	BTC>70000       price above
+//	ETH<2500        price below
+//	SOL~5%/1h       moved 5% either way within an hour
+//	TOTAL>100000    portfolio total above (or < for below)
+//	BTC>60%         allocation above 60%
+//
+// Prices and totals are in the quote currency.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		rule, err := parseRule(item)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(item string) (Rule, error) {
+	if asset, spec, ok := strings.Cut(item, "~"); ok {
+		pct, window, ok := strings.Cut(spec, "/")
+		if !ok {
+			return Rule{}, fmt.Errorf("%q is not ASSET~PERCENT/WINDOW", item)
+		}
+		threshold, err := parseThreshold(strings.TrimSuffix(strings.TrimSpace(pct), "%"))
+		if err != nil {
+			return Rule{}, fmt.Errorf("%q: %w", item, err)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil || d <= 0 {
+			return Rule{}, fmt.Errorf("%q: invalid window %q", item, window)
+		}
+		return Rule{Kind: Move, Asset: assetName(asset), Threshold: threshold, Window: d}, nil
+	}
+
+	i := strings.IndexAny(item, "<>")
+	if i < 0 {
+		return Rule{}, fmt.Errorf("%q is not a rule, e.g. BTC>70000", item)
+	}
+	asset, op, value := assetName(item[:i]), item[i], strings.TrimSpace(item[i+1:])
+	if asset == "" {
+		return Rule{}, fmt.Errorf("%q has no asset", item)
+	}
+
+	weight := strings.HasSuffix(value, "%")
+	threshold, err := parseThreshold(strings.TrimSuffix(value, "%"))
+	if err != nil {
+		return Rule{}, fmt.Errorf("%q: %w", item, err)
+	}
+	rule := Rule{Asset: asset, Threshold: threshold}
+
+	switch {
+	case weight && (asset == Total || op == '<'):
+		return Rule{}, fmt.Errorf("%q: allocation rules take an asset and >", item)
+	case weight:
+		rule.Kind = WeightAbove
+	case asset == Total && op == '>':
+		rule.Kind = TotalAbove
+	case asset == Total:
+		rule.Kind = TotalBelow
+	case op == '>':
+		rule.Kind = Above
+	default:
+		rule.Kind = Below
+	}
+	return rule, nil
+}
+
+func parseThreshold(s string) (decimal.Decimal, error) {
+	d, err := decimal.Parse(strings.TrimSpace(s))
+	if err != nil || d.Sign() <= 0 {
+		return decimal.Zero, fmt.Errorf("invalid threshold %q", s)
+	}
+	return d, nil
+}
+
+func assetName(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
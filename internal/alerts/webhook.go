@@ -0,0 +1,124 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body, keyed
+// with the webhook secret and prefixed with "sha256=".
+const SignatureHeader = "X-Signature-256"
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+	webhookTimeout        = 10 * time.Second
+)
+
+// Payload is the JSON body a webhook receives. Rule, Asset and Value are
+// only set for alerts.
+type Payload struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Rule    string    `json:"rule,omitempty"`
+	Asset   string    `json:"asset,omitempty"`
+	Value   string    `json:"value,omitempty"`
+}
+
+func NewPayload(alert Alert) Payload {
+	p := Payload{Type: alert.Type, Time: alert.Time.UTC(), Message: alert.Message}
+	if alert.Type == EventAlert {
+		p.Rule = alert.Rule.String()
+		p.Asset = alert.Rule.Asset
+		p.Value = alert.Value.String()
+	}
+	return p
+}
+
+// Webhook posts each alert as JSON. Failed deliveries - network errors, 429
+// and 5xx responses - are retried up to Retries times, waiting Backoff and
+// doubling it after each attempt. Other responses are not retried.
+type Webhook struct {
+	URL     string
+	Secret  string
+	Client  *http.Client
+	Retries int
+	Backoff time.Duration
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		URL:     url,
+		Secret:  secret,
+		Client:  &http.Client{Timeout: webhookTimeout},
+		Retries: defaultWebhookRetries,
+		Backoff: defaultWebhookBackoff,
+	}
+}
+
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(NewPayload(alert))
+	if err != nil {
+		return err
+	}
+
+	backoff := w.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.Retries {
+			return fmt.Errorf("webhook: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post reports whether a failed delivery is worth retrying.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.Secret, body))
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// Sign is the hex HMAC-SHA256 of body keyed with secret, as receivers
+// should recompute it.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
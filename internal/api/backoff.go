@@ -0,0 +1,39 @@
+package api
+
+import (
+	"math/rand"
+	"time"
+)
+
+type Backoff struct {
+	Min     time.Duration
+	Max     time.Duration
+	attempt int
+}
+
+func NewBackoff(min, max time.Duration) *Backoff {
+	return &Backoff{Min: min, Max: max}
+}
+
+// Next doubles the delay on every call up to Max and returns a random
+// duration in its upper half, so clients dropped together do not reconnect
+// in lockstep.
+func (b *Backoff) Next() time.Duration {
+	delay := b.Max
+	if b.attempt < 32 {
+		if d := b.Min << b.attempt; d > 0 && d < b.Max {
+			delay = d
+		}
+	}
+	b.attempt++
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+const pnlPctDecimals = 4
+
+// LoadCostBasis rebuilds the open tax lots from the full trade history. It
+// needs an API key with permission to query closed orders and trades.
+func (c *Client) LoadCostBasis(ctx context.Context) error {
+	trades, err := c.FetchTrades(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trades = trades
+	c.lots = buildLots(c.registry, c.registry.canonical(c.quote), trades)
+	return nil
+}
+
+// refreshCostBasis fetches the trades since the last one known and rebuilds
+// the lots, if they were loaded at all. It asks from a second before the
+// last trade so none in the same second is missed; the overlap is merged by
+// ID.
+func (c *Client) refreshCostBasis(ctx context.Context) {
+	c.mu.RLock()
+	loaded := c.lots != nil
+	var start float64
+	if n := len(c.trades); n > 0 {
+		start = c.trades[n-1].Time - 1
+	}
+	c.mu.RUnlock()
+	if !loaded {
+		return
+	}
+
+	trades, err := c.fetchTradesSince(ctx, start)
+	if err != nil {
+		c.reportError("Failed to refresh cost basis: %w", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trades = mergeTrades(c.trades, trades)
+	c.lots = buildLots(c.registry, c.registry.canonical(c.quote), c.trades)
+}
+
+func (c *Client) Lots() map[string][]models.Lot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make(map[string][]models.Lot, len(c.lots))
+	for asset, lots := range c.lots {
+		out[asset] = append([]models.Lot(nil), lots...)
+	}
+	return out
+}
+
+// buildLots replays trades, oldest first, under the average cost method. A
+// buy paid in the quote currency opens a lot at its cost plus fee. Anything
+// that disposes of an asset - a sell, or a buy paid with it - shrinks all of
+// its open lots in proportion, which leaves the average cost unchanged. Buys
+// paid in another currency cannot be costed without historical FX rates and
+// open no lot.
+func buildLots(registry *Registry, quote string, trades []models.Trade) map[string][]models.Lot {
+	lots := make(map[string][]models.Lot)
+
+	for _, trade := range trades {
+		pair, ok := registry.Pairs[trade.Pair]
+		if !ok {
+			continue
+		}
+
+		switch trade.Type {
+		case "buy":
+			if pair.Quote == quote {
+				lots[pair.Base] = append(lots[pair.Base], models.Lot{
+					Asset:    pair.Base,
+					TradeID:  trade.TxID,
+					Time:     tradeTime(trade.Time),
+					Quantity: trade.Vol,
+					Cost:     trade.Cost.Add(trade.Fee),
+				})
+			} else {
+				lots[pair.Quote] = dispose(lots[pair.Quote], trade.Cost.Add(trade.Fee))
+			}
+		case "sell":
+			lots[pair.Base] = dispose(lots[pair.Base], trade.Vol)
+		}
+	}
+
+	for asset, open := range lots {
+		if len(open) == 0 {
+			delete(lots, asset)
+		}
+	}
+	return lots
+}
+
+// dispose removes quantity from lots pro rata. Selling more than the lots
+// hold, e.g. coins that were deposited rather than bought, closes them all.
+func dispose(lots []models.Lot, quantity decimal.Decimal) []models.Lot {
+	held := decimal.Zero
+	for _, lot := range lots {
+		held = held.Add(lot.Quantity)
+	}
+	if held.Sign() <= 0 {
+		return lots
+	}
+	if quantity.Cmp(held) >= 0 {
+		return nil
+	}
+
+	remaining := held.Sub(quantity)
+	out := lots[:0]
+	for _, lot := range lots {
+		lot.Quantity = lot.Quantity.Mul(remaining).Div(held, routeDecimals)
+		lot.Cost = lot.Cost.Mul(remaining).Div(held, routeDecimals)
+		if lot.Quantity.Sign() > 0 {
+			out = append(out, lot)
+		}
+	}
+	return out
+}
+
+func tradeTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// averageCost is the cost per unit across open lots, or zero without any.
+func averageCost(lots []models.Lot) decimal.Decimal {
+	quantity, cost := decimal.Zero, decimal.Zero
+	for _, lot := range lots {
+		quantity = quantity.Add(lot.Quantity)
+		cost = cost.Add(lot.Cost)
+	}
+	if quantity.Sign() <= 0 {
+		return decimal.Zero
+	}
+	return cost.Div(quantity, routeDecimals)
+}
+
+// applyCostBasis values the whole balance at the average cost of the open
+// lots, so units that were deposited or earned rather than bought are
+// assumed to have cost the same. P&L waits for the first price.
+func applyCostBasis(value *models.AssetValue, avgCost decimal.Decimal) {
+	if avgCost.IsZero() {
+		return
+	}
+
+	value.AvgCost = avgCost
+	value.CostBasis = value.Balance.Mul(avgCost)
+	if value.Price.IsZero() {
+		return
+	}
+	value.UnrealizedPnL = value.Value.Sub(value.CostBasis)
+	if value.CostBasis.Sign() > 0 {
+		value.PnLPct = value.UnrealizedPnL.Mul(decimal.NewFromInt(100)).Div(value.CostBasis, pnlPctDecimals)
+	}
+}
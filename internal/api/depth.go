@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+// FetchOrderBook returns the best count bids and asks of a pair, named by
+// key, altname or wsname.
+func (c *Client) FetchOrderBook(ctx context.Context, pair string, count int) (models.OrderBook, error) {
+	info, ok := c.Registry().PairNamed(pair)
+	if !ok {
+		return models.OrderBook{}, fmt.Errorf("unknown pair %q", pair)
+	}
+	query := url.Values{"pair": {info.Altname}, "count": {strconv.Itoa(count)}}
+
+	var resp models.DepthResponse
+	if err := c.publicGet(ctx, "Depth?"+query.Encode(), &resp); err != nil {
+		return models.OrderBook{}, err
+	}
+	if len(resp.Error) > 0 {
+		return models.OrderBook{}, &APIError{Errors: resp.Error}
+	}
+
+	book := models.OrderBook{Pair: info.WsName, Time: time.Now()}
+	for _, levels := range resp.Result {
+		var err error
+		if book.Bids, err = parseLevels(levels.Bids); err != nil {
+			return models.OrderBook{}, fmt.Errorf("malformed order book for %s: %w", pair, err)
+		}
+		if book.Asks, err = parseLevels(levels.Asks); err != nil {
+			return models.OrderBook{}, fmt.Errorf("malformed order book for %s: %w", pair, err)
+		}
+	}
+	return book, nil
+}
+
+func parseLevels(rows [][]json.RawMessage) ([]models.BookLevel, error) {
+	levels := make([]models.BookLevel, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("level has %d fields", len(row))
+		}
+		var level models.BookLevel
+		if err := json.Unmarshal(row[0], &level.Price); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(row[1], &level.Volume); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+const (
+	rateLimitError = "EAPI:Rate limit exceeded"
+
+	minHistoryRetry = 2 * time.Second
+	maxHistoryRetry = 30 * time.Second
+)
+
+// FetchTrades pages through TradesHistory and returns every trade, oldest
+// first.
+func (c *Client) FetchTrades(ctx context.Context) ([]models.Trade, error) {
+	return c.fetchTrades(ctx, url.Values{})
+}
+
+// fetchTradesSince returns the trades after start, a Unix time, oldest
+// first.
+func (c *Client) fetchTradesSince(ctx context.Context, start float64) ([]models.Trade, error) {
+	return c.fetchTrades(ctx, url.Values{"start": {strconv.FormatFloat(start, 'f', -1, 64)}})
+}
+
+func (c *Client) fetchTrades(ctx context.Context, params url.Values) ([]models.Trade, error) {
+	trades := make(map[string]models.Trade)
+	backoff := NewBackoff(minHistoryRetry, maxHistoryRetry)
+
+	for ofs := 0; ; {
+		params.Set("ofs", strconv.Itoa(ofs))
+		var resp models.TradesHistoryResponse
+		err := c.privatePost(ctx, "TradesHistory", params, &resp)
+		if err == nil && len(resp.Error) > 0 {
+			err = &APIError{Errors: resp.Error}
+		}
+		if err != nil {
+			if waitRateLimit(ctx, err, backoff) {
+				continue
+			}
+			return nil, err
+		}
+
+		for id, trade := range resp.Result.Trades {
+			trade.TxID = id
+			trades[id] = trade
+		}
+		ofs += len(resp.Result.Trades)
+		if len(resp.Result.Trades) == 0 || ofs >= resp.Result.Count {
+			break
+		}
+	}
+
+	out := make([]models.Trade, 0, len(trades))
+	for _, trade := range trades {
+		out = append(out, trade)
+	}
+	sortTrades(out)
+	return out, nil
+}
+
+// mergeTrades adds the trades in more that known does not have yet, by ID,
+// and keeps the result oldest first.
+func mergeTrades(known, more []models.Trade) []models.Trade {
+	seen := make(map[string]bool, len(known))
+	for _, trade := range known {
+		seen[trade.TxID] = true
+	}
+	out := append([]models.Trade(nil), known...)
+	for _, trade := range more {
+		if !seen[trade.TxID] {
+			seen[trade.TxID] = true
+			out = append(out, trade)
+		}
+	}
+	sortTrades(out)
+	return out
+}
+
+func sortTrades(trades []models.Trade) {
+	sort.Slice(trades, func(i, j int) bool {
+		if trades[i].Time != trades[j].Time {
+			return trades[i].Time < trades[j].Time
+		}
+		return trades[i].TxID < trades[j].TxID
+	})
+}
+
+// FetchLedgers pages through Ledgers and returns every entry, oldest first.
+func (c *Client) FetchLedgers(ctx context.Context) ([]models.LedgerEntry, error) {
+	return c.fetchLedgers(ctx, url.Values{})
+}
+
+// UpdateLedgers returns known, oldest first, with the entries added since
+// the last of them. It asks from a second before that entry so none in the
+// same second is missed; the overlap is merged by ID. With nothing known it
+// fetches the whole ledger.
+func (c *Client) UpdateLedgers(ctx context.Context, known []models.LedgerEntry) ([]models.LedgerEntry, error) {
+	params := url.Values{}
+	if n := len(known); n > 0 {
+		params.Set("start", strconv.FormatFloat(known[n-1].Time-1, 'f', -1, 64))
+	}
+	entries, err := c.fetchLedgers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return mergeLedgers(known, entries), nil
+}
+
+func (c *Client) fetchLedgers(ctx context.Context, params url.Values) ([]models.LedgerEntry, error) {
+	entries := make(map[string]models.LedgerEntry)
+	backoff := NewBackoff(minHistoryRetry, maxHistoryRetry)
+
+	for ofs := 0; ; {
+		params.Set("ofs", strconv.Itoa(ofs))
+		var resp models.LedgersResponse
+		err := c.privatePost(ctx, "Ledgers", params, &resp)
+		if err == nil && len(resp.Error) > 0 {
+			err = &APIError{Errors: resp.Error}
+		}
+		if err != nil {
+			if waitRateLimit(ctx, err, backoff) {
+				continue
+			}
+			return nil, err
+		}
+
+		for id, entry := range resp.Result.Ledger {
+			entry.ID = id
+			entries[id] = entry
+		}
+		ofs += len(resp.Result.Ledger)
+		if len(resp.Result.Ledger) == 0 || ofs >= resp.Result.Count {
+			break
+		}
+	}
+
+	out := make([]models.LedgerEntry, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, entry)
+	}
+	sortLedgers(out)
+	return out, nil
+}
+
+// mergeLedgers adds the entries in more that known does not have yet, by
+// ID, and keeps the result oldest first.
+func mergeLedgers(known, more []models.LedgerEntry) []models.LedgerEntry {
+	seen := make(map[string]bool, len(known))
+	out := make([]models.LedgerEntry, 0, len(known)+len(more))
+	for _, entry := range known {
+		seen[entry.ID] = true
+		out = append(out, entry)
+	}
+	for _, entry := range more {
+		if !seen[entry.ID] {
+			seen[entry.ID] = true
+			out = append(out, entry)
+		}
+	}
+	sortLedgers(out)
+	return out
+}
+
+func sortLedgers(entries []models.LedgerEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Time != entries[j].Time {
+			return entries[i].Time < entries[j].Time
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
+
+// waitRateLimit sleeps out a rate limit error and reports whether the
+// request should be retried. Long histories take many pages, and each one
+// counts against the private API limit.
+func waitRateLimit(ctx context.Context, err error, backoff *Backoff) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(strings.Join(apiErr.Errors, ","), rateLimitError) {
+		return false
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(backoff.Next()):
+		return true
+	}
+}
@@ -1,40 +1,174 @@
 package api
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/umit144/kraken-portfolio/internal/config"
 	"github.com/umit144/kraken-portfolio/internal/models"
-	"github.com/umit144/kraken-portfolio/pkg/utils"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
 
 	"github.com/gorilla/websocket"
 )
 
+// Client is safe for concurrent use. Prices, balances and the registry are
+// owned by the client and only handed out as copies.
 type Client struct {
-	Config     *config.Config
-	WsConn     *websocket.Conn
-	Prices     map[string]float64
-	PrevPrices map[string]float64
-	Balances   map[string]float64
+	Config        *config.Config
+	OnStateChange func(models.ConnectionState)
+	// OnPriceUpdate is called after every price change, outside the lock.
+	OnPriceUpdate func()
+	// OnError is called with API errors the client recovers from on its
+	// own, such as a failed refresh or an error frame. It may be called from
+	// more than one goroutine.
+	OnError func(error)
+
+	mu         sync.RWMutex
+	prices     map[string]decimal.Decimal
+	prevPrices map[string]decimal.Decimal
+	opens      map[string]decimal.Decimal
+	highs      map[string]decimal.Decimal
+	lows       map[string]decimal.Decimal
+	volumes    map[string]decimal.Decimal
+	firsts     map[string]decimal.Decimal
+	buffers    map[string]*priceBuffer
+	balances   map[string]decimal.Decimal
+	registry   *Registry
+	lots       map[string][]models.Lot
+	trades     []models.Trade
+	state      models.ConnectionState
+	quote      string
+
+	openOrders   []models.Order
+	closedOrders []models.Order
+	watched      []string
+
+	httpClient *http.Client
+	dialer     *websocket.Dialer
+	logger     *log.Logger
+	restURL    string
+	wsURL      string
+	privateURL string
+	protocol   protocol
+
+	balanceUpdates chan balanceUpdate
+	orderUpdates   chan struct{}
+	tradesWake     chan struct{}
+	ordersWake     chan struct{}
+	subscribed     map[string]bool
+
+	privateMu sync.Mutex
+	connMu    sync.Mutex
+	wsConn    *websocket.Conn
+	privConn  *websocket.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+
+	now func() time.Time
+}
+
+var (
+	ErrUnknownQuoteCurrency = errors.New("unknown quote currency")
+	ErrUnknownTargetAsset   = errors.New("unknown asset in target allocation")
+	ErrUnknownWatchedAsset  = errors.New("unknown watched asset")
+)
+
+type APIError struct {
+	Errors []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %v", e.Errors)
+}
+
+// HandshakeError is a WebSocket handshake the endpoint answered with an
+// HTTP response instead of switching protocols. It unwraps to
+// websocket.ErrBadHandshake.
+type HandshakeError struct {
+	StatusCode int
+	err        error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("%v: HTTP %d", e.err, e.StatusCode)
 }
 
-func NewClient(cfg *config.Config) *Client {
-	return &Client{
+func (e *HandshakeError) Unwrap() error {
+	return e.err
+}
+
+// Permanent reports whether the endpoint refused the client for good, as
+// opposed to being down or in maintenance.
+func (e *HandshakeError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+func NewClient(cfg *config.Config, opts ...Option) *Client {
+	c := &Client{
 		Config:     cfg,
-		Prices:     make(map[string]float64),
-		PrevPrices: make(map[string]float64),
-		Balances:   make(map[string]float64),
+		prices:     make(map[string]decimal.Decimal),
+		prevPrices: make(map[string]decimal.Decimal),
+		opens:      make(map[string]decimal.Decimal),
+		highs:      make(map[string]decimal.Decimal),
+		lows:       make(map[string]decimal.Decimal),
+		volumes:    make(map[string]decimal.Decimal),
+		firsts:     make(map[string]decimal.Decimal),
+		buffers:    make(map[string]*priceBuffer),
+		balances:   make(map[string]decimal.Decimal),
+		registry:   StaticRegistry(),
+		httpClient: http.DefaultClient,
+		dialer:     websocket.DefaultDialer,
+		logger:     log.Default(),
+		restURL:    cfg.RestURL,
+		wsURL:      cfg.WsURL,
+		privateURL: cfg.PrivateWsURL,
+		protocol:   newProtocol(cfg.WsVersion),
+		quote:      cfg.QuoteCurrency,
+		done:       make(chan struct{}),
+
+		balanceUpdates: make(chan balanceUpdate, 16),
+		orderUpdates:   make(chan struct{}, 1),
+		tradesWake:     make(chan struct{}, 1),
+		ordersWake:     make(chan struct{}, 1),
+
+		now: time.Now,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.restURL == "" {
+		c.restURL = config.DefaultRestURL
+	}
+	if c.wsURL == "" {
+		c.wsURL = config.DefaultWsURLFor(cfg.WsVersion)
+	}
+	if c.privateURL == "" {
+		c.privateURL = config.DefaultPrivateWsURLFor(cfg.WsVersion)
+	}
+	if c.quote == "" {
+		c.quote = config.DefaultQuoteCurrency
+	}
+	c.restURL = strings.TrimSuffix(c.restURL, "/")
+	return c
 }
 
 func (c *Client) GenerateSignature(urlPath, postData, nonce string) string {
@@ -45,20 +179,28 @@ func (c *Client) GenerateSignature(urlPath, postData, nonce string) string {
 	return base64.StdEncoding.EncodeToString(hmac512.Sum(nil))
 }
 
-func (c *Client) GetBalances() error {
+func (c *Client) privatePost(ctx context.Context, method string, params url.Values, out interface{}) error {
+	c.privateMu.Lock()
+	defer c.privateMu.Unlock()
+
+	if params == nil {
+		params = url.Values{}
+	}
 	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
-	data := fmt.Sprintf("nonce=%s", nonce)
+	params.Set("nonce", nonce)
+	data := params.Encode()
+	path := "/0/private/" + method
 
-	req, err := http.NewRequest("POST", "https://api.kraken.com/0/private/Balance", strings.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.restURL+path, strings.NewReader(data))
 	if err != nil {
 		return err
 	}
 
 	req.Header.Add("API-Key", c.Config.ApiKey)
-	req.Header.Add("API-Sign", c.GenerateSignature("/0/private/Balance", data, nonce))
+	req.Header.Add("API-Sign", c.GenerateSignature(path, data, nonce))
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -68,126 +210,567 @@ func (c *Client) GetBalances() error {
 	if err != nil {
 		return err
 	}
+	return json.Unmarshal(body, out)
+}
 
+func (c *Client) fetchBalances(ctx context.Context) (map[string]decimal.Decimal, error) {
 	var balanceResp models.BalanceResponse
-	if err := json.Unmarshal(body, &balanceResp); err != nil {
-		return err
+	if err := c.privatePost(ctx, "Balance", nil, &balanceResp); err != nil {
+		return nil, err
 	}
 
 	if len(balanceResp.Error) > 0 {
-		return fmt.Errorf("API error: %v", balanceResp.Error)
+		return nil, &APIError{Errors: balanceResp.Error}
 	}
 
-	c.Balances = make(map[string]float64)
+	balances := make(map[string]decimal.Decimal)
 	for asset, balStr := range balanceResp.Result {
-		if bal, err := utils.ParseFloat(balStr); err == nil && bal > 0 {
-			c.Balances[asset] = bal
+		if bal, err := decimal.Parse(balStr); err == nil && bal.Sign() > 0 {
+			balances[asset] = bal
 		}
 	}
+	return balances, nil
+}
+
+func (c *Client) GetBalances(ctx context.Context) error {
+	balances, err := c.fetchBalances(ctx)
+	if err != nil {
+		return err
+	}
+	c.SetBalances(balances)
 	return nil
 }
 
-func (c *Client) Connect() error {
-	if err := c.GetBalances(); err != nil {
-		return fmt.Errorf("failed to get balances: %v", err)
+func (c *Client) GetWebSocketsToken(ctx context.Context) (string, error) {
+	var tokenResp models.WebSocketsTokenResponse
+	if err := c.privatePost(ctx, "GetWebSocketsToken", nil, &tokenResp); err != nil {
+		return "", err
 	}
 
-	conn, _, err := websocket.DefaultDialer.Dial("wss://ws.kraken.com", nil)
+	if len(tokenResp.Error) > 0 {
+		return "", &APIError{Errors: tokenResp.Error}
+	}
+	return tokenResp.Result.Token, nil
+}
+
+func (c *Client) publicGet(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.restURL+"/0/public/"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to connect to websocket: %v", err)
+		return err
 	}
-	c.WsConn = conn
+	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+func (c *Client) FetchRegistry(ctx context.Context) (*Registry, error) {
+	var assetsResp models.AssetsResponse
+	if err := c.publicGet(ctx, "Assets", &assetsResp); err != nil {
+		return nil, err
+	}
+	if len(assetsResp.Error) > 0 {
+		return nil, &APIError{Errors: assetsResp.Error}
+	}
+
+	var pairsResp models.AssetPairsResponse
+	if err := c.publicGet(ctx, "AssetPairs", &pairsResp); err != nil {
+		return nil, err
+	}
+	if len(pairsResp.Error) > 0 {
+		return nil, &APIError{Errors: pairsResp.Error}
+	}
+
+	return NewRegistry(assetsResp.Result, pairsResp.Result), nil
+}
+
+func (c *Client) LoadRegistry(ctx context.Context) error {
+	cacheDir := c.Config.CacheDir
+
+	var cached *Registry
+	if cacheDir != "" {
+		if r, err := LoadRegistryCache(cacheDir); err == nil {
+			if !r.Stale() {
+				c.SetRegistry(r)
+				return nil
+			}
+			cached = r
+		}
+	}
+
+	r, err := c.FetchRegistry(ctx)
+	if err != nil {
+		if cached != nil {
+			c.logger.Printf("Using stale asset registry: %v", err)
+			c.SetRegistry(cached)
+			return nil
+		}
+		return err
+	}
+
+	if cacheDir != "" {
+		if err := r.Save(cacheDir); err != nil {
+			c.logger.Printf("Failed to cache asset registry: %v", err)
+		}
+	}
+	c.SetRegistry(r)
+	return nil
+}
+
+func (c *Client) subscriptionPairs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	seen := make(map[string]bool)
 	pairs := make([]string, 0)
-	for asset := range c.Balances {
-		if pair, ok := models.AssetMapping[asset]; ok && pair != "USD" {
-			pairs = append(pairs, pair)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			pairs = append(pairs, name)
+		}
+	}
+	for asset := range c.balances {
+		route, _ := c.registry.RouteFor(asset, c.quote)
+		for _, name := range route.WsNames() {
+			add(name)
+		}
+	}
+	for _, asset := range c.watched {
+		route, _ := c.registry.RouteFor(asset, c.quote)
+		for _, name := range route.WsNames() {
+			add(name)
 		}
 	}
+	// Open orders need their own pair's price for the distance to it.
+	for _, order := range c.openOrders {
+		if pair, ok := c.registry.PairNamed(order.Descr.Pair); ok && pair.WsName != "" {
+			add(pair.WsName)
+		}
+	}
+	return pairs
+}
 
-	if len(pairs) > 0 {
-		msg := map[string]interface{}{
-			"event": "subscribe",
-			"pair":  pairs,
-			"subscription": map[string]interface{}{
-				"name": "ticker",
-			},
+func (c *Client) Connect(ctx context.Context) error {
+	if err := c.LoadRegistry(ctx); err != nil {
+		return fmt.Errorf("failed to load asset registry: %w", err)
+	}
+	if !c.Registry().Known(c.quote) {
+		return fmt.Errorf("%w: %s", ErrUnknownQuoteCurrency, c.quote)
+	}
+	if err := c.CheckTargets(); err != nil {
+		return err
+	}
+	for _, asset := range c.Watched() {
+		if !c.Registry().Known(asset) {
+			return fmt.Errorf("%w: %s", ErrUnknownWatchedAsset, asset)
 		}
-		return c.WsConn.WriteJSON(msg)
 	}
+
+	if err := c.GetBalances(ctx); err != nil {
+		return fmt.Errorf("failed to get balances: %w", err)
+	}
+
+	if err := c.dial(ctx); err != nil {
+		return err
+	}
+	c.setState(models.StateConnected)
 	return nil
 }
 
-func (c *Client) UpdatePrice(pair string, price float64) {
-	c.PrevPrices[pair] = c.Prices[pair]
-	c.Prices[pair] = price
+// CheckTargets reports the first asset in the target allocation the
+// registry does not know.
+func (c *Client) CheckTargets() error {
+	registry := c.Registry()
+	for asset := range c.Config.TargetWeights {
+		if !registry.Known(asset) {
+			return fmt.Errorf("%w: %s", ErrUnknownTargetAsset, asset)
+		}
+	}
+	return nil
 }
 
-func (c *Client) GetPrice(pair string) float64 {
-	return c.Prices[pair]
+func (c *Client) dial(ctx context.Context) error {
+	conn, resp, err := c.dialer.DialContext(ctx, c.wsURL, nil)
+	if err != nil {
+		if resp != nil {
+			err = &HandshakeError{StatusCode: resp.StatusCode, err: err}
+		}
+		return fmt.Errorf("failed to connect to websocket: %w", err)
+	}
+
+	c.connMu.Lock()
+	c.wsConn = conn
+	c.connMu.Unlock()
+
+	c.subscribed = make(map[string]bool)
+	return c.subscribeNewPairs()
+}
+
+func (c *Client) subscribeNewPairs() error {
+	pairs := make([]string, 0)
+	for _, pair := range c.subscriptionPairs() {
+		if !c.subscribed[pair] {
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	conn := c.conn()
+	if conn == nil {
+		return nil
+	}
+	if err := conn.WriteJSON(c.protocol.SubscribeMessage(pairs)); err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		c.subscribed[pair] = true
+	}
+	return nil
+}
+
+func (c *Client) conn() *websocket.Conn {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.wsConn
+}
+
+// Watch streams the prices of assets that are not held, e.g. for alerts.
+// It must be called before Connect.
+func (c *Client) Watch(assets ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watched = append(c.watched, assets...)
+}
+
+func (c *Client) Watched() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.watched...)
+}
+
+// PriceOf is the price of one unit of asset in the quote currency, if every
+// leg of its route is priced.
+func (c *Client) PriceOf(asset string) (decimal.Decimal, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	route, ok := c.registry.RouteFor(asset, c.quote)
+	if !ok {
+		return decimal.Zero, false
+	}
+	price := route.Price(c.prices)
+	return price, price.Sign() > 0
+}
+
+func (c *Client) UpdatePrice(pair string, price decimal.Decimal) {
+	c.mu.Lock()
+	c.setPrice(pair, price)
+	c.mu.Unlock()
+	c.priceUpdated()
+}
+
+// UpdateTicker records the last price along with the 24 hour open, high and
+// low. Fields the frame leaves zero keep their previous values.
+func (c *Client) UpdateTicker(ticker models.Ticker) {
+	c.mu.Lock()
+	defer c.priceUpdated()
+	defer c.mu.Unlock()
+	c.setPrice(ticker.Symbol, ticker.Last)
+	if ticker.Open.Sign() > 0 {
+		c.opens[ticker.Symbol] = ticker.Open
+	}
+	if ticker.High.Sign() > 0 {
+		c.highs[ticker.Symbol] = ticker.High
+	}
+	if ticker.Low.Sign() > 0 {
+		c.lows[ticker.Symbol] = ticker.Low
+	}
+	if ticker.Volume.Sign() > 0 {
+		c.volumes[ticker.Symbol] = ticker.Volume
+	}
+}
+
+func (c *Client) priceUpdated() {
+	if c.OnPriceUpdate != nil {
+		c.OnPriceUpdate()
+	}
+}
+
+func (c *Client) setPrice(pair string, price decimal.Decimal) {
+	c.prevPrices[pair] = c.prices[pair]
+	c.prices[pair] = price
+	if price.Sign() <= 0 {
+		return
+	}
+	if _, ok := c.firsts[pair]; !ok {
+		c.firsts[pair] = price
+	}
+	b, ok := c.buffers[pair]
+	if !ok {
+		b = newPriceBuffer(c.Config.SparklineWindow)
+		c.buffers[pair] = b
+	}
+	b.add(c.now(), price)
+}
+
+func (c *Client) GetPrice(pair string) decimal.Decimal {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.prices[pair]
+}
+
+func (c *Client) GetPrevPrice(pair string) decimal.Decimal {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.prevPrices[pair]
+}
+
+func (c *Client) Prices() map[string]decimal.Decimal {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyDecimals(c.prices)
+}
+
+func (c *Client) Balances() map[string]decimal.Decimal {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyDecimals(c.balances)
+}
+
+func (c *Client) SetBalances(balances map[string]decimal.Decimal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balances = copyDecimals(balances)
 }
 
+func (c *Client) Registry() *Registry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registry
+}
+
+func (c *Client) SetRegistry(r *Registry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registry = r
+}
+
+// QuoteCurrency is the display name of the currency the portfolio is valued
+// in.
+func (c *Client) QuoteCurrency() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registry.DisplayName(c.registry.canonical(c.quote))
+}
+
+// QuoteAsset is the registry key of the quote currency, e.g. ZEUR.
+func (c *Client) QuoteAsset() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registry.canonical(c.quote)
+}
+
+// QuoteDecimals is the precision Kraken displays the quote currency at.
+func (c *Client) QuoteDecimals() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registry.Assets[c.registry.canonical(c.quote)].DisplayDecimals
+}
+
+func (c *Client) State() models.ConnectionState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+func copyDecimals(m map[string]decimal.Decimal) map[string]decimal.Decimal {
+	out := make(map[string]decimal.Decimal, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// GetAssetValues values every holding in the quote currency. Balances of
+// the quote currency itself, including held and staked variants, are merged
+// into a single row priced at one. Amounts reserved by open orders are set
+// on the spot balance they come out of.
 func (c *Client) GetAssetValues() []models.AssetValue {
-	assets := make([]models.AssetValue, 0, len(c.Balances))
-
-	for asset, balance := range c.Balances {
-		if asset == "ZUSD" {
-			assets = append(assets, models.AssetValue{
-				Asset:     "USD",
-				Balance:   balance,
-				Price:     1.0,
-				PrevPrice: 1.0,
-				USDValue:  balance,
-			})
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	assets, _ := c.assetValues()
+	return assets
+}
+
+// Weights sums each asset's share of the portfolio, in percent, by registry
+// key, so staked and held variants such as DOT.S count towards DOT. It also
+// returns the total value; ok is false while some holding is unpriced.
+func (c *Client) Weights() (weights map[string]decimal.Decimal, total decimal.Decimal, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	assets, keys := c.assetValues()
+	weights = make(map[string]decimal.Decimal, len(assets))
+	for i, asset := range assets {
+		if asset.Price.IsZero() {
+			return nil, decimal.Zero, false
+		}
+		weights[keys[i]] = weights[keys[i]].Add(asset.Weight)
+		total = total.Add(asset.Value)
+	}
+	return weights, total, true
+}
+
+// ResolveAsset maps an asset as the user writes it, e.g. BTC, XBT or DOT.S,
+// to the registry key it is priced and weighted under.
+func (c *Client) ResolveAsset(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registry.ResolveFor(name, c.registry.canonical(c.quote))
+}
+
+// assetValues builds the rows of GetAssetValues along with the registry key
+// of each. The caller holds c.mu.
+func (c *Client) assetValues() ([]models.AssetValue, []string) {
+	assets := make([]models.AssetValue, 0, len(c.balances))
+	keys := make([]string, 0, len(c.balances))
+	quote := c.registry.canonical(c.quote)
+	reserved := c.reserved()
+
+	cash, hasCash := decimal.Zero, false
+	for asset, balance := range c.balances {
+		key := c.registry.ResolveFor(asset, quote)
+		if key == quote {
+			cash = cash.Add(balance)
+			hasCash = true
 			continue
 		}
 
-		if pair, ok := models.AssetMapping[asset]; ok {
-			price := c.Prices[pair]
-			prevPrice := c.PrevPrices[pair]
-			assets = append(assets, models.AssetValue{
-				Asset:     strings.TrimPrefix(strings.TrimPrefix(asset, "X"), "Z"),
-				Balance:   balance,
-				Price:     price,
-				PrevPrice: prevPrice,
-				USDValue:  balance * price,
-			})
+		if route, ok := c.registry.RouteFor(asset, quote); ok {
+			price := route.Price(c.prices)
+			value := models.AssetValue{
+				Asset:           c.registry.DisplayName(asset),
+				Balance:         balance,
+				Price:           price,
+				PrevPrice:       route.Price(c.prevPrices),
+				Value:           balance.Mul(price),
+				BalanceDecimals: c.registry.DisplayDecimals(asset),
+				PriceDecimals:   route.Decimals(c.registry, quote),
+			}
+			if asset == key {
+				value.Reserved = reserved[key]
+			}
+			value.Available = balance.Sub(value.Reserved)
+			applyCostBasis(&value, averageCost(c.lots[key]))
+			c.applyChanges(&value, route)
+			assets = append(assets, value)
+			keys = append(keys, key)
 		}
 	}
 
-	return assets
+	if hasCash {
+		assets = append(assets, models.AssetValue{
+			Asset:           c.registry.DisplayName(quote),
+			Balance:         cash,
+			Price:           decimal.One,
+			PrevPrice:       decimal.One,
+			Value:           cash,
+			Reserved:        reserved[quote],
+			Available:       cash.Sub(reserved[quote]),
+			BalanceDecimals: c.registry.Assets[quote].DisplayDecimals,
+		})
+		keys = append(keys, quote)
+	}
+
+	c.applyWeights(assets, keys)
+	return assets, keys
 }
 
-func (c *Client) StartStreaming(renderFunc func([]models.AssetValue)) {
-	for {
-		var message json.RawMessage
-		if err := c.WsConn.ReadJSON(&message); err != nil {
-			log.Printf("WebSocket read error: %v", err)
-			return
-		}
-
-		var data []interface{}
-		if err := json.Unmarshal(message, &data); err == nil && len(data) > 1 {
-			if tickerData, ok := data[1].(map[string]interface{}); ok {
-				if closeData, ok := tickerData["c"].([]interface{}); ok && len(closeData) > 0 {
-					if price, ok := closeData[0].(string); ok {
-						if pairInfo, ok := data[3].(string); ok {
-							if priceVal, err := utils.ParseFloat(price); err == nil {
-								c.UpdatePrice(pairInfo, priceVal)
-								assets := c.GetAssetValues()
-								renderFunc(assets)
-							}
-						}
-					}
-				}
-			}
+// applyWeights sets each row's share of the total and its drift from the
+// configured target. Rows of one asset, such as DOT and DOT.S, are measured
+// against the target together.
+func (c *Client) applyWeights(assets []models.AssetValue, keys []string) {
+	total := decimal.Zero
+	for _, asset := range assets {
+		total = total.Add(asset.Value)
+	}
+	if total.Sign() <= 0 {
+		return
+	}
+
+	targets := make(map[string]decimal.Decimal, len(c.Config.TargetWeights))
+	for asset, weight := range c.Config.TargetWeights {
+		targets[c.registry.canonical(asset)] = weight
+	}
+
+	held := make(map[string]decimal.Decimal)
+	for i := range assets {
+		assets[i].Weight = assets[i].Value.Mul(decimal.NewFromInt(100)).Div(total, pnlPctDecimals)
+		held[keys[i]] = held[keys[i]].Add(assets[i].Weight)
+	}
+	for i := range assets {
+		if target, ok := targets[keys[i]]; ok {
+			assets[i].Target = target
+			assets[i].Drift = held[keys[i]].Sub(target)
+			assets[i].HasTarget = true
 		}
 	}
 }
 
+// applyChanges fills in the 24 hour and session figures. Percentages wait
+// for both ends of the change to be priced.
+func (c *Client) applyChanges(value *models.AssetValue, route Route) {
+	value.Open24h = route.Price(c.opens)
+	value.Low24h, value.High24h = route.Range(c.lows, c.highs)
+	value.SessionOpen = route.Price(c.firsts)
+	value.Change24hPct = percentChange(value.Open24h, value.Price)
+	value.SessionChangePct = percentChange(value.SessionOpen, value.Price)
+	value.Sparkline = c.sparkline(route)
+	if len(route) == 1 {
+		value.Pair = route[0].Pair.WsName
+		if !route[0].Invert {
+			value.Volume24h = c.volumes[value.Pair]
+		}
+	}
+}
+
+func percentChange(from, to decimal.Decimal) decimal.Decimal {
+	if from.IsZero() || to.IsZero() {
+		return decimal.Zero
+	}
+	return to.Sub(from).Mul(decimal.NewFromInt(100)).Div(from, pnlPctDecimals)
+}
+
+// Close stops streaming and closes both WebSockets, sending a normal
+// closure frame first so the server sees a clean disconnect.
 func (c *Client) Close() error {
-	if c.WsConn != nil {
-		return c.WsConn.Close()
+	c.closeOnce.Do(func() { close(c.done) })
+
+	c.connMu.Lock()
+	conn, privConn := c.wsConn, c.privConn
+	c.wsConn, c.privConn = nil, nil
+	c.connMu.Unlock()
+
+	if privConn != nil {
+		closeGracefully(privConn)
+	}
+	if conn != nil {
+		return closeGracefully(conn)
 	}
 	return nil
 }
+
+func closeGracefully(conn *websocket.Conn) error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeTimeout))
+	return conn.Close()
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// OHLCLimit is how many candles Kraken serves of any interval, counting back
+// from now, whatever since asks for. Daily candles reach back about two
+// years.
+const OHLCLimit = 720
+
+// FetchOHLC returns a pair's candles at interval, oldest first, from since
+// on. The pair is named by key or altname.
+func (c *Client) FetchOHLC(ctx context.Context, pair string, interval time.Duration, since time.Time) ([]models.Candle, error) {
+	query := url.Values{
+		"pair":     {pair},
+		"interval": {strconv.Itoa(int(interval / time.Minute))},
+	}
+	if !since.IsZero() {
+		query.Set("since", strconv.FormatInt(since.Unix(), 10))
+	}
+
+	var resp models.OHLCResponse
+	if err := c.publicGet(ctx, "OHLC?"+query.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Error) > 0 {
+		return nil, &APIError{Errors: resp.Error}
+	}
+
+	var candles []models.Candle
+	for key, raw := range resp.Result {
+		if key == "last" {
+			continue
+		}
+		var rows [][]json.RawMessage
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, fmt.Errorf("malformed OHLC for %s: %w", pair, err)
+		}
+		for _, row := range rows {
+			candle, err := parseCandle(row)
+			if err != nil {
+				return nil, fmt.Errorf("malformed OHLC for %s: %w", pair, err)
+			}
+			candles = append(candles, candle)
+		}
+	}
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Time.Before(candles[j].Time) })
+	return candles, nil
+}
+
+func parseCandle(row []json.RawMessage) (models.Candle, error) {
+	if len(row) < 5 {
+		return models.Candle{}, fmt.Errorf("candle has %d fields", len(row))
+	}
+	var seconds int64
+	if err := json.Unmarshal(row[0], &seconds); err != nil {
+		return models.Candle{}, err
+	}
+
+	candle := models.Candle{Time: time.Unix(seconds, 0).UTC()}
+	for i, field := range []*decimal.Decimal{&candle.Open, &candle.High, &candle.Low, &candle.Close} {
+		if err := json.Unmarshal(row[i+1], field); err != nil {
+			return models.Candle{}, err
+		}
+	}
+	return candle, nil
+}
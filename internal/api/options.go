@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *Client) {
+		c.dialer = dialer
+	}
+}
+
+// WithLogger sets where the client logs the errors it recovers from on its
+// own. It defaults to the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+func WithRestURL(url string) Option {
+	return func(c *Client) {
+		c.restURL = url
+	}
+}
+
+func WithWebSocketURL(url string) Option {
+	return func(c *Client) {
+		c.wsURL = url
+	}
+}
+
+func WithPrivateWebSocketURL(url string) Option {
+	return func(c *Client) {
+		c.privateURL = url
+	}
+}
+
+// WithClock sets the clock the sparkline buffers are kept by.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+var ErrTradingDisabled = errors.New("trading is disabled, set KRAKEN_TRADING_ENABLED=true to place or cancel orders")
+
+// AddOrder submits an order, or with req.Validate only has Kraken check it,
+// in which case the result carries a description but no txid. Placing an
+// order needs trading to be enabled in the config.
+func (c *Client) AddOrder(ctx context.Context, req models.OrderRequest) (models.AddOrderResult, error) {
+	if !req.Validate && !c.Config.TradingEnabled {
+		return models.AddOrderResult{}, ErrTradingDisabled
+	}
+
+	params := url.Values{
+		"pair":      {req.Pair},
+		"type":      {req.Type},
+		"ordertype": {req.OrderType},
+		"volume":    {req.Volume.String()},
+	}
+	if req.OrderType == "limit" {
+		params.Set("price", req.Price.String())
+	}
+	if req.Validate {
+		params.Set("validate", strconv.FormatBool(true))
+	}
+
+	var resp models.AddOrderResponse
+	if err := c.privatePost(ctx, "AddOrder", params, &resp); err != nil {
+		return models.AddOrderResult{}, err
+	}
+	if len(resp.Error) > 0 {
+		return models.AddOrderResult{}, &APIError{Errors: resp.Error}
+	}
+	return resp.Result, nil
+}
+
+// CancelOrder cancels an open order by txid and returns how many orders were
+// cancelled.
+func (c *Client) CancelOrder(ctx context.Context, txid string) (int, error) {
+	return c.cancel(ctx, "CancelOrder", url.Values{"txid": {txid}})
+}
+
+// CancelAll cancels every open order and returns how many there were.
+func (c *Client) CancelAll(ctx context.Context) (int, error) {
+	return c.cancel(ctx, "CancelAll", nil)
+}
+
+func (c *Client) cancel(ctx context.Context, method string, params url.Values) (int, error) {
+	if !c.Config.TradingEnabled {
+		return 0, ErrTradingDisabled
+	}
+
+	var resp models.CancelOrderResponse
+	if err := c.privatePost(ctx, method, params, &resp); err != nil {
+		return 0, err
+	}
+	if len(resp.Error) > 0 {
+		return 0, &APIError{Errors: resp.Error}
+	}
+	return resp.Result.Count, nil
+}
+
+// FetchOpenOrders returns the orders still open, oldest first.
+func (c *Client) FetchOpenOrders(ctx context.Context) ([]models.Order, error) {
+	var resp models.OpenOrdersResponse
+	if err := c.privatePost(ctx, "OpenOrders", nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Error) > 0 {
+		return nil, &APIError{Errors: resp.Error}
+	}
+	return sortOrders(resp.Result.Open, false), nil
+}
+
+// FetchClosedOrders returns the most recently closed orders, newest first.
+// Only the first page is fetched, which is all a list of recent fills needs.
+func (c *Client) FetchClosedOrders(ctx context.Context) ([]models.Order, error) {
+	var resp models.ClosedOrdersResponse
+	if err := c.privatePost(ctx, "ClosedOrders", nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Error) > 0 {
+		return nil, &APIError{Errors: resp.Error}
+	}
+	return sortOrders(resp.Result.Closed, true), nil
+}
+
+func sortOrders(byID map[string]models.Order, newestFirst bool) []models.Order {
+	orders := make([]models.Order, 0, len(byID))
+	for id, order := range byID {
+		order.TxID = id
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		ti, tj := orders[i].OpenTm, orders[j].OpenTm
+		if newestFirst {
+			ti, tj = orders[i].CloseTm, orders[j].CloseTm
+		}
+		if ti != tj {
+			return (ti < tj) != newestFirst
+		}
+		return orders[i].TxID < orders[j].TxID
+	})
+	return orders
+}
+
+// LoadOrders fetches open and recently closed orders. Once loaded, open
+// orders count towards reserved balances, their pairs are streamed and the
+// private feed keeps them current. It needs an API key with permission to
+// query open and closed orders.
+func (c *Client) LoadOrders(ctx context.Context) error {
+	open, err := c.FetchOpenOrders(ctx)
+	if err != nil {
+		return err
+	}
+	closed, err := c.FetchClosedOrders(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.openOrders = open
+	c.closedOrders = closed
+	c.mu.Unlock()
+
+	c.notifyOrders()
+	return nil
+}
+
+// refreshOrders reloads orders after the private feed reports a change, if
+// they were loaded at all.
+func (c *Client) refreshOrders(ctx context.Context) {
+	c.mu.RLock()
+	loaded := c.openOrders != nil
+	c.mu.RUnlock()
+	if !loaded {
+		return
+	}
+
+	if err := c.LoadOrders(ctx); err != nil {
+		c.reportError("Failed to refresh orders: %w", err)
+	}
+}
+
+func (c *Client) OpenOrders() []models.Order {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]models.Order(nil), c.openOrders...)
+}
+
+// OpenOrderViews lists open orders with how far their limit price sits from
+// the pair's last price, in percent of it.
+func (c *Client) OpenOrderViews() []models.OrderView {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	views := make([]models.OrderView, 0, len(c.openOrders))
+	for _, order := range c.openOrders {
+		view := c.orderView(order)
+		view.Volume = order.Vol
+		if order.Descr.OrderType != "market" {
+			view.Price = order.Descr.Price
+			view.DistancePct = percentChange(view.Last, view.Price)
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// RecentFills lists up to n of the most recently closed orders that were at
+// least partly filled, at their average fill price.
+func (c *Client) RecentFills(n int) []models.OrderView {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	views := make([]models.OrderView, 0, n)
+	for _, order := range c.closedOrders {
+		if len(views) == n {
+			break
+		}
+		if order.VolExec.Sign() <= 0 {
+			continue
+		}
+		view := c.orderView(order)
+		view.Price = order.Price
+		view.Volume = order.VolExec
+		views = append(views, view)
+	}
+	return views
+}
+
+func (c *Client) orderView(order models.Order) models.OrderView {
+	view := models.OrderView{
+		TxID:      order.TxID,
+		Pair:      order.Descr.Pair,
+		Side:      order.Descr.Type,
+		OrderType: order.Descr.OrderType,
+	}
+	if order.Vol.Sign() > 0 {
+		view.FilledPct = order.VolExec.Mul(decimal.NewFromInt(100)).Div(order.Vol, pnlPctDecimals)
+	}
+	if pair, ok := c.registry.PairNamed(order.Descr.Pair); ok {
+		view.Pair = pair.WsName
+		view.Last = c.prices[pair.WsName]
+		view.PriceDecimals = pair.PairDecimals
+		view.LotDecimals = pair.LotDecimals
+	}
+	return view
+}
+
+// reserved totals what open orders hold back, by registry key: the unfilled
+// volume of sells in the base asset and its cost at the limit price, or the
+// last price for market orders, of buys in the quote asset.
+func (c *Client) reserved() map[string]decimal.Decimal {
+	out := make(map[string]decimal.Decimal)
+	for _, order := range c.openOrders {
+		pair, ok := c.registry.PairNamed(order.Descr.Pair)
+		if !ok {
+			continue
+		}
+		remaining := order.Vol.Sub(order.VolExec)
+		if remaining.Sign() <= 0 {
+			continue
+		}
+
+		switch order.Descr.Type {
+		case "sell":
+			out[pair.Base] = out[pair.Base].Add(remaining)
+		case "buy":
+			price := order.Descr.Price
+			if order.Descr.OrderType == "market" || price.Sign() <= 0 {
+				price = c.prices[pair.WsName]
+			}
+			out[pair.Quote] = out[pair.Quote].Add(remaining.Mul(price))
+		}
+	}
+	return out
+}
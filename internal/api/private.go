@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+type balanceUpdate struct {
+	balances map[string]decimal.Decimal
+	replace  bool
+}
+
+// tokenRefusals are the API errors retrying cannot fix: the key is wrong or
+// lacks WebSocket permission.
+var tokenRefusals = []string{"EGeneral:Permission denied", "EAPI:Invalid key"}
+
+// refreshDelay is how long a refresh waits after the frame that asked for
+// it, so a burst of fills costs one round of REST calls.
+const refreshDelay = 500 * time.Millisecond
+
+// runPrivateFeed keeps an authenticated WebSocket open and forwards balance
+// and order changes to the streaming loop, which owns Client.Balances. It gives up
+// only when the API refuses the key; rate limits and outages are retried.
+func (c *Client) runPrivateFeed(ctx context.Context) {
+	backoff := NewBackoff(minReconnect, maxReconnect)
+	go c.runRefresher(ctx, c.tradesWake, c.refreshCostBasis)
+	go c.runRefresher(ctx, c.ordersWake, c.refreshOrders)
+
+	for {
+		err := c.streamPrivate(ctx, backoff)
+		if c.closed() {
+			return
+		}
+
+		if tokenRefused(err) {
+			c.reportError("Private balance feed disabled: %w", err)
+			return
+		}
+		c.logger.Printf("Private WebSocket error: %v", err)
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(backoff.Next()):
+		}
+	}
+}
+
+func tokenRefused(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return slices.ContainsFunc(apiErr.Errors, func(e string) bool {
+		return slices.Contains(tokenRefusals, e)
+	})
+}
+
+func (c *Client) streamPrivate(ctx context.Context, backoff *Backoff) error {
+	token, err := c.GetWebSocketsToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := c.dialer.DialContext(ctx, c.privateURL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	c.connMu.Lock()
+	c.privConn = conn
+	c.connMu.Unlock()
+	if c.closed() {
+		return nil
+	}
+
+	for _, msg := range c.protocol.PrivateSubscribeMessages(token) {
+		if err := conn.WriteJSON(msg); err != nil {
+			return err
+		}
+	}
+	backoff.Reset()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		event := c.protocol.Parse(message)
+		if event.Err != nil {
+			c.reportError("Private WebSocket error: %w", event.Err)
+		}
+
+		switch {
+		case event.Balances != nil:
+			c.pushBalances(balanceUpdate{
+				balances: c.resolveBalances(event.Balances),
+				replace:  event.BalancesSnapshot,
+			})
+		case event.TradesChanged:
+			balances, err := c.fetchBalances(ctx)
+			if err != nil {
+				c.reportError("Failed to refresh balances: %w", err)
+				continue
+			}
+			c.pushBalances(balanceUpdate{balances: balances, replace: true})
+		}
+		if event.TradesChanged {
+			wake(c.tradesWake)
+		}
+		if event.OrdersChanged {
+			wake(c.ordersWake)
+		}
+	}
+}
+
+// runRefresher calls refresh once per burst of wake-ups, off the private
+// feed's read loop, until the client is closed.
+func (c *Client) runRefresher(ctx context.Context, wakeUps <-chan struct{}, refresh func(context.Context)) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-wakeUps:
+		}
+
+		select {
+		case <-c.done:
+			return
+		case <-time.After(refreshDelay):
+		}
+		// The refresh covers whatever arrived while it waited.
+		select {
+		case <-wakeUps:
+		default:
+		}
+		refresh(ctx)
+	}
+}
+
+// wake signals a refresher without blocking. A wake-up already pending
+// covers this one.
+func wake(wakeUps chan<- struct{}) {
+	select {
+	case wakeUps <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) resolveBalances(altnames map[string]decimal.Decimal) map[string]decimal.Decimal {
+	registry := c.Registry()
+	balances := make(map[string]decimal.Decimal, len(altnames))
+	for name, balance := range altnames {
+		balances[registry.canonical(name)] = balance
+	}
+	return balances
+}
+
+func (c *Client) pushBalances(update balanceUpdate) {
+	select {
+	case c.balanceUpdates <- update:
+	case <-c.done:
+	}
+}
+
+// notifyOrders wakes the streaming loop to subscribe to the pairs of new
+// orders and redraw. A wake-up already pending covers this one.
+func (c *Client) notifyOrders() {
+	select {
+	case c.orderUpdates <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) applyBalances(update balanceUpdate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if update.replace {
+		c.balances = make(map[string]decimal.Decimal, len(update.balances))
+	}
+
+	for asset, balance := range update.balances {
+		if balance.Sign() > 0 {
+			c.balances[asset] = balance
+		} else {
+			delete(c.balances, asset)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+type streamEvent struct {
+	Tickers []models.Ticker
+	Err     error
+
+	// Balances are keyed by asset altname. A snapshot replaces every
+	// holding, an update only the assets it lists.
+	Balances         map[string]decimal.Decimal
+	BalancesSnapshot bool
+	TradesChanged    bool
+	OrdersChanged    bool
+}
+
+// protocol hides the framing differences between WebSocket API versions.
+// Pairs are always exchanged with the client in their AssetPairs wsname form.
+type protocol interface {
+	SubscribeMessage(pairs []string) interface{}
+	PrivateSubscribeMessages(token string) []interface{}
+	Parse(message []byte) streamEvent
+}
+
+func newProtocol(version string) protocol {
+	if version == "v2" {
+		return protocolV2{}
+	}
+	return protocolV1{}
+}
+
+type protocolV1 struct{}
+
+type v1Event struct {
+	Event        string `json:"event"`
+	Status       string `json:"status"`
+	Pair         string `json:"pair"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+type v1Ticker struct {
+	Ask    []interface{} `json:"a"`
+	Bid    []interface{} `json:"b"`
+	Close  []interface{} `json:"c"`
+	Volume []interface{} `json:"v"`
+	VWAP   []interface{} `json:"p"`
+	Low    []interface{} `json:"l"`
+	High   []interface{} `json:"h"`
+	Open   []interface{} `json:"o"`
+}
+
+func (protocolV1) SubscribeMessage(pairs []string) interface{} {
+	return map[string]interface{}{
+		"event": "subscribe",
+		"pair":  pairs,
+		"subscription": map[string]interface{}{
+			"name": "ticker",
+		},
+	}
+}
+
+func (protocolV1) PrivateSubscribeMessages(token string) []interface{} {
+	messages := make([]interface{}, 0, 2)
+	for _, name := range []string{"ownTrades", "openOrders"} {
+		messages = append(messages, map[string]interface{}{
+			"event": "subscribe",
+			"subscription": map[string]interface{}{
+				"name":  name,
+				"token": token,
+			},
+		})
+	}
+	return messages
+}
+
+func (protocolV1) Parse(message []byte) streamEvent {
+	var event v1Event
+	if err := json.Unmarshal(message, &event); err == nil {
+		if event.Event == "error" || (event.Event == "subscriptionStatus" && event.Status == "error") {
+			return streamEvent{Err: fmt.Errorf("subscription error for %s: %s", event.Pair, event.ErrorMessage)}
+		}
+		return streamEvent{}
+	}
+
+	var frame []json.RawMessage
+	if err := json.Unmarshal(message, &frame); err != nil || len(frame) < 3 {
+		return streamEvent{}
+	}
+
+	var channel, pair string
+	if len(frame) == 3 {
+		if json.Unmarshal(frame[1], &channel) != nil {
+			return streamEvent{}
+		}
+		switch channel {
+		case "ownTrades":
+			return streamEvent{TradesChanged: true}
+		case "openOrders":
+			return streamEvent{OrdersChanged: true}
+		}
+		return streamEvent{}
+	}
+
+	if json.Unmarshal(frame[len(frame)-2], &channel) != nil || channel != "ticker" {
+		return streamEvent{}
+	}
+	if json.Unmarshal(frame[len(frame)-1], &pair) != nil {
+		return streamEvent{}
+	}
+
+	var data v1Ticker
+	if err := json.Unmarshal(frame[1], &data); err != nil {
+		return streamEvent{Err: fmt.Errorf("malformed ticker for %s: %v", pair, err)}
+	}
+
+	last, ok := v1Value(data.Close, 0)
+	if !ok {
+		return streamEvent{Err: fmt.Errorf("ticker for %s has no close price", pair)}
+	}
+
+	ticker := models.Ticker{Symbol: pair, Last: last}
+	ticker.Bid, _ = v1Value(data.Bid, 0)
+	ticker.Ask, _ = v1Value(data.Ask, 0)
+	ticker.Volume, _ = v1Value(data.Volume, 1)
+	ticker.VWAP, _ = v1Value(data.VWAP, 1)
+	ticker.Low, _ = v1Value(data.Low, 1)
+	ticker.High, _ = v1Value(data.High, 1)
+	if open, ok := v1Value(data.Open, 1); ok && open.Sign() > 0 {
+		ticker.Open = open
+		ticker.Change = last.Sub(open)
+		ticker.ChangePct = ticker.Change.Mul(decimal.NewFromInt(100)).Div(open, 2)
+	}
+	return streamEvent{Tickers: []models.Ticker{ticker}}
+}
+
+func v1Value(values []interface{}, i int) (decimal.Decimal, bool) {
+	if i >= len(values) {
+		return decimal.Zero, false
+	}
+
+	s, ok := values[i].(string)
+	if !ok {
+		return decimal.Zero, false
+	}
+
+	d, err := decimal.Parse(s)
+	return d, err == nil
+}
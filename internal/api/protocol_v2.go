@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// WebSocket v2 uses BTC and DOGE where AssetPairs wsnames still say XBT and
+// XDG.
+var v2Aliases = map[string]string{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+type protocolV2 struct{}
+
+type v2Message struct {
+	Method  string          `json:"method"`
+	Success *bool           `json:"success"`
+	Error   string          `json:"error"`
+	Channel string          `json:"channel"`
+	Type    string          `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func V2Symbol(wsname string) string {
+	parts := strings.Split(wsname, "/")
+	for i, part := range parts {
+		if alias, ok := v2Aliases[part]; ok {
+			parts[i] = alias
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func v1WsName(symbol string) string {
+	parts := strings.Split(symbol, "/")
+	for i, part := range parts {
+		for v1, v2 := range v2Aliases {
+			if part == v2 {
+				parts[i] = v1
+			}
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (protocolV2) SubscribeMessage(pairs []string) interface{} {
+	symbols := make([]string, len(pairs))
+	for i, pair := range pairs {
+		symbols[i] = V2Symbol(pair)
+	}
+
+	return map[string]interface{}{
+		"method": "subscribe",
+		"params": map[string]interface{}{
+			"channel": "ticker",
+			"symbol":  symbols,
+		},
+	}
+}
+
+type v2Balance struct {
+	Asset   string          `json:"asset"`
+	Balance decimal.Decimal `json:"balance"`
+	Type    string          `json:"type"`
+}
+
+func (protocolV2) PrivateSubscribeMessages(token string) []interface{} {
+	messages := make([]interface{}, 0, 2)
+	for _, channel := range []string{"balances", "executions"} {
+		messages = append(messages, map[string]interface{}{
+			"method": "subscribe",
+			"params": map[string]interface{}{
+				"channel": channel,
+				"token":   token,
+			},
+		})
+	}
+	return messages
+}
+
+func (protocolV2) Parse(message []byte) streamEvent {
+	var msg v2Message
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return streamEvent{Err: fmt.Errorf("malformed message: %v", err)}
+	}
+
+	if msg.Success != nil && !*msg.Success {
+		return streamEvent{Err: fmt.Errorf("%s error: %s", msg.Method, msg.Error)}
+	}
+
+	switch msg.Channel {
+	case "balances":
+		return parseV2Balances(msg)
+	case "executions":
+		return parseV2Executions(msg)
+	}
+	if msg.Channel != "ticker" {
+		return streamEvent{}
+	}
+
+	var tickers []models.Ticker
+	if err := json.Unmarshal(msg.Data, &tickers); err != nil {
+		return streamEvent{Err: fmt.Errorf("malformed ticker: %v", err)}
+	}
+	// A frame without a change says nothing about the open; deriving one
+	// would show a 0% change.
+	var changes []struct {
+		Change *decimal.Decimal `json:"change"`
+	}
+	json.Unmarshal(msg.Data, &changes)
+	for i := range tickers {
+		tickers[i].Symbol = v1WsName(tickers[i].Symbol)
+		if i < len(changes) && changes[i].Change != nil {
+			tickers[i].Open = tickers[i].Last.Sub(tickers[i].Change)
+		}
+	}
+	return streamEvent{Tickers: tickers}
+}
+
+// parseV2Executions reports order changes, and trades when an order was
+// filled, so the cost basis is refreshed too.
+func parseV2Executions(msg v2Message) streamEvent {
+	event := streamEvent{OrdersChanged: true}
+	var data []struct {
+		ExecType string `json:"exec_type"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return event
+	}
+	for _, execution := range data {
+		if execution.ExecType == "trade" {
+			event.TradesChanged = true
+		}
+	}
+	return event
+}
+
+func parseV2Balances(msg v2Message) streamEvent {
+	var data []v2Balance
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return streamEvent{Err: fmt.Errorf("malformed balances: %v", err)}
+	}
+
+	event := streamEvent{
+		Balances:         make(map[string]decimal.Decimal, len(data)),
+		BalancesSnapshot: msg.Type == "snapshot",
+	}
+	for _, balance := range data {
+		event.Balances[v1WsName(balance.Asset)] = balance.Balance
+		if balance.Type == "trade" {
+			event.TradesChanged = true
+		}
+	}
+	return event
+}
@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+const (
+	usdAsset         = "ZUSD"
+	registryFile     = "assets.json"
+	registryCacheTTL = 24 * time.Hour
+)
+
+// Balance keys for staked, opt-in rewards and held funds carry a suffix on
+// top of the underlying asset name (DOT.S, XBT.M, ETH.F, USD.HOLD).
+var assetSuffixes = []string{".HOLD", ".S", ".M", ".F", ".B", ".P"}
+
+// Cross rates are triangulated through the most liquid markets, in order of
+// preference.
+var bridgeAssets = []string{usdAsset, "ZEUR", "XXBT", "USDT"}
+
+type Registry struct {
+	Assets    map[string]models.AssetInfo `json:"assets"`
+	Pairs     map[string]models.AssetPair `json:"pairs"`
+	FetchedAt time.Time                   `json:"fetched_at"`
+
+	altnames map[string]string
+	byQuote  map[string]models.AssetPair
+}
+
+func NewRegistry(assets map[string]models.AssetInfo, pairs map[string]models.AssetPair) *Registry {
+	r := &Registry{
+		Assets:    assets,
+		Pairs:     pairs,
+		FetchedAt: time.Now(),
+	}
+	r.index()
+	return r
+}
+
+func StaticRegistry() *Registry {
+	assets := make(map[string]models.AssetInfo)
+	pairs := make(map[string]models.AssetPair)
+
+	for asset, pair := range models.AssetMapping {
+		altname := strings.TrimPrefix(strings.TrimPrefix(asset, "X"), "Z")
+		if asset == usdAsset {
+			assets[asset] = models.AssetInfo{Altname: altname, Decimals: 4, DisplayDecimals: 2}
+			continue
+		}
+		assets[asset] = models.AssetInfo{Altname: altname, Decimals: 8, DisplayDecimals: 5}
+		pairs[pair] = models.AssetPair{
+			Altname: strings.ReplaceAll(pair, "/", ""),
+			WsName:  pair,
+			Base:    asset,
+			Quote:   usdAsset,
+		}
+	}
+
+	r := NewRegistry(assets, pairs)
+	r.FetchedAt = time.Time{}
+	return r
+}
+
+func (r *Registry) index() {
+	r.altnames = make(map[string]string, len(r.Assets))
+	for key, info := range r.Assets {
+		r.altnames[info.Altname] = key
+	}
+
+	// Several pairs can share a base and quote, e.g. a pair and its ".d"
+	// dark pool variant. Pick the same one every run: the regular pair
+	// first, then the lowest key.
+	keys := make([]string, 0, len(r.Pairs))
+	for key := range r.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	r.byQuote = make(map[string]models.AssetPair, len(r.Pairs))
+	dark := make(map[string]bool, len(r.Pairs))
+	for _, key := range keys {
+		pair := r.Pairs[key]
+		if pair.WsName == "" {
+			continue
+		}
+		id := pair.Base + "|" + pair.Quote
+		isDark := strings.HasSuffix(key, ".d") || strings.HasSuffix(pair.Altname, ".d")
+		if _, ok := r.byQuote[id]; ok && (isDark || !dark[id]) {
+			continue
+		}
+		r.byQuote[id] = pair
+		dark[id] = isDark
+	}
+}
+
+func (r *Registry) canonical(name string) string {
+	if _, ok := r.Assets[name]; ok {
+		return name
+	}
+	if key, ok := r.altnames[name]; ok {
+		return key
+	}
+	for v1, v2 := range v2Aliases {
+		if name == v2 {
+			return r.canonical(v1)
+		}
+	}
+	return name
+}
+
+// Known reports whether the registry lists asset under its key, altname or
+// WebSocket v2 symbol.
+func (r *Registry) Known(asset string) bool {
+	_, ok := r.Assets[r.canonical(asset)]
+	return ok
+}
+
+func candidates(asset string) []string {
+	names := []string{asset}
+
+	base := asset
+	for _, suffix := range assetSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			base = strings.TrimSuffix(base, suffix)
+			names = append(names, base)
+			break
+		}
+	}
+
+	if trimmed := strings.TrimRight(base, "0123456789"); trimmed != "" && trimmed != base {
+		names = append(names, trimmed)
+	}
+	return names
+}
+
+// Resolve maps a balance key to the registry asset it is priced as, walking
+// from the exact name down to the unsuffixed base asset.
+func (r *Registry) Resolve(asset string) string {
+	return r.ResolveFor(asset, usdAsset)
+}
+
+// ResolveFor is Resolve for valuations in quote rather than USD.
+func (r *Registry) ResolveFor(asset, quote string) string {
+	quote = r.canonical(quote)
+	for _, name := range candidates(asset) {
+		key := r.canonical(name)
+		if _, ok := r.routeFrom(key, quote); ok {
+			return key
+		}
+	}
+	return r.canonical(asset)
+}
+
+func (r *Registry) PairFor(asset, quote string) (models.AssetPair, bool) {
+	quote = r.canonical(quote)
+	for _, name := range candidates(asset) {
+		if pair, ok := r.byQuote[r.canonical(name)+"|"+quote]; ok {
+			return pair, true
+		}
+	}
+	return models.AssetPair{}, false
+}
+
+// PairNamed finds a pair by key, altname or wsname, as order descriptions
+// and the WebSocket name them.
+func (r *Registry) PairNamed(name string) (models.AssetPair, bool) {
+	if pair, ok := r.Pairs[name]; ok {
+		return pair, true
+	}
+	for _, pair := range r.Pairs {
+		if pair.Altname == name || pair.WsName == name {
+			return pair, true
+		}
+	}
+	return models.AssetPair{}, false
+}
+
+func (r *Registry) USDPair(asset string) (models.AssetPair, bool) {
+	return r.PairFor(asset, usdAsset)
+}
+
+// RouteFor finds how to price asset in quote: nothing when it is the quote
+// itself, one leg for a direct or inverse pair, or two legs through a bridge
+// currency when Kraken lists no pair between them.
+func (r *Registry) RouteFor(asset, quote string) (Route, bool) {
+	quote = r.canonical(quote)
+	for _, name := range candidates(asset) {
+		if route, ok := r.routeFrom(r.canonical(name), quote); ok {
+			return route, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) routeFrom(asset, quote string) (Route, bool) {
+	if asset == quote {
+		return Route{}, true
+	}
+	if leg, ok := r.leg(asset, quote); ok {
+		return Route{leg}, true
+	}
+	for _, bridge := range bridgeAssets {
+		if bridge == asset || bridge == quote {
+			continue
+		}
+		first, ok := r.leg(asset, bridge)
+		if !ok {
+			continue
+		}
+		if second, ok := r.leg(bridge, quote); ok {
+			return Route{first, second}, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Registry) leg(from, to string) (Leg, bool) {
+	if pair, ok := r.byQuote[from+"|"+to]; ok {
+		return Leg{Pair: pair}, true
+	}
+	if pair, ok := r.byQuote[to+"|"+from]; ok {
+		return Leg{Pair: pair, Invert: true}, true
+	}
+	return Leg{}, false
+}
+
+func (r *Registry) DisplayName(asset string) string {
+	if info, ok := r.Assets[asset]; ok && info.Altname != "" {
+		return info.Altname
+	}
+	if len(asset) == 4 && (asset[0] == 'X' || asset[0] == 'Z') {
+		return asset[1:]
+	}
+	return asset
+}
+
+// DisplayDecimals is the number of decimals Kraken shows for the asset, or 0
+// when the registry does not know it.
+func (r *Registry) DisplayDecimals(asset string) int {
+	if info, ok := r.Assets[asset]; ok {
+		return info.DisplayDecimals
+	}
+	if info, ok := r.Assets[r.Resolve(asset)]; ok {
+		return info.DisplayDecimals
+	}
+	return 0
+}
+
+func (r *Registry) Stale() bool {
+	return time.Since(r.FetchedAt) > registryCacheTTL
+}
+
+func LoadRegistryCache(dir string) (*Registry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, registryFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var r Registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	r.index()
+	return &r, nil
+}
+
+func (r *Registry) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, registryFile), data, 0o644)
+}
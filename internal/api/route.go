@@ -0,0 +1,76 @@
+package api
+
+import (
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// Inverted and triangulated rates are rounded to this many decimals, well
+// beyond any pair or asset precision Kraken publishes.
+const routeDecimals = 18
+
+// Leg prices one hop of a Route. Invert is set when the pair is quoted the
+// other way round, e.g. EUR/USD used to convert USD into EUR.
+type Leg struct {
+	Pair   models.AssetPair
+	Invert bool
+}
+
+// Route is the chain of pairs that converts an asset into the quote
+// currency. An empty route means the asset is the quote currency.
+type Route []Leg
+
+// Price multiplies the legs' prices together. It returns zero until every
+// leg has a price.
+func (r Route) Price(prices map[string]decimal.Decimal) decimal.Decimal {
+	price := decimal.One
+	for _, leg := range r {
+		p := prices[leg.Pair.WsName]
+		if p.IsZero() {
+			return decimal.Zero
+		}
+		if leg.Invert {
+			p = decimal.One.Div(p, routeDecimals)
+		}
+		price = price.Mul(p)
+	}
+	if len(r) > 1 {
+		price = price.Round(routeDecimals)
+	}
+	return price
+}
+
+// Range converts the 24 hour low and high. It is only known for a single
+// pair, since the extremes of several pairs need not coincide, and is zero
+// otherwise. An inverted pair's high is the route's low.
+func (r Route) Range(lows, highs map[string]decimal.Decimal) (low, high decimal.Decimal) {
+	if len(r) != 1 {
+		return decimal.Zero, decimal.Zero
+	}
+	leg := r[0]
+	low, high = lows[leg.Pair.WsName], highs[leg.Pair.WsName]
+	if low.IsZero() || high.IsZero() {
+		return decimal.Zero, decimal.Zero
+	}
+	if leg.Invert {
+		return decimal.One.Div(high, routeDecimals), decimal.One.Div(low, routeDecimals)
+	}
+	return low, high
+}
+
+// Decimals is the precision prices along the route are shown at: the pair's
+// own for a direct quote, otherwise the quote asset's.
+func (r Route) Decimals(registry *Registry, quote string) int {
+	if len(r) == 1 && !r[0].Invert {
+		return r[0].Pair.PairDecimals
+	}
+	return registry.Assets[quote].Decimals
+}
+
+func (r Route) WsNames() []string {
+	names := make([]string, 0, len(r))
+	for _, leg := range r {
+		names = append(names, leg.Pair.WsName)
+	}
+	return names
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// SparklineBuckets is how many prices a pair's buffer holds over the
+// sparkline window, the widest trend the display can draw.
+const SparklineBuckets = 60
+
+// priceBuffer keeps the last price of each step-long bucket over a rolling
+// window, oldest first. last is the start of the newest bucket.
+type priceBuffer struct {
+	step   time.Duration
+	last   time.Time
+	prices []decimal.Decimal
+}
+
+func newPriceBuffer(window time.Duration) *priceBuffer {
+	if window <= 0 {
+		window = config.DefaultSparklineWindow
+	}
+	return &priceBuffer{step: window / SparklineBuckets}
+}
+
+// add records price at. A quiet stretch keeps the price from before it, and
+// a price in the newest bucket, or an earlier one, replaces the newest.
+func (b *priceBuffer) add(at time.Time, price decimal.Decimal) {
+	bucket := at.Truncate(b.step)
+	switch {
+	case len(b.prices) == 0:
+		b.prices = append(b.prices, price)
+		b.last = bucket
+	case bucket.After(b.last):
+		gap := int(bucket.Sub(b.last)/b.step) - 1
+		if gap > SparklineBuckets {
+			gap = SparklineBuckets
+		}
+		newest := b.prices[len(b.prices)-1]
+		for i := 0; i < gap; i++ {
+			b.prices = append(b.prices, newest)
+		}
+		b.prices = append(b.prices, price)
+		b.last = bucket
+	default:
+		b.prices[len(b.prices)-1] = price
+	}
+	if len(b.prices) > SparklineBuckets {
+		b.prices = b.prices[len(b.prices)-SparklineBuckets:]
+	}
+}
+
+// series is the buffer as of now in SparklineBuckets buckets, the last one
+// holding now. Buckets before the first price are zero, so the series of
+// different pairs line up bucket by bucket.
+func (b *priceBuffer) series(now time.Time) []decimal.Decimal {
+	out := make([]decimal.Decimal, SparklineBuckets)
+	if len(b.prices) == 0 {
+		return out
+	}
+
+	// The buckets since the newest price still hold it.
+	end := SparklineBuckets - 1
+	if ahead := int(now.Truncate(b.step).Sub(b.last) / b.step); ahead > 0 {
+		end -= ahead
+	}
+	newest := b.prices[len(b.prices)-1]
+	for i := SparklineBuckets - 1; i > end && i >= 0; i-- {
+		out[i] = newest
+	}
+	for i, j := end, len(b.prices)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		out[i] = b.prices[j]
+	}
+	return out
+}
+
+// sparkline prices the route bucket by bucket from its pairs' buffers. It
+// is nil until every leg has been priced.
+func (c *Client) sparkline(route Route) []decimal.Decimal {
+	now := c.now()
+	series := make(map[string][]decimal.Decimal, len(route))
+	for _, leg := range route {
+		b, ok := c.buffers[leg.Pair.WsName]
+		if !ok {
+			return nil
+		}
+		series[leg.Pair.WsName] = b.series(now)
+	}
+
+	out := make([]decimal.Decimal, SparklineBuckets)
+	prices := make(map[string]decimal.Decimal, len(route))
+	for i := range out {
+		for name, s := range series {
+			prices[name] = s[i]
+		}
+		out[i] = route.Price(prices)
+	}
+	return out
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	staleAfter     = 10 * time.Second
+	deadAfter      = 30 * time.Second
+	minReconnect   = time.Second
+	maxReconnect   = time.Minute
+	watchdogPeriod = time.Second
+	closeTimeout   = time.Second
+)
+
+// StartStreaming runs until ctx is cancelled or Close is called, closing the
+// client on the way out. Dropped or silent connections are re-dialled with
+// backoff, balances refreshed and ticker subscriptions replayed; state
+// transitions are reported through OnStateChange. It returns ctx.Err() on
+// cancellation, nil after Close, and a *HandshakeError if the endpoint
+// refuses the handshake with 401, 403 or 404. Other statuses, such as a 503
+// during maintenance, are retried.
+func (c *Client) StartStreaming(ctx context.Context, renderFunc func([]models.AssetValue)) error {
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-c.done:
+		}
+	}()
+
+	backoff := NewBackoff(minReconnect, maxReconnect)
+	if c.Config.PrivateFeed {
+		go c.runPrivateFeed(ctx)
+	}
+
+	for {
+		err := c.stream(renderFunc)
+		if c.closed() {
+			return ctx.Err()
+		}
+		c.logger.Printf("WebSocket read error: %v", err)
+
+		c.setState(models.StateReconnecting)
+		if err := c.reconnect(ctx, backoff); err != nil {
+			c.Close()
+			return err
+		}
+		if c.closed() {
+			return ctx.Err()
+		}
+		c.setState(models.StateConnected)
+		renderFunc(c.GetAssetValues())
+	}
+}
+
+func (c *Client) stream(renderFunc func([]models.AssetValue)) error {
+	conn := c.conn()
+	if conn == nil {
+		return nil
+	}
+
+	messages := make(chan json.RawMessage)
+	errs := make(chan error, 1)
+	go c.readMessages(conn, messages, errs)
+
+	watchdog := time.NewTicker(watchdogPeriod)
+	defer watchdog.Stop()
+
+	lastMessage := time.Now()
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case err := <-errs:
+			conn.Close()
+			return err
+		case message := <-messages:
+			lastMessage = time.Now()
+			if c.State() == models.StateStale {
+				c.setState(models.StateConnected)
+			}
+			c.handleMessage(message, renderFunc)
+		case update := <-c.balanceUpdates:
+			c.applyBalances(update)
+			if err := c.subscribeNewPairs(); err != nil {
+				c.logger.Printf("Failed to subscribe to new pairs: %v", err)
+			}
+			renderFunc(c.GetAssetValues())
+		case <-c.orderUpdates:
+			if err := c.subscribeNewPairs(); err != nil {
+				c.logger.Printf("Failed to subscribe to new pairs: %v", err)
+			}
+			renderFunc(c.GetAssetValues())
+		case <-watchdog.C:
+			silence := time.Since(lastMessage)
+			if silence > deadAfter {
+				conn.Close()
+				return fmt.Errorf("no data received for %v", silence.Round(time.Second))
+			}
+			if silence > staleAfter && c.State() == models.StateConnected {
+				c.setState(models.StateStale)
+			}
+		}
+	}
+}
+
+func (c *Client) readMessages(conn *websocket.Conn, messages chan<- json.RawMessage, errs chan<- error) {
+	for {
+		var message json.RawMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			errs <- err
+			return
+		}
+
+		select {
+		case messages <- message:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) handleMessage(message json.RawMessage, renderFunc func([]models.AssetValue)) {
+	event := c.protocol.Parse(message)
+	if event.Err != nil {
+		c.reportError("WebSocket error: %w", event.Err)
+	}
+	if len(event.Tickers) == 0 {
+		return
+	}
+
+	for _, ticker := range event.Tickers {
+		c.UpdateTicker(ticker)
+	}
+	renderFunc(c.GetAssetValues())
+}
+
+func (c *Client) reconnect(ctx context.Context, backoff *Backoff) error {
+	for {
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(backoff.Next()):
+		}
+
+		if err := c.GetBalances(ctx); err != nil {
+			c.reportError("Failed to refresh balances: %w", err)
+		}
+
+		err := c.dial(ctx)
+		var handshakeErr *HandshakeError
+		if errors.As(err, &handshakeErr) && handshakeErr.Permanent() {
+			return err
+		}
+		if err != nil {
+			c.logger.Printf("Reconnect failed: %v", err)
+			continue
+		}
+
+		if c.closed() {
+			c.Close()
+			return nil
+		}
+		backoff.Reset()
+		return nil
+	}
+}
+
+// reportError logs a failed API call or an error frame and hands it to
+// OnError.
+func (c *Client) reportError(format string, err error) {
+	err = fmt.Errorf(format, err)
+	c.logger.Print(err)
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+func (c *Client) setState(state models.ConnectionState) {
+	c.mu.Lock()
+	if c.state == state {
+		c.mu.Unlock()
+		return
+	}
+	c.state = state
+	c.mu.Unlock()
+
+	if c.OnStateChange != nil {
+		c.OnStateChange(state)
+	}
+}
+
+func (c *Client) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
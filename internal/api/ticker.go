@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// FetchPrices loads current prices over REST for every pair needed to value
+// the balances and the target allocation, for commands that do not stream.
+func (c *Client) FetchPrices(ctx context.Context) error {
+	registry := c.Registry()
+	byWsName := make(map[string]string)
+	for key, pair := range registry.Pairs {
+		byWsName[pair.WsName] = key
+	}
+
+	assets := make([]string, 0)
+	for asset := range c.Balances() {
+		assets = append(assets, asset)
+	}
+	for asset := range c.Config.TargetWeights {
+		assets = append(assets, asset)
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, asset := range assets {
+		route, _ := registry.RouteFor(asset, c.quote)
+		for _, name := range route.WsNames() {
+			if key, ok := byWsName[name]; ok && !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var resp models.TickerResponse
+	query := url.Values{"pair": {strings.Join(keys, ",")}}
+	if err := c.publicGet(ctx, "Ticker?"+query.Encode(), &resp); err != nil {
+		return err
+	}
+	if len(resp.Error) > 0 {
+		return &APIError{Errors: resp.Error}
+	}
+
+	for key, info := range resp.Result {
+		pair, ok := registry.Pairs[key]
+		if !ok || len(info.Close) == 0 {
+			continue
+		}
+		last, err := decimal.Parse(info.Close[0])
+		if err != nil {
+			continue
+		}
+		ticker := models.Ticker{Symbol: pair.WsName, Last: last}
+		ticker.Open, _ = decimal.Parse(info.Open)
+		if len(info.Low) > 1 {
+			ticker.Low, _ = decimal.Parse(info.Low[1])
+		}
+		if len(info.High) > 1 {
+			ticker.High, _ = decimal.Parse(info.High[1])
+		}
+		c.UpdateTicker(ticker)
+	}
+	return nil
+}
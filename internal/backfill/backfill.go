@@ -0,0 +1,177 @@
+package backfill
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+const day = 24 * time.Hour
+
+type Options struct {
+	Registry *api.Registry
+	// Quote is the registry key of the currency snapshots are valued in,
+	// e.g. ZUSD.
+	Quote string
+	// End is the day the backfill stops before; it is left to the live
+	// tracker.
+	End time.Time
+}
+
+// Closes are daily closing prices by pair wsname and UTC day.
+type Closes map[string]map[time.Time]decimal.Decimal
+
+// Add records daily candles for a pair.
+func (c Closes) Add(wsname string, candles []models.Candle) {
+	if c[wsname] == nil {
+		c[wsname] = make(map[time.Time]decimal.Decimal, len(candles))
+	}
+	for _, candle := range candles {
+		c[wsname][candle.Time.UTC().Truncate(day)] = candle.Close
+	}
+}
+
+// Pairs lists the pairs whose daily closes price every asset in the ledger,
+// ordered by wsname.
+func Pairs(entries []models.LedgerEntry, opts Options) []models.AssetPair {
+	seen := make(map[string]bool)
+	var pairs []models.AssetPair
+	for _, e := range entries {
+		if seen[e.Asset] {
+			continue
+		}
+		seen[e.Asset] = true
+		route, _ := opts.Registry.RouteFor(e.Asset, opts.Quote)
+		for _, leg := range route {
+			if !seen["pair:"+leg.Pair.WsName] {
+				seen["pair:"+leg.Pair.WsName] = true
+				pairs = append(pairs, leg.Pair)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].WsName < pairs[j].WsName })
+	return pairs
+}
+
+// Build replays the ledger into each asset's balance at the end of every
+// UTC day and values it at that day's closes, as the live tracker would
+// have. It returns one snapshot per day from the first entry up to the day
+// before End. A day on which a held asset has no close is skipped rather
+// than stored short; assets Kraken cannot price at all are left out. Both
+// are explained in the warnings.
+func Build(entries []models.LedgerEntry, closes Closes, opts Options) ([]snapshot.Snapshot, []string) {
+	entries = append([]models.LedgerEntry(nil), entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time < entries[j].Time })
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	currency := opts.Registry.DisplayName(opts.Quote)
+	end := opts.End.UTC().Truncate(day)
+
+	balances := make(map[string]decimal.Decimal)
+	unpriced := make(map[string]bool)
+	missing := make(map[string]int)
+	var snaps []snapshot.Snapshot
+
+	next := 0
+	for d := ledgerTime(entries[0].Time).Truncate(day); d.Before(end); d = d.Add(day) {
+		for next < len(entries) && ledgerTime(entries[next].Time).Before(d.Add(day)) {
+			e := entries[next]
+			balances[e.Asset] = balances[e.Asset].Add(e.Amount).Sub(e.Fee)
+			next++
+		}
+
+		snap, short := value(balances, closes, d, opts, unpriced)
+		if short != "" {
+			missing[short]++
+			continue
+		}
+		if len(snap.Holdings) == 0 {
+			continue
+		}
+		snap.Currency = currency
+		snaps = append(snaps, snap)
+	}
+
+	var warnings []string
+	for asset := range unpriced {
+		warnings = append(warnings, fmt.Sprintf("%s has no price in %s and was left out", asset, currency))
+	}
+	for asset, days := range missing {
+		warnings = append(warnings, fmt.Sprintf("%d days were skipped because %s had no daily close", days, asset))
+	}
+	sort.Strings(warnings)
+	return snaps, warnings
+}
+
+// value prices the balances at day's closes. It returns the name of a held
+// asset with no close that day instead, if there is one.
+func value(balances map[string]decimal.Decimal, closes Closes, d time.Time, opts Options, unpriced map[string]bool) (snapshot.Snapshot, string) {
+	registry, quote := opts.Registry, opts.Quote
+
+	assets := make([]string, 0, len(balances))
+	for asset, balance := range balances {
+		if balance.Sign() > 0 {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+
+	snap := snapshot.Snapshot{Time: d}
+	cash, hasCash := decimal.Zero, false
+	for _, asset := range assets {
+		balance := balances[asset]
+		if registry.ResolveFor(asset, quote) == quote {
+			cash = cash.Add(balance)
+			hasCash = true
+			continue
+		}
+		route, ok := registry.RouteFor(asset, quote)
+		if !ok {
+			unpriced[registry.DisplayName(asset)] = true
+			continue
+		}
+
+		prices := make(map[string]decimal.Decimal, len(route))
+		for _, leg := range route {
+			prices[leg.Pair.WsName] = closes[leg.Pair.WsName][d]
+		}
+		price := route.Price(prices)
+		if price.IsZero() {
+			return snapshot.Snapshot{}, registry.DisplayName(asset)
+		}
+		snap.Holdings = append(snap.Holdings, snapshot.Holding{
+			Asset:   registry.DisplayName(asset),
+			Balance: balance,
+			Price:   price,
+			Value:   balance.Mul(price),
+		})
+	}
+	if hasCash {
+		snap.Holdings = append(snap.Holdings, snapshot.Holding{
+			Asset:   registry.DisplayName(quote),
+			Balance: cash,
+			Price:   decimal.One,
+			Value:   cash,
+		})
+	}
+
+	sort.Slice(snap.Holdings, func(i, j int) bool { return snap.Holdings[i].Asset < snap.Holdings[j].Asset })
+	for _, h := range snap.Holdings {
+		snap.Total = snap.Total.Add(h.Value)
+	}
+	snap.Open, snap.High, snap.Low = snap.Total, snap.Total, snap.Total
+	return snap, ""
+}
+
+func ledgerTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
@@ -0,0 +1,31 @@
+package backfill
+
+import (
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// Prices values an asset at the daily close of the UTC day asked about,
+// through the same route the tracker prices it by. It is an
+// accounting.PriceSource for the gains of a ledger the closes were fetched
+// for with Pairs.
+type Prices struct {
+	Closes Closes
+	Opts   Options
+}
+
+func (p Prices) PriceAt(asset string, at time.Time) (decimal.Decimal, bool) {
+	route, ok := p.Opts.Registry.RouteFor(asset, p.Opts.Quote)
+	if !ok {
+		return decimal.Zero, false
+	}
+
+	d := at.UTC().Truncate(day)
+	prices := make(map[string]decimal.Decimal, len(route))
+	for _, leg := range route {
+		prices[leg.Pair.WsName] = p.Closes[leg.Pair.WsName][d]
+	}
+	price := route.Price(prices)
+	return price, !price.IsZero()
+}
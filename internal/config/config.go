@@ -3,6 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
 
 	"github.com/joho/godotenv"
 )
@@ -10,6 +16,101 @@ import (
 type Config struct {
 	ApiKey    string
 	ApiSecret string
+	CacheDir  string
+	RestURL   string
+	WsURL     string
+	WsVersion string
+
+	PrivateWsURL string
+	PrivateFeed  bool
+
+	QuoteCurrency string
+
+	// TargetWeights maps asset names, as the user wrote them, to target
+	// allocations in percent. DriftTolerance is how many percentage points
+	// an asset may stray from its target before it is flagged.
+	TargetWeights  map[string]decimal.Decimal
+	DriftTolerance decimal.Decimal
+
+	// TradingEnabled must be set before the client will place or cancel
+	// orders. Validation-only requests are always allowed.
+	TradingEnabled bool
+
+	// AlertRules are the rules checked on every price update, as written,
+	// e.g. "BTC>70000,SOL~5%/1h". A rule that fired waits for its value to
+	// fall back by AlertHysteresis percent of the threshold, and at least
+	// AlertCooldown, before it can fire again.
+	AlertRules      string
+	AlertHysteresis decimal.Decimal
+	AlertCooldown   time.Duration
+
+	// Alerts and connection events are posted to WebhookURL, signed with
+	// WebhookSecret, and mailed through SMTPAddr when they are set.
+	WebhookURL    string
+	WebhookSecret string
+	SMTPAddr      string
+	SMTPFrom      string
+	SMTPTo        []string
+	SMTPUsername  string
+	SMTPPassword  string
+
+	// SnapshotDir is where portfolio snapshots are kept, taken every
+	// SnapshotInterval. An empty dir turns them off.
+	SnapshotDir      string
+	SnapshotInterval time.Duration
+
+	// PerformancePeriod is how far back the display measures returns, e.g.
+	// 30d or ytd, lowercased but otherwise as written. RiskFreeRate is the annual rate in percent the Sharpe
+	// ratio is measured against.
+	PerformancePeriod string
+	RiskFreeRate      decimal.Decimal
+
+	// SparklineWindow is how much price history the display's trend
+	// column covers.
+	SparklineWindow time.Duration
+}
+
+const (
+	DefaultRestURL = "https://api.kraken.com"
+	DefaultWsURL   = "wss://ws.kraken.com"
+	DefaultWsV2URL = "wss://ws.kraken.com/v2"
+
+	DefaultPrivateWsURL   = "wss://ws-auth.kraken.com"
+	DefaultPrivateWsV2URL = "wss://ws-auth.kraken.com/v2"
+
+	DefaultQuoteCurrency = "USD"
+
+	DefaultAlertCooldown = 15 * time.Minute
+
+	DefaultSnapshotInterval = time.Minute
+
+	DefaultPerformancePeriod = "30d"
+
+	DefaultSparklineWindow = 30 * time.Minute
+)
+
+var (
+	DefaultDriftTolerance  = decimal.NewFromInt(5)
+	DefaultAlertHysteresis = decimal.MustParse("0.5")
+)
+
+var (
+	ErrInvalidWsVersion = fmt.Errorf("KRAKEN_WS_VERSION must be v1 or v2")
+	ErrIncompleteSMTP   = fmt.Errorf("KRAKEN_SMTP_ADDR needs KRAKEN_SMTP_FROM and KRAKEN_SMTP_TO")
+)
+
+func DefaultWsURLFor(version string) string {
+	if version == "v2" {
+		return DefaultWsV2URL
+	}
+	return DefaultWsURL
+}
+
+func DefaultPrivateWsURLFor(version string) string {
+	if version == "v2" {
+		return DefaultPrivateWsV2URL
+	}
+	return DefaultPrivateWsURL
 }
 
 var (
@@ -37,9 +138,45 @@ func New(apiKey, apiSecret string) (*Config, error) {
 	return &Config{
 		ApiKey:    apiKey,
 		ApiSecret: apiSecret,
+		CacheDir:  defaultCacheDir(),
+		RestURL:   DefaultRestURL,
+		WsURL:     DefaultWsURL,
+		WsVersion: "v1",
+
+		PrivateWsURL: DefaultPrivateWsURL,
+		PrivateFeed:  true,
+
+		QuoteCurrency:  DefaultQuoteCurrency,
+		DriftTolerance: DefaultDriftTolerance,
+
+		AlertHysteresis: DefaultAlertHysteresis,
+		AlertCooldown:   DefaultAlertCooldown,
+
+		SnapshotDir:      defaultSnapshotDir(),
+		SnapshotInterval: DefaultSnapshotInterval,
+
+		PerformancePeriod: DefaultPerformancePeriod,
+
+		SparklineWindow: DefaultSparklineWindow,
 	}, nil
 }
 
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "kraken-portfolio")
+}
+
+func defaultSnapshotDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "kraken-portfolio", "snapshots")
+}
+
 func LoadConfig(envPath string) (*Config, error) {
 	if err := LoadEnv(envPath); err != nil {
 		return nil, err
@@ -48,7 +185,145 @@ func LoadConfig(envPath string) (*Config, error) {
 	apiKey := os.Getenv("KRAKEN_API_KEY")
 	apiSecret := os.Getenv("KRAKEN_API_SECRET")
 
-	return New(apiKey, apiSecret)
+	cfg, err := New(apiKey, apiSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheDir, ok := os.LookupEnv("KRAKEN_CACHE_DIR"); ok {
+		cfg.CacheDir = cacheDir
+	}
+	if restURL := os.Getenv("KRAKEN_REST_URL"); restURL != "" {
+		cfg.RestURL = restURL
+	}
+	if wsVersion := os.Getenv("KRAKEN_WS_VERSION"); wsVersion != "" {
+		cfg.WsVersion = wsVersion
+		cfg.WsURL = DefaultWsURLFor(wsVersion)
+		cfg.PrivateWsURL = DefaultPrivateWsURLFor(wsVersion)
+	}
+	if wsURL := os.Getenv("KRAKEN_WS_URL"); wsURL != "" {
+		cfg.WsURL = wsURL
+	}
+	if privateWsURL := os.Getenv("KRAKEN_PRIVATE_WS_URL"); privateWsURL != "" {
+		cfg.PrivateWsURL = privateWsURL
+	}
+	if privateFeed := os.Getenv("KRAKEN_PRIVATE_FEED"); privateFeed != "" {
+		enabled, err := strconv.ParseBool(privateFeed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KRAKEN_PRIVATE_FEED: %w", err)
+		}
+		cfg.PrivateFeed = enabled
+	}
+	if trading := os.Getenv("KRAKEN_TRADING_ENABLED"); trading != "" {
+		enabled, err := strconv.ParseBool(trading)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KRAKEN_TRADING_ENABLED: %w", err)
+		}
+		cfg.TradingEnabled = enabled
+	}
+	if quote := os.Getenv("KRAKEN_QUOTE_CURRENCY"); quote != "" {
+		cfg.QuoteCurrency = strings.ToUpper(strings.TrimSpace(quote))
+	}
+	if targets := os.Getenv("KRAKEN_TARGET_ALLOCATION"); targets != "" {
+		weights, err := ParseTargetWeights(targets)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KRAKEN_TARGET_ALLOCATION: %w", err)
+		}
+		cfg.TargetWeights = weights
+	}
+	if tolerance := os.Getenv("KRAKEN_DRIFT_TOLERANCE"); tolerance != "" {
+		d, err := decimal.Parse(strings.TrimSpace(tolerance))
+		if err != nil || d.Sign() < 0 {
+			return nil, fmt.Errorf("invalid KRAKEN_DRIFT_TOLERANCE: %q", tolerance)
+		}
+		cfg.DriftTolerance = d
+	}
+	cfg.AlertRules = os.Getenv("KRAKEN_ALERTS")
+	if hysteresis := os.Getenv("KRAKEN_ALERT_HYSTERESIS"); hysteresis != "" {
+		d, err := decimal.Parse(strings.TrimSuffix(strings.TrimSpace(hysteresis), "%"))
+		if err != nil || d.Sign() < 0 {
+			return nil, fmt.Errorf("invalid KRAKEN_ALERT_HYSTERESIS: %q", hysteresis)
+		}
+		cfg.AlertHysteresis = d
+	}
+	if cooldown := os.Getenv("KRAKEN_ALERT_COOLDOWN"); cooldown != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(cooldown))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid KRAKEN_ALERT_COOLDOWN: %q", cooldown)
+		}
+		cfg.AlertCooldown = d
+	}
+	cfg.WebhookURL = os.Getenv("KRAKEN_WEBHOOK_URL")
+	cfg.WebhookSecret = os.Getenv("KRAKEN_WEBHOOK_SECRET")
+	cfg.SMTPAddr = os.Getenv("KRAKEN_SMTP_ADDR")
+	cfg.SMTPFrom = os.Getenv("KRAKEN_SMTP_FROM")
+	cfg.SMTPUsername = os.Getenv("KRAKEN_SMTP_USERNAME")
+	cfg.SMTPPassword = os.Getenv("KRAKEN_SMTP_PASSWORD")
+	for _, to := range strings.Split(os.Getenv("KRAKEN_SMTP_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			cfg.SMTPTo = append(cfg.SMTPTo, to)
+		}
+	}
+	if snapshotDir, ok := os.LookupEnv("KRAKEN_SNAPSHOT_DIR"); ok {
+		cfg.SnapshotDir = snapshotDir
+	}
+	if interval := os.Getenv("KRAKEN_SNAPSHOT_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(interval))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KRAKEN_SNAPSHOT_INTERVAL: %q", interval)
+		}
+		cfg.SnapshotInterval = d
+	}
+	if window := os.Getenv("KRAKEN_SPARKLINE_WINDOW"); window != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(window))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid KRAKEN_SPARKLINE_WINDOW: %q", window)
+		}
+		cfg.SparklineWindow = d
+	}
+	if period := os.Getenv("KRAKEN_PERFORMANCE_PERIOD"); period != "" {
+		cfg.PerformancePeriod = strings.ToLower(strings.TrimSpace(period))
+	}
+	if rate := os.Getenv("KRAKEN_RISK_FREE_RATE"); rate != "" {
+		d, err := decimal.Parse(strings.TrimSuffix(strings.TrimSpace(rate), "%"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid KRAKEN_RISK_FREE_RATE: %q", rate)
+		}
+		cfg.RiskFreeRate = d
+	}
+	return cfg, nil
+}
+
+// ParseTargetWeights reads a comma-separated list of ASSET=PERCENT, e.g.
+// "BTC=60,ETH=30,USD=10". Weights may add up to less than 100; the rest is
+// left unallocated.
+func ParseTargetWeights(s string) (map[string]decimal.Decimal, error) {
+	weights := make(map[string]decimal.Decimal)
+	total := decimal.Zero
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		asset, weight, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not ASSET=PERCENT", item)
+		}
+		asset = strings.ToUpper(strings.TrimSpace(asset))
+		d, err := decimal.Parse(strings.TrimSuffix(strings.TrimSpace(weight), "%"))
+		if err != nil || d.Sign() < 0 || asset == "" {
+			return nil, fmt.Errorf("%q is not ASSET=PERCENT", item)
+		}
+		if _, dup := weights[asset]; dup {
+			return nil, fmt.Errorf("%s is listed twice", asset)
+		}
+		weights[asset] = d
+		total = total.Add(d)
+	}
+	if total.GreaterThan(decimal.NewFromInt(100)) {
+		return nil, fmt.Errorf("weights add up to %s%%, more than 100%%", total.String())
+	}
+	return weights, nil
 }
 
 func (c *Config) Validate() error {
@@ -58,5 +333,11 @@ func (c *Config) Validate() error {
 	if c.ApiSecret == "" {
 		return ErrNoAPISecret
 	}
+	if c.WsVersion != "" && c.WsVersion != "v1" && c.WsVersion != "v2" {
+		return ErrInvalidWsVersion
+	}
+	if c.SMTPAddr != "" && (c.SMTPFrom == "" || len(c.SMTPTo) == 0) {
+		return ErrIncompleteSMTP
+	}
 	return nil
 }
@@ -0,0 +1,46 @@
+package krakenfake
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+// SetBook sets the order book Depth serves for a pair, named by wsname.
+func (s *Server) SetBook(wsname string, book models.OrderBook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.books[wsname] = book
+}
+
+func (s *Server) handleDepth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, pair, ok := s.pairByName(r.URL.Query().Get("pair"))
+	if !ok {
+		writeResult(w, nil, fmt.Errorf("EQuery:Unknown asset pair"))
+		return
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count <= 0 {
+		count = 100
+	}
+
+	book := s.books[pair.WsName]
+	levels := func(side []models.BookLevel) [][]interface{} {
+		if len(side) > count {
+			side = side[:count]
+		}
+		rows := make([][]interface{}, 0, len(side))
+		for _, l := range side {
+			rows = append(rows, []interface{}{l.Price.String(), l.Volume.String(), book.Time.Unix()})
+		}
+		return rows
+	}
+	writeResult(w, map[string]interface{}{
+		key: map[string]interface{}{"asks": levels(book.Asks), "bids": levels(book.Bids)},
+	}, nil)
+}
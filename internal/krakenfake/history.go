@@ -0,0 +1,86 @@
+package krakenfake
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+// PageSize is how many trades or ledger entries one TradesHistory or Ledgers
+// request returns, as on Kraken.
+const PageSize = 50
+
+func (s *Server) AddTrade(txid string, trade models.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trades[txid] = trade
+}
+
+func (s *Server) AddLedgerEntry(id string, entry models.LedgerEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ledger[id] = entry
+}
+
+func (s *Server) handleTradesHistory(form url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	times := make(map[string]float64, len(s.trades))
+	for id, trade := range s.trades {
+		times[id] = trade.Time
+	}
+	ids, count := pageIDs(times, form)
+	page := make(map[string]models.Trade)
+	for _, id := range ids {
+		page[id] = s.trades[id]
+	}
+	return map[string]interface{}{"trades": page, "count": count}, nil
+}
+
+func (s *Server) handleLedgers(form url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	times := make(map[string]float64, len(s.ledger))
+	for id, entry := range s.ledger {
+		times[id] = entry.Time
+	}
+	ids, count := pageIDs(times, form)
+	page := make(map[string]models.LedgerEntry)
+	for _, id := range ids {
+		page[id] = s.ledger[id]
+	}
+	return map[string]interface{}{"ledger": page, "count": count}, nil
+}
+
+// pageIDs orders the entries after the start form value, a Unix time,
+// newest first and returns the page starting at the ofs form value along
+// with how many there are in all.
+func pageIDs(times map[string]float64, form url.Values) ([]string, int) {
+	start, _ := strconv.ParseFloat(form.Get("start"), 64)
+	ids := make([]string, 0, len(times))
+	for id, t := range times {
+		if t > start {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if times[ids[i]] != times[ids[j]] {
+			return times[ids[i]] > times[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	ofs, _ := strconv.Atoi(form.Get("ofs"))
+	if ofs < 0 || ofs > len(ids) {
+		ofs = len(ids)
+	}
+	end := ofs + PageSize
+	if end > len(ids) {
+		end = len(ids)
+	}
+	return ids[ofs:end], len(ids)
+}
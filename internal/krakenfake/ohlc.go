@@ -0,0 +1,53 @@
+package krakenfake
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+// ohlcLimit is how many of the latest candles OHLC returns, as on Kraken.
+const ohlcLimit = 720
+
+// SetCandles sets the candles OHLC serves for a pair, named by wsname,
+// whatever interval is asked for.
+func (s *Server) SetCandles(wsname string, candles []models.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sorted := append([]models.Candle(nil), candles...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	s.candles[wsname] = sorted
+}
+
+func (s *Server) handleOHLC(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, pair, ok := s.pairByName(r.URL.Query().Get("pair"))
+	if !ok {
+		writeResult(w, nil, fmt.Errorf("EQuery:Unknown asset pair"))
+		return
+	}
+	since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+
+	candles := s.candles[pair.WsName]
+	if len(candles) > ohlcLimit {
+		candles = candles[len(candles)-ohlcLimit:]
+	}
+	rows := make([][]interface{}, 0, len(candles))
+	var last int64
+	for _, c := range candles {
+		if c.Time.Unix() <= since {
+			continue
+		}
+		rows = append(rows, []interface{}{
+			c.Time.Unix(), c.Open.String(), c.High.String(), c.Low.String(), c.Close.String(),
+			c.Close.String(), "0", 0,
+		})
+		last = c.Time.Unix()
+	}
+	writeResult(w, map[string]interface{}{key: rows, "last": last}, nil)
+}
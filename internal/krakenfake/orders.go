@@ -0,0 +1,290 @@
+package krakenfake
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// Order is an order placed through AddOrder. Status is open until it is
+// cancelled or FillOrder fills all of it. Price is the limit price and
+// AvgPrice what the filled part went at.
+type Order struct {
+	TxID      string
+	Status    string
+	Pair      string
+	Type      string
+	OrderType string
+	Volume    decimal.Decimal
+	Price     decimal.Decimal
+	VolExec   decimal.Decimal
+	AvgPrice  decimal.Decimal
+	OpenTm    float64
+	CloseTm   float64
+}
+
+// info is the order as OpenOrders and ClosedOrders return it.
+func (o Order) info() map[string]interface{} {
+	info := map[string]interface{}{
+		"status": o.Status,
+		"opentm": o.OpenTm,
+		"descr": map[string]string{
+			"pair":      o.Pair,
+			"type":      o.Type,
+			"ordertype": o.OrderType,
+			"price":     o.Price.String(),
+			"order":     fmt.Sprintf("%s %s %s @ %s %s", o.Type, o.Volume.String(), o.Pair, o.OrderType, o.Price.String()),
+		},
+		"vol":      o.Volume.String(),
+		"vol_exec": o.VolExec.String(),
+		"cost":     o.VolExec.Mul(o.AvgPrice).String(),
+		"fee":      "0",
+		"price":    o.AvgPrice.String(),
+	}
+	if o.Status != "open" {
+		info["closetm"] = o.CloseTm
+	}
+	return info
+}
+
+// Orders returns the orders placed so far, keyed by txid.
+func (s *Server) Orders() map[string]Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Order, len(s.orders))
+	for id, order := range s.orders {
+		out[id] = order
+	}
+	return out
+}
+
+// handleAddOrder checks orders the way Kraken does for the fields it
+// supports: a known pair, a side, market or limit, and at least the pair's
+// minimum volume.
+func (s *Server) handleAddOrder(form url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, pair, ok := s.pairByName(form.Get("pair"))
+	if !ok {
+		return nil, fmt.Errorf("EQuery:Unknown asset pair")
+	}
+	side := form.Get("type")
+	if side != "buy" && side != "sell" {
+		return nil, fmt.Errorf("EGeneral:Invalid arguments:type")
+	}
+	orderType := form.Get("ordertype")
+	if orderType != "market" && orderType != "limit" {
+		return nil, fmt.Errorf("EGeneral:Invalid arguments:ordertype")
+	}
+
+	volume, err := decimal.Parse(form.Get("volume"))
+	if err != nil || volume.Sign() <= 0 {
+		return nil, fmt.Errorf("EGeneral:Invalid arguments:volume")
+	}
+	if min, err := decimal.Parse(pair.OrderMin); err == nil && volume.LessThan(min) {
+		return nil, fmt.Errorf("EOrder:Order minimum not met")
+	}
+
+	price := decimal.Zero
+	descr := fmt.Sprintf("%s %s %s @ market", side, volume.StringFixed(int32(pair.LotDecimals)), pair.Altname)
+	if orderType == "limit" {
+		price, err = decimal.Parse(form.Get("price"))
+		if err != nil || price.Sign() <= 0 {
+			return nil, fmt.Errorf("EGeneral:Invalid arguments:price")
+		}
+		descr = fmt.Sprintf("%s %s %s @ limit %s", side, volume.StringFixed(int32(pair.LotDecimals)), pair.Altname, price.String())
+	}
+
+	result := map[string]interface{}{"descr": map[string]string{"order": descr}}
+	if form.Get("validate") == "true" {
+		return result, nil
+	}
+
+	txid := fmt.Sprintf("O%05d-FAKE", len(s.orders)+1)
+	s.orders[txid] = Order{
+		TxID:      txid,
+		Status:    "open",
+		Pair:      pair.Altname,
+		Type:      side,
+		OrderType: orderType,
+		Volume:    volume,
+		Price:     price,
+		OpenTm:    now(),
+	}
+	result["txid"] = []string{txid}
+	return result, nil
+}
+
+func (s *Server) handleCancelOrder(form url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[form.Get("txid")]
+	if !ok || order.Status != "open" {
+		return nil, fmt.Errorf("EOrder:Unknown order")
+	}
+	order.Status = "canceled"
+	order.CloseTm = now()
+	s.orders[order.TxID] = order
+	return map[string]interface{}{"count": 1}, nil
+}
+
+func (s *Server) handleCancelAll(url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for id, order := range s.orders {
+		if order.Status == "open" {
+			order.Status = "canceled"
+			order.CloseTm = now()
+			s.orders[id] = order
+			count++
+		}
+	}
+	return map[string]interface{}{"count": count}, nil
+}
+
+func (s *Server) handleOpenOrders(url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open := make(map[string]interface{})
+	for id, order := range s.orders {
+		if order.Status == "open" {
+			open[id] = order.info()
+		}
+	}
+	return map[string]interface{}{"open": open}, nil
+}
+
+func (s *Server) handleClosedOrders(url.Values) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closed := make(map[string]interface{})
+	for id, order := range s.orders {
+		if order.Status != "open" {
+			closed[id] = order.info()
+		}
+	}
+	return map[string]interface{}{"closed": closed, "count": len(closed)}, nil
+}
+
+// FillOrder executes volume more of an open order at price, closing it once
+// all of it is filled, and notifies order subscribers.
+func (s *Server) FillOrder(txid, volume, price string) error {
+	s.mu.Lock()
+	order, ok := s.orders[txid]
+	if !ok || order.Status != "open" {
+		s.mu.Unlock()
+		return fmt.Errorf("no open order %s", txid)
+	}
+
+	filled := decimal.MustParse(volume)
+	fillPrice := decimal.MustParse(price)
+	executed := order.VolExec.Add(filled)
+	order.AvgPrice = order.VolExec.Mul(order.AvgPrice).Add(filled.Mul(fillPrice)).Div(executed, 10)
+	order.VolExec = executed
+	if executed.Cmp(order.Volume) >= 0 {
+		order.Status = "closed"
+		order.CloseTm = now()
+	}
+	s.orders[txid] = order
+	s.mu.Unlock()
+
+	s.pushOrders([]Order{order}, "trade")
+	return nil
+}
+
+// notifyingOrders wraps an order handler so that order subscribers hear of
+// every order it opens or closes.
+func (s *Server) notifyingOrders(handler PrivateHandler) PrivateHandler {
+	return func(form url.Values) (interface{}, error) {
+		before := s.Orders()
+		result, err := handler(form)
+		if err != nil {
+			return result, err
+		}
+
+		var changed []Order
+		for id, order := range s.Orders() {
+			if prev, ok := before[id]; !ok || prev.Status != order.Status {
+				changed = append(changed, order)
+			}
+		}
+		s.pushOrders(changed, "")
+		return result, nil
+	}
+}
+
+// pushOrders sends the orders to order subscribers. v2 frames carry
+// execType, or each order's status when it is empty.
+func (s *Server) pushOrders(orders []Order, execType string) {
+	if len(orders) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		if c.orders {
+			conns = append(conns, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.writeJSON(orderFrame(c.version, "update", execType, orders))
+	}
+}
+
+// openOrderList must be called with s.mu held.
+func (s *Server) openOrderList() []Order {
+	orders := make([]Order, 0, len(s.orders))
+	for _, order := range s.orders {
+		if order.Status == "open" {
+			orders = append(orders, order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].TxID < orders[j].TxID })
+	return orders
+}
+
+// orderFrame is an openOrders frame for v1 clients and an executions one for
+// v2 clients.
+func orderFrame(version, kind, execType string, orders []Order) interface{} {
+	if version != "v2" {
+		entries := make([]map[string]interface{}, len(orders))
+		for i, order := range orders {
+			entries[i] = map[string]interface{}{order.TxID: order.info()}
+		}
+		return []interface{}{entries, "openOrders", map[string]int{"sequence": 1}}
+	}
+
+	data := make([]map[string]interface{}, len(orders))
+	for i, order := range orders {
+		exec := execType
+		if exec == "" {
+			exec = order.Status
+		}
+		data[i] = map[string]interface{}{
+			"order_id":     order.TxID,
+			"exec_type":    exec,
+			"order_status": order.Status,
+			"symbol":       order.Pair,
+			"side":         order.Type,
+			"order_qty":    order.Volume.String(),
+			"cum_qty":      order.VolExec.String(),
+		}
+	}
+	return map[string]interface{}{"channel": "executions", "type": kind, "data": data}
+}
+
+func now() float64 {
+	return float64(time.Now().UnixNano()) / 1e9
+}
@@ -0,0 +1,814 @@
+package krakenfake
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/gorilla/websocket"
+)
+
+// Token is the WebSocket token handed out by GetWebSocketsToken and the only
+// one the private channels accept.
+const Token = "krakenfake-ws-token"
+
+type PrivateHandler func(form url.Values) (interface{}, error)
+
+type Server struct {
+	APIKey    string
+	APISecret string
+
+	httpServer *httptest.Server
+	upgrader   websocket.Upgrader
+
+	mu            sync.Mutex
+	balances      map[string]string
+	assets        map[string]models.AssetInfo
+	pairs         map[string]models.AssetPair
+	private       map[string]PrivateHandler
+	trades        map[string]models.Trade
+	ledger        map[string]models.LedgerEntry
+	prices        map[string]string
+	candles       map[string][]models.Candle
+	books         map[string]models.OrderBook
+	orders        map[string]Order
+	lastNonce     int64
+	conns         map[*conn]bool
+	rejections    []int
+	subscriptions chan []string
+
+	privateSubscriptions chan string
+	closes               chan int
+}
+
+type conn struct {
+	ws      *websocket.Conn
+	mu      sync.Mutex
+	version string
+	pairs   map[string]bool
+	private bool
+	orders  bool
+}
+
+func (c *conn) writeJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteJSON(v)
+}
+
+func New(apiKey, apiSecret string) *Server {
+	s := &Server{
+		APIKey:        apiKey,
+		APISecret:     apiSecret,
+		balances:      make(map[string]string),
+		assets:        defaultAssets(),
+		pairs:         defaultPairs(),
+		private:       make(map[string]PrivateHandler),
+		trades:        make(map[string]models.Trade),
+		ledger:        make(map[string]models.LedgerEntry),
+		prices:        make(map[string]string),
+		candles:       make(map[string][]models.Candle),
+		books:         make(map[string]models.OrderBook),
+		orders:        make(map[string]Order),
+		conns:         make(map[*conn]bool),
+		subscriptions: make(chan []string, 16),
+
+		privateSubscriptions: make(chan string, 16),
+		closes:               make(chan int, 16),
+	}
+
+	s.HandlePrivate("Balance", func(url.Values) (interface{}, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		result := make(map[string]string, len(s.balances))
+		for asset, balance := range s.balances {
+			result[asset] = balance
+		}
+		return result, nil
+	})
+
+	s.HandlePrivate("GetWebSocketsToken", func(url.Values) (interface{}, error) {
+		return map[string]interface{}{"token": Token, "expires": 900}, nil
+	})
+
+	s.HandlePrivate("TradesHistory", s.handleTradesHistory)
+	s.HandlePrivate("Ledgers", s.handleLedgers)
+	s.HandlePrivate("AddOrder", s.notifyingOrders(s.handleAddOrder))
+	s.HandlePrivate("CancelOrder", s.notifyingOrders(s.handleCancelOrder))
+	s.HandlePrivate("CancelAll", s.notifyingOrders(s.handleCancelAll))
+	s.HandlePrivate("OpenOrders", s.handleOpenOrders)
+	s.HandlePrivate("ClosedOrders", s.handleClosedOrders)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/0/public/Assets", s.handleAssets)
+	mux.HandleFunc("/0/public/AssetPairs", s.handleAssetPairs)
+	mux.HandleFunc("/0/public/Ticker", s.handleTicker)
+	mux.HandleFunc("/0/public/OHLC", s.handleOHLC)
+	mux.HandleFunc("/0/public/Depth", s.handleDepth)
+	mux.HandleFunc("/0/private/", s.handlePrivate)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/ws/v2", s.handleWebSocketV2)
+	s.httpServer = httptest.NewServer(mux)
+	return s
+}
+
+func defaultAssets() map[string]models.AssetInfo {
+	return map[string]models.AssetInfo{
+		"XXBT": {Altname: "XBT", Decimals: 10, DisplayDecimals: 5},
+		"XETH": {Altname: "ETH", Decimals: 10, DisplayDecimals: 5},
+		"SOL":  {Altname: "SOL", Decimals: 10, DisplayDecimals: 5},
+		"ZUSD": {Altname: "USD", Decimals: 4, DisplayDecimals: 2},
+	}
+}
+
+func defaultPairs() map[string]models.AssetPair {
+	return map[string]models.AssetPair{
+		"XXBTZUSD": {Altname: "XBTUSD", WsName: "XBT/USD", Base: "XXBT", Quote: "ZUSD", PairDecimals: 1, LotDecimals: 8, OrderMin: "0.0001"},
+		"XETHZUSD": {Altname: "ETHUSD", WsName: "ETH/USD", Base: "XETH", Quote: "ZUSD", PairDecimals: 2, LotDecimals: 8, OrderMin: "0.002"},
+		"SOLUSD":   {Altname: "SOLUSD", WsName: "SOL/USD", Base: "SOL", Quote: "ZUSD", PairDecimals: 2, LotDecimals: 8, OrderMin: "0.02"},
+	}
+}
+
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+func (s *Server) WsURL() string {
+	return "ws" + strings.TrimPrefix(s.httpServer.URL, "http") + "/ws"
+}
+
+func (s *Server) WsV2URL() string {
+	return s.WsURL() + "/v2"
+}
+
+func (s *Server) Client() *http.Client {
+	return s.httpServer.Client()
+}
+
+func (s *Server) Close() {
+	s.DropConnections()
+	s.httpServer.Close()
+}
+
+func (s *Server) SetBalances(balances map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances = make(map[string]string, len(balances))
+	for asset, balance := range balances {
+		s.balances[asset] = balance
+	}
+}
+
+func (s *Server) AddAsset(name string, info models.AssetInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assets[name] = info
+}
+
+func (s *Server) AddPair(name string, pair models.AssetPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pairs[name] = pair
+}
+
+// SetPrice sets the last price the public Ticker endpoint reports for a
+// pair, given by wsname.
+func (s *Server) SetPrice(wsname, price string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[wsname] = price
+}
+
+// HandlePrivate registers a handler for /0/private/<method>. Requests reach
+// it only after the API key, signature and nonce have been verified.
+func (s *Server) HandlePrivate(method string, handler PrivateHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.private[method] = handler
+}
+
+// Handler returns the handler registered for /0/private/<method>, so a
+// test can wrap it.
+func (s *Server) Handler(method string) PrivateHandler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.private[method]
+}
+
+func (s *Server) handleAssets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeResult(w, s.assets, nil)
+}
+
+func (s *Server) handleAssetPairs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeResult(w, s.pairs, nil)
+}
+
+// pairByName finds a pair by key or altname. The caller holds s.mu.
+func (s *Server) pairByName(name string) (string, models.AssetPair, bool) {
+	if pair, ok := s.pairs[name]; ok {
+		return name, pair, true
+	}
+	for key, pair := range s.pairs {
+		if pair.Altname == name {
+			return key, pair, true
+		}
+	}
+	return "", models.AssetPair{}, false
+}
+
+func (s *Server) handleTicker(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]models.TickerInfo)
+	for _, name := range strings.Split(r.URL.Query().Get("pair"), ",") {
+		key, pair, ok := s.pairByName(name)
+		if !ok {
+			writeResult(w, nil, fmt.Errorf("EQuery:Unknown asset pair"))
+			return
+		}
+		price, ok := s.prices[pair.WsName]
+		if !ok {
+			continue
+		}
+		result[key] = models.TickerInfo{
+			Close: []string{price, "1"},
+			Low:   []string{price, price},
+			High:  []string{price, price},
+			Open:  price,
+		}
+	}
+	writeResult(w, result, nil)
+}
+
+func (s *Server) handlePrivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeResult(w, nil, fmt.Errorf("EGeneral:Invalid arguments"))
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeResult(w, nil, fmt.Errorf("EGeneral:Invalid arguments"))
+		return
+	}
+
+	if err := s.verify(r, string(body), form.Get("nonce")); err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+
+	method := strings.TrimPrefix(r.URL.Path, "/0/private/")
+	s.mu.Lock()
+	handler, ok := s.private[method]
+	s.mu.Unlock()
+	if !ok {
+		writeResult(w, nil, fmt.Errorf("EGeneral:Unknown method"))
+		return
+	}
+
+	result, err := handler(form)
+	writeResult(w, result, err)
+}
+
+func (s *Server) verify(r *http.Request, postData, nonce string) error {
+	if r.Header.Get("API-Key") != s.APIKey {
+		return fmt.Errorf("EAPI:Invalid key")
+	}
+
+	n, err := strconv.ParseInt(nonce, 10, 64)
+	if err != nil {
+		return fmt.Errorf("EAPI:Invalid nonce")
+	}
+
+	expected := Sign(s.APISecret, r.URL.Path, postData, nonce)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get("API-Sign"))) {
+		return fmt.Errorf("EAPI:Invalid signature")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n <= s.lastNonce {
+		return fmt.Errorf("EAPI:Invalid nonce")
+	}
+	s.lastNonce = n
+	return nil
+}
+
+// Sign computes the API-Sign header Kraken expects for a private request:
+// HMAC-SHA512 of the URI path and SHA256(nonce + POST data), keyed with the
+// base64-decoded secret.
+func Sign(secret, urlPath, postData, nonce string) string {
+	sha256Sum := sha256.Sum256([]byte(nonce + postData))
+	decodedSecret, _ := base64.StdEncoding.DecodeString(secret)
+	mac := hmac.New(sha512.New, decodedSecret)
+	mac.Write([]byte(urlPath))
+	mac.Write(sha256Sum[:])
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func writeResult(w http.ResponseWriter, result interface{}, err error) {
+	resp := map[string]interface{}{"error": []string{}}
+	if err != nil {
+		resp["error"] = []string{err.Error()}
+	} else {
+		resp["result"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// RejectHandshakes answers the next WebSocket handshakes with these HTTP
+// statuses, one each, before accepting connections again.
+func (s *Server) RejectHandshakes(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejections = append(s.rejections, statuses...)
+}
+
+func (s *Server) accept(w http.ResponseWriter, r *http.Request, version string) *conn {
+	s.mu.Lock()
+	if len(s.rejections) > 0 {
+		status := s.rejections[0]
+		s.rejections = s.rejections[1:]
+		s.mu.Unlock()
+		http.Error(w, http.StatusText(status), status)
+		return nil
+	}
+	s.mu.Unlock()
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil
+	}
+
+	c := &conn{ws: ws, version: version, pairs: make(map[string]bool)}
+	s.mu.Lock()
+	s.conns[c] = true
+	s.mu.Unlock()
+	return c
+}
+
+func (s *Server) release(c *conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+	c.ws.Close()
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	c := s.accept(w, r, "v1")
+	if c == nil {
+		return
+	}
+	defer s.release(c)
+
+	c.writeJSON(map[string]interface{}{
+		"event":   "systemStatus",
+		"status":  "online",
+		"version": "1.9.0",
+	})
+
+	for {
+		var msg struct {
+			Event        string   `json:"event"`
+			ReqID        int      `json:"reqid,omitempty"`
+			Pair         []string `json:"pair"`
+			Subscription struct {
+				Name  string `json:"name"`
+				Token string `json:"token"`
+			} `json:"subscription"`
+		}
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			s.recordClose(err)
+			return
+		}
+
+		switch {
+		case msg.Event == "ping":
+			c.writeJSON(map[string]interface{}{"event": "pong", "reqid": msg.ReqID})
+		case msg.Event == "subscribe" && (msg.Subscription.Name == "ownTrades" || msg.Subscription.Name == "openOrders"):
+			s.subscribePrivate(c, msg.Subscription.Name, msg.Subscription.Token)
+		case msg.Event == "subscribe":
+			s.subscribe(c, msg.Pair, msg.Subscription.Name)
+		}
+	}
+}
+
+func (s *Server) handleWebSocketV2(w http.ResponseWriter, r *http.Request) {
+	c := s.accept(w, r, "v2")
+	if c == nil {
+		return
+	}
+	defer s.release(c)
+
+	c.writeJSON(map[string]interface{}{
+		"channel": "status",
+		"type":    "update",
+		"data":    []map[string]string{{"api_version": "v2", "system": "online"}},
+	})
+
+	for {
+		var msg struct {
+			Method string `json:"method"`
+			ReqID  int    `json:"req_id,omitempty"`
+			Params struct {
+				Channel string   `json:"channel"`
+				Symbol  []string `json:"symbol"`
+				Token   string   `json:"token"`
+			} `json:"params"`
+		}
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			s.recordClose(err)
+			return
+		}
+
+		switch {
+		case msg.Method == "ping":
+			c.writeJSON(map[string]interface{}{"method": "pong", "req_id": msg.ReqID})
+		case msg.Method == "subscribe" && (msg.Params.Channel == "balances" || msg.Params.Channel == "executions"):
+			s.subscribePrivate(c, msg.Params.Channel, msg.Params.Token)
+		case msg.Method == "subscribe":
+			pairs := make([]string, len(msg.Params.Symbol))
+			for i, symbol := range msg.Params.Symbol {
+				pairs[i] = s.wsName(symbol)
+			}
+			s.subscribe(c, pairs, msg.Params.Channel)
+		}
+	}
+}
+
+func v2Symbol(wsname string) string {
+	parts := strings.Split(wsname, "/")
+	for i, part := range parts {
+		switch part {
+		case "XBT":
+			parts[i] = "BTC"
+		case "XDG":
+			parts[i] = "DOGE"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (s *Server) wsName(symbol string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, pair := range s.pairs {
+		if v2Symbol(pair.WsName) == symbol {
+			return pair.WsName
+		}
+	}
+	return symbol
+}
+
+func (s *Server) knownPair(wsname string) bool {
+	for _, pair := range s.pairs {
+		if pair.WsName == wsname {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) subscribe(c *conn, pairs []string, name string) {
+	accepted := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		s.mu.Lock()
+		ok := s.knownPair(pair)
+		if ok {
+			c.pairs[pair] = true
+			accepted = append(accepted, pair)
+		}
+		s.mu.Unlock()
+
+		c.writeJSON(subscriptionAck(c.version, pair, name, ok))
+	}
+
+	select {
+	case s.subscriptions <- accepted:
+	default:
+	}
+}
+
+func subscriptionAck(version, pair, name string, ok bool) interface{} {
+	if version == "v2" {
+		ack := map[string]interface{}{
+			"method":  "subscribe",
+			"success": ok,
+		}
+		if ok {
+			ack["result"] = map[string]string{"channel": name, "symbol": v2Symbol(pair)}
+		} else {
+			ack["error"] = "Currency pair not supported " + v2Symbol(pair)
+		}
+		return ack
+	}
+
+	ack := map[string]interface{}{
+		"event":        "subscriptionStatus",
+		"pair":         pair,
+		"channelName":  name,
+		"subscription": map[string]string{"name": name},
+		"status":       "subscribed",
+	}
+	if !ok {
+		ack["status"] = "error"
+		ack["errorMessage"] = "Currency pair not supported"
+	}
+	return ack
+}
+
+func (s *Server) subscribePrivate(c *conn, channel, token string) {
+	ok := token == Token
+
+	var ack map[string]interface{}
+	if c.version == "v2" {
+		ack = map[string]interface{}{"method": "subscribe", "success": ok}
+		if ok {
+			ack["result"] = map[string]string{"channel": channel}
+		} else {
+			ack["error"] = "EAPI:Invalid token"
+		}
+	} else {
+		ack = map[string]interface{}{
+			"event":        "subscriptionStatus",
+			"channelName":  channel,
+			"subscription": map[string]string{"name": channel},
+			"status":       "subscribed",
+		}
+		if !ok {
+			ack["status"] = "error"
+			ack["errorMessage"] = "EAPI:Invalid token"
+		}
+	}
+	c.writeJSON(ack)
+	if !ok {
+		return
+	}
+
+	var snapshot interface{}
+	s.mu.Lock()
+	if channel == "openOrders" || channel == "executions" {
+		c.orders = true
+		snapshot = orderFrame(c.version, "snapshot", "", s.openOrderList())
+	} else {
+		c.private = true
+		snapshot = s.balanceFrame(c.version, "snapshot", s.balances)
+	}
+	s.mu.Unlock()
+
+	c.writeJSON(snapshot)
+	select {
+	case s.privateSubscriptions <- channel:
+	default:
+	}
+}
+
+// balanceFrame must be called with s.mu held.
+func (s *Server) balanceFrame(version, kind string, balances map[string]string) interface{} {
+	if version != "v2" {
+		return []interface{}{
+			[]map[string]interface{}{{
+				fmt.Sprintf("T%d", time.Now().UnixNano()): map[string]string{"type": "buy"},
+			}},
+			"ownTrades",
+			map[string]int{"sequence": 1},
+		}
+	}
+
+	data := make([]map[string]interface{}, 0, len(balances))
+	for asset, balance := range balances {
+		name := asset
+		if info, ok := s.assets[asset]; ok {
+			name = info.Altname
+		}
+		entry := map[string]interface{}{
+			"asset":       v2Symbol(name),
+			"asset_class": "currency",
+			"balance":     json.Number(balance),
+		}
+		if kind == "update" {
+			entry["type"] = "trade"
+		}
+		data = append(data, entry)
+	}
+	return map[string]interface{}{"channel": "balances", "type": kind, "data": data}
+}
+
+// WaitForPrivateSubscription blocks until a client subscribes to a private
+// channel with a valid token and returns the channel name.
+func (s *Server) WaitForPrivateSubscription(timeout time.Duration) (string, error) {
+	select {
+	case channel := <-s.privateSubscriptions:
+		return channel, nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("no private subscription within %v", timeout)
+	}
+}
+
+// PushBalance settles a balance change server side and notifies private
+// subscribers: v2 clients get a balances update, v1 clients an ownTrades
+// frame.
+func (s *Server) PushBalance(asset, balance string) error {
+	s.mu.Lock()
+	s.balances[asset] = balance
+	frames := make(map[*conn]interface{})
+	for c := range s.conns {
+		if c.private {
+			frames[c] = s.balanceFrame(c.version, "update", map[string]string{asset: balance})
+		}
+	}
+	s.mu.Unlock()
+
+	if len(frames) == 0 {
+		return fmt.Errorf("no private subscribers")
+	}
+	for c, frame := range frames {
+		if err := c.writeJSON(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) recordClose(err error) {
+	code := websocket.CloseAbnormalClosure
+	if closeErr, ok := err.(*websocket.CloseError); ok {
+		code = closeErr.Code
+	}
+
+	select {
+	case s.closes <- code:
+	default:
+	}
+}
+
+// WaitForClose blocks until a client connection ends and returns its close
+// code, or websocket.CloseAbnormalClosure if no close frame was received.
+func (s *Server) WaitForClose(timeout time.Duration) (int, error) {
+	select {
+	case code := <-s.closes:
+		return code, nil
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("no connection closed within %v", timeout)
+	}
+}
+
+// WaitForSubscription blocks until a client subscribes and returns the pairs
+// it asked for.
+func (s *Server) WaitForSubscription(timeout time.Duration) ([]string, error) {
+	select {
+	case pairs := <-s.subscriptions:
+		return pairs, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no subscription within %v", timeout)
+	}
+}
+
+func (s *Server) subscribers(pair string) []*conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		if c.pairs[pair] {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+func (s *Server) PushTicker(pair, price string) error {
+	return s.PushTickerStats(pair, TickerStats{Last: price})
+}
+
+// TickerStats are the figures of a ticker frame. Empty fields default to
+// Last, so the 24 hour change is zero.
+type TickerStats struct {
+	Last string
+	Open string
+	Low  string
+	High string
+}
+
+// PushTickerStats sends a ticker with a 24 hour open, low and high. v2
+// frames carry the open as the change from it.
+func (s *Server) PushTickerStats(pair string, stats TickerStats) error {
+	conns := s.subscribers(pair)
+	if len(conns) == 0 {
+		return fmt.Errorf("no subscribers for %s", pair)
+	}
+
+	for _, field := range []*string{&stats.Open, &stats.Low, &stats.High} {
+		if *field == "" {
+			*field = stats.Last
+		}
+	}
+	for _, c := range conns {
+		if err := c.writeJSON(tickerFrame(c.version, pair, stats)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tickerFrame(version, pair string, stats TickerStats) interface{} {
+	price := stats.Last
+	if version == "v2" {
+		last := json.Number(price)
+		open := decimal.MustParse(stats.Open)
+		change := decimal.MustParse(price).Sub(open)
+		changePct := change.Mul(decimal.NewFromInt(100)).Div(open, 2)
+		return map[string]interface{}{
+			"channel": "ticker",
+			"type":    "update",
+			"data": []map[string]interface{}{{
+				"symbol":     v2Symbol(pair),
+				"bid":        last,
+				"bid_qty":    json.Number("1"),
+				"ask":        last,
+				"ask_qty":    json.Number("1"),
+				"last":       last,
+				"volume":     json.Number("0"),
+				"vwap":       last,
+				"low":        json.Number(stats.Low),
+				"high":       json.Number(stats.High),
+				"change":     json.Number(change.String()),
+				"change_pct": json.Number(changePct.String()),
+			}},
+		}
+	}
+
+	return []interface{}{
+		0,
+		map[string]interface{}{
+			"a": []interface{}{price, 1, "1.000"},
+			"b": []interface{}{price, 1, "1.000"},
+			"c": []string{price, "0.1"},
+			"v": []string{"0", "0"},
+			"p": []string{price, price},
+			"t": []int{0, 0},
+			"l": []string{stats.Low, stats.Low},
+			"h": []string{stats.High, stats.High},
+			"o": []string{stats.Open, stats.Open},
+		},
+		"ticker",
+		pair,
+	}
+}
+
+// PushFrame sends an arbitrary frame to every connection subscribed to pair.
+func (s *Server) PushFrame(pair string, frame interface{}) error {
+	conns := s.subscribers(pair)
+	if len(conns) == 0 {
+		return fmt.Errorf("no subscribers for %s", pair)
+	}
+
+	for _, c := range conns {
+		if err := c.writeJSON(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropConnections closes every open WebSocket without a close frame, the
+// way a network failure would look to the client.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.ws.UnderlyingConn().Close()
+	}
+}
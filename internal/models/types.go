@@ -1,6 +1,11 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
 	"github.com/gorilla/websocket"
 )
 
@@ -8,9 +13,9 @@ type KrakenClient struct {
 	ApiKey     string
 	ApiSecret  string
 	WsConn     *websocket.Conn
-	Prices     map[string]float64
-	PrevPrices map[string]float64
-	Balances   map[string]float64
+	Prices     map[string]decimal.Decimal
+	PrevPrices map[string]decimal.Decimal
+	Balances   map[string]decimal.Decimal
 }
 
 type BalanceResponse struct {
@@ -18,12 +23,344 @@ type BalanceResponse struct {
 	Result map[string]string `json:"result"`
 }
 
+type WebSocketsTokenResponse struct {
+	Error  []string `json:"error"`
+	Result struct {
+		Token   string `json:"token"`
+		Expires int    `json:"expires"`
+	} `json:"result"`
+}
+
+type AssetInfo struct {
+	Altname         string `json:"altname"`
+	Decimals        int    `json:"decimals"`
+	DisplayDecimals int    `json:"display_decimals"`
+}
+
+type AssetsResponse struct {
+	Error  []string             `json:"error"`
+	Result map[string]AssetInfo `json:"result"`
+}
+
+type AssetPair struct {
+	Altname      string `json:"altname"`
+	WsName       string `json:"wsname"`
+	Base         string `json:"base"`
+	Quote        string `json:"quote"`
+	PairDecimals int    `json:"pair_decimals"`
+	LotDecimals  int    `json:"lot_decimals"`
+	OrderMin     string `json:"ordermin"`
+}
+
+type AssetPairsResponse struct {
+	Error  []string             `json:"error"`
+	Result map[string]AssetPair `json:"result"`
+}
+
+// Trade is an entry of the private TradesHistory endpoint. Cost and Fee are
+// in the pair's quote currency.
+type Trade struct {
+	OrderTxID string          `json:"ordertxid"`
+	Pair      string          `json:"pair"`
+	Time      float64         `json:"time"`
+	Type      string          `json:"type"`
+	OrderType string          `json:"ordertype"`
+	Price     decimal.Decimal `json:"price"`
+	Cost      decimal.Decimal `json:"cost"`
+	Fee       decimal.Decimal `json:"fee"`
+	Vol       decimal.Decimal `json:"vol"`
+	Ledgers   []string        `json:"ledgers"`
+
+	TxID string `json:"-"`
+}
+
+type TradesHistoryResponse struct {
+	Error  []string `json:"error"`
+	Result struct {
+		Trades map[string]Trade `json:"trades"`
+		Count  int              `json:"count"`
+	} `json:"result"`
+}
+
+// LedgerEntry is an entry of the private Ledgers endpoint: one asset's side
+// of a trade, deposit, withdrawal, staking reward or other balance change.
+type LedgerEntry struct {
+	RefID   string          `json:"refid"`
+	Time    float64         `json:"time"`
+	Type    string          `json:"type"`
+	Subtype string          `json:"subtype"`
+	Aclass  string          `json:"aclass"`
+	Asset   string          `json:"asset"`
+	Amount  decimal.Decimal `json:"amount"`
+	Fee     decimal.Decimal `json:"fee"`
+	Balance decimal.Decimal `json:"balance"`
+
+	ID string `json:"-"`
+}
+
+type LedgersResponse struct {
+	Error  []string `json:"error"`
+	Result struct {
+		Ledger map[string]LedgerEntry `json:"ledger"`
+		Count  int                    `json:"count"`
+	} `json:"result"`
+}
+
+// Lot is an open tax lot: the units of an asset still held from one buy and
+// their share of its cost, fees included, in the quote currency.
+type Lot struct {
+	Asset    string
+	TradeID  string
+	Time     time.Time
+	Quantity decimal.Decimal
+	Cost     decimal.Decimal
+}
+
+// AssetValue carries the cost basis fields only when the asset has open
+// lots; AvgCost is zero otherwise.
 type AssetValue struct {
-	Asset     string
-	Balance   float64
-	Price     float64
-	PrevPrice float64
-	USDValue  float64
+	Asset           string
+	Balance         decimal.Decimal
+	Price           decimal.Decimal
+	PrevPrice       decimal.Decimal
+	Value           decimal.Decimal // in the quote currency
+	BalanceDecimals int
+	PriceDecimals   int
+
+	AvgCost       decimal.Decimal
+	CostBasis     decimal.Decimal
+	UnrealizedPnL decimal.Decimal
+	PnLPct        decimal.Decimal
+
+	// Open24h is zero until a ticker with an open has arrived, and High24h
+	// and Low24h are only known for assets priced through a single pair.
+	// Pair is that pair's wsname and Volume24h its volume in the asset,
+	// when the asset is the pair's base. SessionOpen is the first price seen
+	// since the tracker started.
+	Open24h          decimal.Decimal
+	High24h          decimal.Decimal
+	Low24h           decimal.Decimal
+	Pair             string
+	Volume24h        decimal.Decimal
+	Change24hPct     decimal.Decimal
+	SessionOpen      decimal.Decimal
+	SessionChangePct decimal.Decimal
+
+	// Sparkline is the recent price history in equal time buckets, oldest
+	// first and ending now. Buckets from before the first price are zero.
+	Sparkline []decimal.Decimal
+
+	// Weight is the row's share of the total value in percent. Target and
+	// Drift, in percentage points, are only set when HasTarget is.
+	Weight    decimal.Decimal
+	Target    decimal.Decimal
+	Drift     decimal.Decimal
+	HasTarget bool
+
+	// Reserved is the part of Balance held by open orders, once they have
+	// been loaded; Available is what is left to trade.
+	Reserved  decimal.Decimal
+	Available decimal.Decimal
+}
+
+type Ticker struct {
+	Symbol    string          `json:"symbol"`
+	Bid       decimal.Decimal `json:"bid"`
+	BidQty    decimal.Decimal `json:"bid_qty"`
+	Ask       decimal.Decimal `json:"ask"`
+	AskQty    decimal.Decimal `json:"ask_qty"`
+	Last      decimal.Decimal `json:"last"`
+	Volume    decimal.Decimal `json:"volume"`
+	VWAP      decimal.Decimal `json:"vwap"`
+	Low       decimal.Decimal `json:"low"`
+	High      decimal.Decimal `json:"high"`
+	Change    decimal.Decimal `json:"change"`
+	ChangePct decimal.Decimal `json:"change_pct"`
+
+	// Open is the price 24 hours ago. v2 frames do not carry it, so it is
+	// derived from the change.
+	Open decimal.Decimal `json:"-"`
+}
+
+// TickerInfo is one pair's entry from the public Ticker endpoint. Close,
+// Low and High hold today's value then the last 24 hours'; Open is the
+// price 24 hours ago.
+type TickerInfo struct {
+	Close []string `json:"c"`
+	Low   []string `json:"l"`
+	High  []string `json:"h"`
+	Open  string   `json:"o"`
+}
+
+type TickerResponse struct {
+	Error  []string              `json:"error"`
+	Result map[string]TickerInfo `json:"result"`
+}
+
+// Candle is one OHLC interval starting at Time.
+type Candle struct {
+	Time  time.Time
+	Open  decimal.Decimal
+	High  decimal.Decimal
+	Low   decimal.Decimal
+	Close decimal.Decimal
+}
+
+// OHLCResponse holds the pair's candles, each an array of time, open, high,
+// low, close, vwap, volume and count, next to a "last" timestamp.
+type OHLCResponse struct {
+	Error  []string                   `json:"error"`
+	Result map[string]json.RawMessage `json:"result"`
+}
+
+// BookLevel is one price level of an order book.
+type BookLevel struct {
+	Price  decimal.Decimal
+	Volume decimal.Decimal
+}
+
+// OrderBook holds the best bids, highest first, and the best asks, lowest
+// first, of a pair named by wsname.
+type OrderBook struct {
+	Pair string
+	Bids []BookLevel
+	Asks []BookLevel
+	Time time.Time
+}
+
+// DepthResponse holds each pair's levels as arrays of price, volume and
+// timestamp.
+type DepthResponse struct {
+	Error  []string `json:"error"`
+	Result map[string]struct {
+		Asks [][]json.RawMessage `json:"asks"`
+		Bids [][]json.RawMessage `json:"bids"`
+	} `json:"result"`
+}
+
+// OrderRequest is an AddOrder call. Price is only sent for limit orders, and
+// Validate asks Kraken to check the order without placing it.
+type OrderRequest struct {
+	Pair      string
+	Type      string // buy or sell
+	OrderType string // market or limit
+	Volume    decimal.Decimal
+	Price     decimal.Decimal
+	Validate  bool
+}
+
+type AddOrderResult struct {
+	Descr struct {
+		Order string `json:"order"`
+	} `json:"descr"`
+	TxID []string `json:"txid"`
+}
+
+type AddOrderResponse struct {
+	Error  []string       `json:"error"`
+	Result AddOrderResult `json:"result"`
+}
+
+type OrderDescr struct {
+	Pair      string          `json:"pair"`
+	Type      string          `json:"type"`
+	OrderType string          `json:"ordertype"`
+	Price     decimal.Decimal `json:"price"`
+	Order     string          `json:"order"`
+}
+
+// Order is an entry of the private OpenOrders or ClosedOrders endpoints.
+// Descr.Pair is the pair's altname and Price the average fill price.
+type Order struct {
+	Status  string          `json:"status"`
+	OpenTm  float64         `json:"opentm"`
+	CloseTm float64         `json:"closetm"`
+	Descr   OrderDescr      `json:"descr"`
+	Vol     decimal.Decimal `json:"vol"`
+	VolExec decimal.Decimal `json:"vol_exec"`
+	Cost    decimal.Decimal `json:"cost"`
+	Fee     decimal.Decimal `json:"fee"`
+	Price   decimal.Decimal `json:"price"`
+
+	TxID string `json:"-"`
+}
+
+type OpenOrdersResponse struct {
+	Error  []string `json:"error"`
+	Result struct {
+		Open map[string]Order `json:"open"`
+	} `json:"result"`
+}
+
+type ClosedOrdersResponse struct {
+	Error  []string `json:"error"`
+	Result struct {
+		Closed map[string]Order `json:"closed"`
+		Count  int              `json:"count"`
+	} `json:"result"`
+}
+
+// OrderView is an order as the display lists it. For open orders Price is
+// the limit price and Last the pair's current price, zero when unknown; for
+// fills Price is the average fill price and Volume the amount filled.
+type OrderView struct {
+	TxID          string
+	Pair          string
+	Side          string
+	OrderType     string
+	Price         decimal.Decimal
+	Volume        decimal.Decimal
+	FilledPct     decimal.Decimal
+	Last          decimal.Decimal
+	DistancePct   decimal.Decimal
+	PriceDecimals int
+	LotDecimals   int
+}
+
+// PerformanceSummary is the display's row of returns over Period, all in
+// percent. MWR is only known when HasMWR is set, and volatility and Sharpe
+// when HasRisk is.
+type PerformanceSummary struct {
+	Period      string
+	TWR         decimal.Decimal
+	MWR         decimal.Decimal
+	HasMWR      bool
+	MaxDrawdown decimal.Decimal
+	Volatility  decimal.Decimal
+	Sharpe      decimal.Decimal
+	HasRisk     bool
+}
+
+type CancelOrderResponse struct {
+	Error  []string `json:"error"`
+	Result struct {
+		Count int `json:"count"`
+	} `json:"result"`
+}
+
+// ConnectionState starts out as StateConnecting, so nothing reads as live
+// before the first dial has succeeded.
+type ConnectionState int
+
+const (
+	StateConnecting ConnectionState = iota
+	StateConnected
+	StateReconnecting
+	StateStale
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateStale:
+		return "stale"
+	}
+	return "unknown"
 }
 
 var AssetMapping = map[string]string{
@@ -0,0 +1,82 @@
+package performance
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// PriceFunc prices a ledger asset in the quote currency at a point in time.
+type PriceFunc func(asset string, at time.Time) (decimal.Decimal, bool)
+
+// Flows values the ledger's deposits and withdrawals with price. Fees stay
+// inside the portfolio's returns. Entries that cannot be priced are left out
+// and named in the warnings.
+func Flows(entries []models.LedgerEntry, price PriceFunc) ([]Flow, []string) {
+	var flows []Flow
+	unpriced := make(map[string]bool)
+	for _, e := range entries {
+		if e.Type != "deposit" && e.Type != "withdrawal" {
+			continue
+		}
+		if e.Amount.IsZero() {
+			continue
+		}
+		at := ledgerTime(e.Time)
+		p, ok := price(e.Asset, at)
+		if !ok {
+			unpriced[e.Asset] = true
+			continue
+		}
+		flows = append(flows, Flow{Time: at, Asset: e.Asset, RefID: e.RefID, Amount: e.Amount.Mul(p)})
+	}
+
+	var warnings []string
+	for asset := range unpriced {
+		warnings = append(warnings, fmt.Sprintf("%s deposits or withdrawals have no price and were left out", asset))
+	}
+	sort.Strings(warnings)
+	return flows, warnings
+}
+
+func ledgerTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// ParsePeriod reads how far back to measure from now: "all", "ytd", a
+// number of days, weeks or years such as 30d, 12w or 1y, or a Go duration
+// such as 12h. It returns the start of the period, zero for all of it.
+func ParsePeriod(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "all":
+		return time.Time{}, nil
+	case "ytd":
+		return time.Date(now.UTC().Year(), time.January, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	if n := len(s); n > 1 {
+		if count, err := strconv.Atoi(s[:n-1]); err == nil && count > 0 {
+			switch s[n-1] {
+			case 'd':
+				return now.AddDate(0, 0, -count), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*count), nil
+			case 'y':
+				return now.AddDate(-count, 0, 0), nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("invalid period %q, e.g. 30d, 12w, 1y, ytd or all", s)
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,49 @@
+package performance
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+const reportTimeLayout = "2006-01-02 15:04"
+
+// WriteReport prints the period's values and flows, then its returns and
+// risk figures. Amounts are in currency, at cents.
+func WriteReport(w io.Writer, r Result, currency string, warnings []string) error {
+	fmt.Fprintf(w, "Performance from %s to %s UTC\n\n",
+		r.Start.UTC().Format(reportTimeLayout), r.End.UTC().Format(reportTimeLayout))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Start value\t%s %s\n", r.StartValue.StringFixed(2), currency)
+	fmt.Fprintf(tw, "Deposits\t%s %s\n", r.Deposits.StringFixed(2), currency)
+	fmt.Fprintf(tw, "Withdrawals\t%s %s\n", r.Withdrawals.StringFixed(2), currency)
+	fmt.Fprintf(tw, "End value\t%s %s\n", r.EndValue.StringFixed(2), currency)
+	fmt.Fprintf(tw, "Gain\t%s %s\n", r.Gain.StringFixed(2), currency)
+	fmt.Fprintln(tw, "\t")
+	fmt.Fprintf(tw, "Time-weighted return\t%s%%\n", r.TWR.StringFixed(2))
+	if r.HasMWR {
+		fmt.Fprintf(tw, "Money-weighted return\t%s%%\n", r.MWR.StringFixed(2))
+	} else {
+		fmt.Fprintln(tw, "Money-weighted return\tn/a")
+	}
+	if r.HasIRR {
+		fmt.Fprintf(tw, "IRR (annualized)\t%s%%\n", r.IRR.StringFixed(2))
+	}
+	fmt.Fprintf(tw, "Max drawdown\t%s%%\n", r.MaxDrawdown.StringFixed(2))
+	if r.HasRisk {
+		fmt.Fprintf(tw, "Volatility (annualized)\t%s%%\n", r.Volatility.StringFixed(2))
+		fmt.Fprintf(tw, "Sharpe ratio\t%s\n", r.Sharpe.StringFixed(2))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(warnings) > 0 {
+		fmt.Fprintln(w, "\nWarnings:")
+		for _, warning := range warnings {
+			fmt.Fprintf(w, "  - %s\n", warning)
+		}
+	}
+	return nil
+}
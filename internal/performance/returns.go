@@ -0,0 +1,244 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+const (
+	year = 365.25 * 24 * time.Hour
+	// Percentages are kept to this many decimals.
+	pctDecimals = 4
+)
+
+var ErrTooFewPoints = errors.New("need at least two portfolio values to measure returns")
+
+// Point is the portfolio value at one time, in the quote currency.
+type Point struct {
+	Time  time.Time
+	Value decimal.Decimal
+}
+
+// Flow is money moved in (positive) or out (negative) of the portfolio, in
+// the quote currency.
+type Flow struct {
+	Time   time.Time
+	Asset  string
+	RefID  string
+	Amount decimal.Decimal
+}
+
+type Options struct {
+	// RiskFree is the annual rate, in percent, the Sharpe ratio measures
+	// excess returns against.
+	RiskFree decimal.Decimal
+}
+
+// Result covers the period from the first point to the last. Returns,
+// drawdown and volatility are in percent. MWR is the money-weighted return
+// over the period and IRR the same rate annualized; HasMWR is false when
+// it has no solution. Volatility is annualized from the spacing of the
+// points, and HasRisk is false when there are too few of them.
+type Result struct {
+	Start       time.Time
+	End         time.Time
+	StartValue  decimal.Decimal
+	EndValue    decimal.Decimal
+	Deposits    decimal.Decimal
+	Withdrawals decimal.Decimal
+	Gain        decimal.Decimal
+
+	TWR         decimal.Decimal
+	MWR         decimal.Decimal
+	IRR         decimal.Decimal
+	HasMWR      bool
+	HasIRR      bool
+	MaxDrawdown decimal.Decimal
+	Volatility  decimal.Decimal
+	Sharpe      decimal.Decimal
+	HasRisk     bool
+}
+
+// FromSnapshots takes the total of each snapshot.
+func FromSnapshots(snaps []snapshot.Snapshot) []Point {
+	points := make([]Point, len(snaps))
+	for i, s := range snaps {
+		points[i] = Point{Time: s.Time, Value: s.Total}
+	}
+	return points
+}
+
+// Analyze measures the portfolio's returns between its first and last
+// points. Flows outside that span are ignored; a flow is taken to land just
+// before the point that follows it, so that point's value includes it.
+func Analyze(points []Point, flows []Flow, opts Options) (Result, error) {
+	points = append([]Point(nil), points...)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	if len(points) < 2 {
+		return Result{}, ErrTooFewPoints
+	}
+	first, last := points[0], points[len(points)-1]
+
+	var inPeriod []Flow
+	for _, f := range flows {
+		if f.Time.After(first.Time) && !f.Time.After(last.Time) {
+			inPeriod = append(inPeriod, f)
+		}
+	}
+	sort.SliceStable(inPeriod, func(i, j int) bool { return inPeriod[i].Time.Before(inPeriod[j].Time) })
+
+	r := Result{
+		Start:      first.Time,
+		End:        last.Time,
+		StartValue: first.Value,
+		EndValue:   last.Value,
+	}
+	for _, f := range inPeriod {
+		if f.Amount.Sign() > 0 {
+			r.Deposits = r.Deposits.Add(f.Amount)
+		} else {
+			r.Withdrawals = r.Withdrawals.Sub(f.Amount)
+		}
+	}
+	r.Gain = last.Value.Sub(first.Value).Sub(r.Deposits).Add(r.Withdrawals)
+
+	returns := subPeriodReturns(points, inPeriod)
+	growth, drawdown := 1.0, 0.0
+	peak := 1.0
+	for _, ret := range returns {
+		growth *= 1 + ret
+		peak = math.Max(peak, growth)
+		drawdown = math.Max(drawdown, 1-growth/peak)
+	}
+	r.TWR = percent(growth - 1)
+	r.MaxDrawdown = percent(drawdown)
+
+	span := last.Time.Sub(first.Time)
+	if m, ok := moneyWeighted(points[0], last, inPeriod); ok {
+		r.MWR, r.HasMWR = percent(m), true
+		if span >= 24*time.Hour {
+			irr := math.Pow(1+m, float64(year)/float64(span)) - 1
+			if !math.IsInf(irr, 0) && !math.IsNaN(irr) {
+				r.IRR, r.HasIRR = percent(irr), true
+			}
+		}
+	}
+
+	if len(returns) >= 2 {
+		perYear := float64(year) / (float64(span) / float64(len(returns)))
+		mean, std := meanStd(returns)
+		r.Volatility = percent(std * math.Sqrt(perYear))
+		if std > 0 {
+			riskFree := math.Pow(1+opts.RiskFree.Float64()/100, 1/perYear) - 1
+			r.Sharpe = decimal.NewFromFloat((mean - riskFree) / std * math.Sqrt(perYear)).Round(2)
+		}
+		r.HasRisk = true
+	}
+	return r, nil
+}
+
+// subPeriodReturns chains the return between each pair of points, with the
+// flows in between taken out. Spans that start from nothing are skipped.
+func subPeriodReturns(points []Point, flows []Flow) []float64 {
+	var returns []float64
+	next := 0
+	for i := 1; i < len(points); i++ {
+		flow := decimal.Zero
+		for next < len(flows) && !flows[next].Time.After(points[i].Time) {
+			flow = flow.Add(flows[next].Amount)
+			next++
+		}
+
+		prev := points[i-1].Value
+		if prev.Sign() <= 0 {
+			continue
+		}
+		growth := points[i].Value.Sub(flow).Float64() / prev.Float64()
+		returns = append(returns, growth-1)
+	}
+	return returns
+}
+
+// moneyWeighted solves for the rate over the whole period at which the
+// starting value and the flows grow into the final value, each weighted by
+// the part of the period it was invested for.
+func moneyWeighted(first, last Point, flows []Flow) (float64, bool) {
+	span := float64(last.Time.Sub(first.Time))
+	if span <= 0 {
+		return 0, false
+	}
+
+	type cash struct {
+		at     float64
+		amount float64
+	}
+	cashflows := []cash{{0, -first.Value.Float64()}}
+	for _, f := range flows {
+		cashflows = append(cashflows, cash{float64(f.Time.Sub(first.Time)) / span, -f.Amount.Float64()})
+	}
+	cashflows = append(cashflows, cash{1, last.Value.Float64()})
+
+	npv := func(rate float64) float64 {
+		sum := 0.0
+		for _, c := range cashflows {
+			sum += c.amount * math.Pow(1+rate, 1-c.at)
+		}
+		return sum
+	}
+
+	lo, hi := -0.9999, 1.0
+	for (npv(hi) < 0) == (npv(lo) < 0) {
+		if hi > 1e6 {
+			return 0, false
+		}
+		hi *= 2
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if (npv(mid) < 0) == (npv(lo) < 0) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+func meanStd(xs []float64) (float64, float64) {
+	mean := 0.0
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+
+	variance := 0.0
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	variance /= float64(len(xs) - 1)
+	return mean, math.Sqrt(variance)
+}
+
+func percent(f float64) decimal.Decimal {
+	return decimal.NewFromFloat(f * 100).Round(pctDecimals)
+}
+
+// Summary is the result as the display's footer row shows it.
+func (r Result) Summary(period string) models.PerformanceSummary {
+	return models.PerformanceSummary{
+		Period:      period,
+		TWR:         r.TWR,
+		MWR:         r.MWR,
+		HasMWR:      r.HasMWR,
+		MaxDrawdown: r.MaxDrawdown,
+		Volatility:  r.Volatility,
+		Sharpe:      r.Sharpe,
+		HasRisk:     r.HasRisk,
+	}
+}
@@ -0,0 +1,41 @@
+package rebalance
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WritePlan prints the orders with their estimated fees, then the assets
+// that were left out and why. Amounts are in currency, at cents.
+func WritePlan(w io.Writer, p *Plan, currency string) error {
+	fmt.Fprintf(w, "Portfolio value: %s %s\n\n", p.Total.StringFixed(2), currency)
+
+	if len(p.Orders) == 0 {
+		fmt.Fprintln(w, "Nothing to rebalance.")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "SIDE\tPAIR\tVOLUME\tPRICE\tVALUE\tEST. FEE\t")
+		for _, o := range p.Orders {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n",
+				o.Side, o.Pair, o.Volume.String(), o.Price.String(),
+				o.Value.StringFixed(2), o.Fee.StringFixed(2))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nEstimated fees: %s %s\n", p.Fees.StringFixed(2), currency)
+		fmt.Fprintf(w, "Cash: %s -> %s %s\n", p.CashBefore.StringFixed(2), p.CashAfter.StringFixed(2), currency)
+	}
+
+	for _, note := range p.Notes {
+		fmt.Fprintf(w, "Note: %s\n", note)
+	}
+	if len(p.Skipped) > 0 {
+		fmt.Fprintln(w, "\nSkipped:")
+		for _, s := range p.Skipped {
+			fmt.Fprintf(w, "  - %s: %s\n", s.Name, s.Reason)
+		}
+	}
+	return nil
+}
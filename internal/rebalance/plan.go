@@ -0,0 +1,223 @@
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// Divisions are rounded to this many decimals before volumes are cut down
+// to the pair's lot decimals.
+const precision = 18
+
+var hundred = decimal.NewFromInt(100)
+
+var ErrEmptyPortfolio = errors.New("the portfolio has no value to rebalance")
+
+// Holding is one asset as the planner sees it. Assets with a target but no
+// balance are passed with a zero balance so they can be bought. Pair is the
+// market the asset trades in against the quote currency; its Altname is
+// empty when Kraken lists none.
+type Holding struct {
+	Asset   string
+	Name    string
+	Balance decimal.Decimal
+	Price   decimal.Decimal
+	Value   decimal.Decimal
+	Pair    models.AssetPair
+}
+
+type Options struct {
+	// Quote is the registry key of the quote currency, the cash side of
+	// every order.
+	Quote string
+	// Targets maps registry keys to weights in percent. Assets without one
+	// are left alone.
+	Targets map[string]decimal.Decimal
+	// FeeRate is the expected fee as a fraction of the order value, e.g.
+	// 0.0026 for 0.26%.
+	FeeRate decimal.Decimal
+	// Tolerance is how many percentage points an asset may drift before it
+	// is traded at all.
+	Tolerance decimal.Decimal
+}
+
+// Order is a proposed market order. Value is volume times price and Fee the
+// estimate on top of it for buys or out of it for sells.
+type Order struct {
+	Asset  string
+	Name   string
+	Pair   string
+	Side   string
+	Volume decimal.Decimal
+	Price  decimal.Decimal
+	Value  decimal.Decimal
+	Fee    decimal.Decimal
+}
+
+// Skip records an asset that is off target but gets no order.
+type Skip struct {
+	Name   string
+	Reason string
+}
+
+// Plan is the proposed set of orders, sells before buys so that their
+// proceeds pay for the buys.
+type Plan struct {
+	Total      decimal.Decimal
+	Orders     []Order
+	Skipped    []Skip
+	Fees       decimal.Decimal
+	CashBefore decimal.Decimal
+	CashAfter  decimal.Decimal
+	Notes      []string
+}
+
+// Build proposes the orders that bring every asset with a target back to
+// it. Only assets beyond the tolerance are traded, volumes are cut to the
+// pair's lot decimals and orders below Kraken's minimum are dropped. When
+// cash runs short, buys are scaled down together.
+func Build(holdings []Holding, opts Options) (*Plan, error) {
+	plan := &Plan{}
+	for _, h := range holdings {
+		plan.Total = plan.Total.Add(h.Value)
+		if h.Asset == opts.Quote {
+			plan.CashBefore = plan.CashBefore.Add(h.Value)
+		}
+	}
+	if plan.Total.Sign() <= 0 {
+		return nil, ErrEmptyPortfolio
+	}
+
+	sorted := append([]Holding(nil), holdings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Value.GreaterThan(sorted[j].Value)
+	})
+
+	var sells, buys []Order
+	for _, h := range sorted {
+		target, ok := opts.Targets[h.Asset]
+		if !ok || h.Asset == opts.Quote {
+			continue
+		}
+
+		drift := h.Value.Mul(hundred).Div(plan.Total, precision).Sub(target)
+		if drift.Abs().Cmp(opts.Tolerance) <= 0 {
+			continue
+		}
+		if h.Pair.Altname == "" {
+			plan.skip(h.Name, "no direct pair with the quote currency")
+			continue
+		}
+		if h.Price.Sign() <= 0 {
+			plan.skip(h.Name, "no price")
+			continue
+		}
+
+		delta := target.Mul(plan.Total).Div(hundred, precision).Sub(h.Value)
+		order := Order{Asset: h.Asset, Name: h.Name, Pair: h.Pair.Altname, Side: "buy", Price: h.Price}
+		volume := delta.Div(h.Price, precision)
+		if delta.Sign() < 0 {
+			order.Side = "sell"
+			volume = volume.Neg()
+			if volume.GreaterThan(h.Balance) {
+				volume = h.Balance
+			}
+		}
+		order.Volume = volume.Truncate(int32(h.Pair.LotDecimals))
+
+		if reason := belowMinimum(order, h.Pair); reason != "" {
+			plan.skip(h.Name, "%s", reason)
+			continue
+		}
+		if order.Side == "sell" {
+			sells = append(sells, order)
+		} else {
+			buys = append(buys, order)
+		}
+	}
+
+	cash := plan.CashBefore
+	for i := range sells {
+		sells[i].price(opts.FeeRate)
+		cash = cash.Add(sells[i].Value).Sub(sells[i].Fee)
+	}
+
+	// Cash is only spent down to its own target, if it has one.
+	floor := opts.Targets[opts.Quote].Mul(plan.Total).Div(hundred, precision)
+	available := cash.Sub(floor)
+	need := decimal.Zero
+	for i := range buys {
+		buys[i].price(opts.FeeRate)
+		need = need.Add(buys[i].Value).Add(buys[i].Fee)
+	}
+	if need.GreaterThan(available) && len(buys) > 0 {
+		buys = plan.scaleBuys(buys, holdings, available, need, opts.FeeRate)
+	}
+	for _, order := range buys {
+		cash = cash.Sub(order.Value).Sub(order.Fee)
+	}
+
+	plan.Orders = append(sells, buys...)
+	for _, order := range plan.Orders {
+		plan.Fees = plan.Fees.Add(order.Fee)
+	}
+	plan.CashAfter = cash
+	return plan, nil
+}
+
+// price fills in the order's value and fee at its volume.
+func (o *Order) price(feeRate decimal.Decimal) {
+	o.Value = o.Volume.Mul(o.Price)
+	o.Fee = o.Value.Mul(feeRate)
+}
+
+// scaleBuys shrinks every buy by the same factor so that they fit in the
+// cash available, dropping those that fall below the minimum.
+func (p *Plan) scaleBuys(buys []Order, holdings []Holding, available, need, feeRate decimal.Decimal) []Order {
+	if available.Sign() <= 0 {
+		for _, order := range buys {
+			p.skip(order.Name, "not enough cash")
+		}
+		return nil
+	}
+
+	pairs := make(map[string]models.AssetPair, len(holdings))
+	for _, h := range holdings {
+		pairs[h.Asset] = h.Pair
+	}
+
+	factor := available.Div(need, precision)
+	p.Notes = append(p.Notes, fmt.Sprintf("buys were scaled to %s%% to fit the cash available", factor.Mul(hundred).StringFixed(1)))
+
+	var out []Order
+	for _, order := range buys {
+		pair := pairs[order.Asset]
+		order.Volume = order.Volume.Mul(factor).Truncate(int32(pair.LotDecimals))
+		if reason := belowMinimum(order, pair); reason != "" {
+			p.skip(order.Name, "%s once scaled", reason)
+			continue
+		}
+		order.price(feeRate)
+		out = append(out, order)
+	}
+	return out
+}
+
+func belowMinimum(order Order, pair models.AssetPair) string {
+	if order.Volume.Sign() <= 0 {
+		return "below the lot size"
+	}
+	min, err := decimal.Parse(pair.OrderMin)
+	if err == nil && order.Volume.LessThan(min) {
+		return fmt.Sprintf("below the minimum order of %s", pair.OrderMin)
+	}
+	return ""
+}
+
+func (p *Plan) skip(name, format string, args ...interface{}) {
+	p.Skipped = append(p.Skipped, Skip{Name: name, Reason: fmt.Sprintf(format, args...)})
+}
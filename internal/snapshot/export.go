@@ -0,0 +1,53 @@
+package snapshot
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+)
+
+// WriteCSV writes one row per point: the total with its open, high and low
+// in the bucket, then the value of every asset that appears in the series,
+// empty where it was not held.
+func WriteCSV(w io.Writer, points []Snapshot) error {
+	seen := make(map[string]bool)
+	var assets []string
+	for _, p := range points {
+		for _, h := range p.Holdings {
+			if !seen[h.Asset] {
+				seen[h.Asset] = true
+				assets = append(assets, h.Asset)
+			}
+		}
+	}
+	sort.Strings(assets)
+
+	cw := csv.NewWriter(w)
+	header := append([]string{"time", "currency", "total", "open", "high", "low"}, assets...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, p := range points {
+		row := []string{
+			p.Time.UTC().Format(time.RFC3339),
+			p.Currency,
+			p.Total.String(),
+			p.Open.String(),
+			p.High.String(),
+			p.Low.String(),
+		}
+		for _, asset := range assets {
+			value := ""
+			if h, ok := p.Holding(asset); ok {
+				value = h.Value.String()
+			}
+			row = append(row, value)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,107 @@
+package snapshot
+
+import (
+	"sort"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+type Holding struct {
+	Asset   string          `json:"asset"`
+	Balance decimal.Decimal `json:"balance"`
+	Price   decimal.Decimal `json:"price"`
+	Value   decimal.Decimal `json:"value"`
+}
+
+// Snapshot is the portfolio at one point in time. In a series Time is the
+// start of the bucket, Holdings and Total are as of its last update, and
+// Open, High and Low track the total through the bucket.
+type Snapshot struct {
+	Time     time.Time       `json:"time"`
+	Currency string          `json:"currency"`
+	Total    decimal.Decimal `json:"total"`
+	Open     decimal.Decimal `json:"open"`
+	High     decimal.Decimal `json:"high"`
+	Low      decimal.Decimal `json:"low"`
+	Holdings []Holding       `json:"holdings"`
+}
+
+// FromAssets records the portfolio as valued by the client. It reports
+// false while some holding is still unpriced, since the total would be
+// short.
+func FromAssets(assets []models.AssetValue, currency string, at time.Time) (Snapshot, bool) {
+	s := Snapshot{Time: at.UTC(), Currency: currency, Holdings: make([]Holding, 0, len(assets))}
+	for _, asset := range assets {
+		if asset.Price.IsZero() {
+			return Snapshot{}, false
+		}
+		s.Holdings = append(s.Holdings, Holding{
+			Asset:   asset.Asset,
+			Balance: asset.Balance,
+			Price:   asset.Price,
+			Value:   asset.Value,
+		})
+		s.Total = s.Total.Add(asset.Value)
+	}
+	sort.Slice(s.Holdings, func(i, j int) bool { return s.Holdings[i].Asset < s.Holdings[j].Asset })
+	s.Open, s.High, s.Low = s.Total, s.Total, s.Total
+	return s, true
+}
+
+// Holding returns the named asset's entry, if it was held.
+func (s Snapshot) Holding(asset string) (Holding, bool) {
+	for _, h := range s.Holdings {
+		if h.Asset == asset {
+			return h, true
+		}
+	}
+	return Holding{}, false
+}
+
+// merge folds a later snapshot of the same bucket into s.
+func (s Snapshot) merge(later Snapshot) Snapshot {
+	out := later
+	out.Time = s.Time
+	out.Open = s.Open
+	out.High = s.High
+	if later.High.GreaterThan(out.High) {
+		out.High = later.High
+	}
+	out.Low = s.Low
+	if later.Low.LessThan(out.Low) {
+		out.Low = later.Low
+	}
+	return out
+}
+
+// Prices looks up past prices in a series of snapshots sorted by time. It
+// satisfies accounting.PriceSource for assets named as in the snapshots.
+type Prices []Snapshot
+
+// PriceAt is the asset's price in the last snapshot at or before at that
+// held it, or failing that in the first one after. The quote currency is
+// always priced at one.
+func (p Prices) PriceAt(asset string, at time.Time) (decimal.Decimal, bool) {
+	i := sort.Search(len(p), func(i int) bool { return p[i].Time.After(at) })
+	for j := i - 1; j >= 0; j-- {
+		if price, ok := p[j].priceOf(asset); ok {
+			return price, true
+		}
+	}
+	for j := i; j < len(p); j++ {
+		if price, ok := p[j].priceOf(asset); ok {
+			return price, true
+		}
+	}
+	return decimal.Zero, false
+}
+
+func (s Snapshot) priceOf(asset string) (decimal.Decimal, bool) {
+	if asset == s.Currency {
+		return decimal.One, true
+	}
+	h, ok := s.Holding(asset)
+	return h.Price, ok && h.Price.Sign() > 0
+}
@@ -0,0 +1,446 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Resolution string
+
+const (
+	Minute Resolution = "1m"
+	Hour   Resolution = "1h"
+	Day    Resolution = "1d"
+)
+
+// Resolutions lists every series the store keeps, finest first.
+var Resolutions = []Resolution{Minute, Hour, Day}
+
+var ErrOutOfOrder = errors.New("snapshot is older than the last one stored")
+
+func ParseResolution(s string) (Resolution, error) {
+	for _, r := range Resolutions {
+		if string(r) == strings.TrimSpace(s) {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("unknown resolution %q, use 1m, 1h or 1d", s)
+}
+
+func (r Resolution) Duration() time.Duration {
+	switch r {
+	case Hour:
+		return time.Hour
+	case Day:
+		return 24 * time.Hour
+	}
+	return time.Minute
+}
+
+// Each series is split into files by period - a day of minutes, a month of
+// hours, a year of days - so retention can drop whole files.
+func (r Resolution) layout() string {
+	switch r {
+	case Hour:
+		return "2006-01"
+	case Day:
+		return "2006"
+	}
+	return "2006-01-02"
+}
+
+func (r Resolution) periodEnd(start time.Time) time.Time {
+	switch r {
+	case Hour:
+		return start.AddDate(0, 1, 0)
+	case Day:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+type Options struct {
+	// Retention is how long each resolution is kept. Zero keeps it for
+	// good.
+	Retention map[Resolution]time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{Retention: map[Resolution]time.Duration{
+		Minute: 7 * 24 * time.Hour,
+		Hour:   90 * 24 * time.Hour,
+	}}
+}
+
+// Store keeps portfolio snapshots as JSON lines under dir, one directory per
+// resolution. Appended snapshots are merged into one point per minute; the
+// hour and day rollups are written from the minutes once their bucket has
+// passed, so a restart catches up on what it missed. Buckets are aligned to
+// UTC.
+type Store struct {
+	dir  string
+	opts Options
+
+	mu      sync.Mutex
+	pending *Snapshot
+	rolled  map[Resolution]time.Time
+	current map[Resolution]time.Time
+}
+
+func Open(dir string, opts Options) (*Store, error) {
+	for _, r := range Resolutions {
+		if err := os.MkdirAll(filepath.Join(dir, string(r)), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{
+		dir:     dir,
+		opts:    opts,
+		rolled:  make(map[Resolution]time.Time),
+		current: make(map[Resolution]time.Time),
+	}
+	for _, r := range []Resolution{Hour, Day} {
+		points, err := s.read(r, time.Time{}, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		if n := len(points); n > 0 {
+			s.rolled[r] = points[n-1].Time
+		}
+	}
+	if err := s.Prune(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append records a snapshot of the portfolio. Snapshots must come in time
+// order.
+func (s *Store) Append(snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap.Time = snap.Time.UTC().Truncate(time.Minute)
+	snap.Open, snap.High, snap.Low = snap.Total, snap.Total, snap.Total
+
+	if s.pending != nil {
+		if snap.Time.Before(s.pending.Time) {
+			return ErrOutOfOrder
+		}
+		if snap.Time.Equal(s.pending.Time) {
+			merged := s.pending.merge(snap)
+			s.pending = &merged
+			return nil
+		}
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	s.pending = &snap
+	return s.rollup(snap.Time)
+}
+
+// Close writes out the minute still being filled.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+// Query returns the points of a series from from up to, but not including,
+// to. A zero from or to leaves that end open. The minute series includes
+// the minute still being filled.
+func (s *Store) Query(r Resolution, from, to time.Time) ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points, err := s.read(r, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if r == Minute && s.pending != nil && inRange(s.pending.Time, from, to) {
+		points = mergeBuckets(append(points, *s.pending))
+	}
+	return points, nil
+}
+
+// Backfill writes daily points reconstructed after the fact. Only days
+// before the first recorded snapshot are written, so history the tracker
+// recorded is never overwritten. It returns how many points were written.
+func (s *Store) Backfill(points []Snapshot) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, err := s.first()
+	if err != nil {
+		return 0, err
+	}
+
+	var days []Snapshot
+	for _, p := range Downsample(points, Day) {
+		if first.IsZero() || p.Time.Before(first.Truncate(Day.Duration())) {
+			days = append(days, p)
+		}
+	}
+	if len(days) == 0 {
+		return 0, nil
+	}
+	if err := s.write(Day, days); err != nil {
+		return 0, err
+	}
+	if last := days[len(days)-1].Time; last.After(s.rolled[Day]) {
+		s.rolled[Day] = last
+	}
+	return len(days), nil
+}
+
+// first is the time of the earliest point in any series, zero for an empty
+// store.
+func (s *Store) first() (time.Time, error) {
+	var first time.Time
+	earlier := func(t time.Time) {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+	if s.pending != nil {
+		earlier(s.pending.Time)
+	}
+
+	for _, r := range Resolutions {
+		files, err := s.files(r)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, f := range files {
+			var found bool
+			if err := readFile(f.path, func(p Snapshot) {
+				earlier(p.Time)
+				found = true
+			}); err != nil {
+				return time.Time{}, err
+			}
+			if found {
+				break
+			}
+		}
+	}
+	return first, nil
+}
+
+// Prune removes the files that lie wholly outside their resolution's
+// retention.
+func (s *Store) Prune(now time.Time) error {
+	for r, retention := range s.opts.Retention {
+		if retention <= 0 {
+			continue
+		}
+		cutoff := now.Add(-retention)
+		files, err := s.files(r)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if r.periodEnd(f.start).After(cutoff) {
+				continue
+			}
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) flush() error {
+	if s.pending == nil {
+		return nil
+	}
+	if err := s.write(Minute, []Snapshot{*s.pending}); err != nil {
+		return err
+	}
+	s.pending = nil
+	return nil
+}
+
+// rollup writes the hours and days that ended before now from the minutes
+// stored since the last rollup. It only looks once per bucket.
+func (s *Store) rollup(now time.Time) error {
+	newDay := false
+	for _, r := range []Resolution{Hour, Day} {
+		bucket := now.Truncate(r.Duration())
+		if !bucket.After(s.current[r]) {
+			continue
+		}
+		s.current[r] = bucket
+		newDay = r == Day
+
+		var from time.Time
+		if last, ok := s.rolled[r]; ok {
+			from = last.Add(r.Duration())
+		}
+		minutes, err := s.read(Minute, from, bucket)
+		if err != nil {
+			return err
+		}
+		points := Downsample(minutes, r)
+		if len(points) == 0 {
+			continue
+		}
+		if err := s.write(r, points); err != nil {
+			return err
+		}
+		s.rolled[r] = points[len(points)-1].Time
+	}
+
+	if newDay {
+		return s.Prune(now)
+	}
+	return nil
+}
+
+// Downsample merges points into one per bucket of r, in time order.
+func Downsample(points []Snapshot, r Resolution) []Snapshot {
+	bucketed := make([]Snapshot, len(points))
+	for i, p := range points {
+		p.Time = p.Time.Truncate(r.Duration())
+		bucketed[i] = p
+	}
+	return mergeBuckets(bucketed)
+}
+
+// mergeBuckets sorts points by time and merges those in the same bucket,
+// later lines winning, as a restart within a minute leaves two.
+func mergeBuckets(points []Snapshot) []Snapshot {
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	var out []Snapshot
+	for _, p := range points {
+		if n := len(out); n > 0 && out[n-1].Time.Equal(p.Time) {
+			out[n-1] = out[n-1].merge(p)
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func (s *Store) write(r Resolution, points []Snapshot) error {
+	byFile := make(map[string][]byte)
+	var order []string
+	for _, p := range points {
+		line, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		name := p.Time.Format(r.layout()) + ".jsonl"
+		if _, ok := byFile[name]; !ok {
+			order = append(order, name)
+		}
+		byFile[name] = append(append(byFile[name], line...), '\n')
+	}
+
+	for _, name := range order {
+		path := filepath.Join(s.dir, string(r), name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(byFile[name])
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read loads the stored points of a series within [from, to). A line cut
+// short by a crash is skipped.
+func (s *Store) read(r Resolution, from, to time.Time) ([]Snapshot, error) {
+	files, err := s.files(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Snapshot
+	for _, f := range files {
+		if !from.IsZero() && !r.periodEnd(f.start).After(from) {
+			continue
+		}
+		if !to.IsZero() && !f.start.Before(to) {
+			continue
+		}
+		if err := readFile(f.path, func(p Snapshot) {
+			if inRange(p.Time, from, to) {
+				points = append(points, p)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return mergeBuckets(points), nil
+}
+
+func readFile(path string, fn func(Snapshot)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var p Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			continue
+		}
+		fn(p)
+	}
+	return scanner.Err()
+}
+
+type seriesFile struct {
+	path  string
+	start time.Time
+}
+
+// files lists a series' files oldest first, ignoring anything not named
+// after its period.
+func (s *Store) files(r Resolution) ([]seriesFile, error) {
+	dir := filepath.Join(s.dir, string(r))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []seriesFile
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if e.IsDir() || !ok {
+			continue
+		}
+		start, err := time.Parse(r.layout(), name)
+		if err != nil {
+			continue
+		}
+		files = append(files, seriesFile{path: filepath.Join(dir, e.Name()), start: start})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].start.Before(files[j].start) })
+	return files, nil
+}
+
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
@@ -1,24 +1,29 @@
 package ui
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
 
 	"golang.org/x/term"
 )
 
 const (
-	colorReset = "\033[0m"
-	colorRed   = "\033[31m"
-	colorGreen = "\033[32m"
-	colorCyan  = "\033[36m"
-	colorGray  = "\033[37m"
-	bgBlack    = "\033[40m"
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+	colorGray   = "\033[37m"
+	bgBlack     = "\033[40m"
 
 	minWidth = 60
 	maxWidth = 100
@@ -27,11 +32,56 @@ const (
 	balanceWidth = 10
 	priceWidth   = 12
 	valueWidth   = 12
+	costWidth    = 12
+	pnlWidth     = 18
+	changeWidth  = 8
+	rangeWidth   = 23
+	allocWidth   = 7
+	targetWidth  = 14
+	sparkWidth   = 8
+
+	sideWidth     = 4
+	pairWidth     = 10
+	typeWidth     = 6
+	volumeWidth   = 12
+	filledWidth   = 7
+	distanceWidth = 8
+
+	minPriceDecimals      = 2
+	defaultCurrency       = "USD"
+	defaultDriftTolerance = 5
 )
 
+// sparkBlocks are the sparkline's levels, lowest first.
+var sparkBlocks = []rune("▁▂▃▅▇")
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// Display is safe for concurrent use, so keys can be handled while the
+// stream renders.
 type Display struct {
+	mu     sync.Mutex
 	width  int
 	writer io.Writer
+	state  models.ConnectionState
+
+	currency       string
+	currencyPlaces int
+	driftTolerance decimal.Decimal
+
+	openOrders  []models.OrderView
+	fills       []models.OrderView
+	alert       string
+	performance models.PerformanceSummary
+
+	// assets are the holdings last rendered, kept to render again on a key.
+	assets []models.AssetValue
+	view   view
 }
 
 func calculateWidth(requestedWidth int) int {
@@ -50,83 +100,318 @@ func NewDisplay() *Display {
 		width = 80
 	}
 	return &Display{
-		width:  calculateWidth(width),
-		writer: os.Stdout,
+		width:          calculateWidth(width),
+		writer:         os.Stdout,
+		currency:       defaultCurrency,
+		currencyPlaces: minPriceDecimals,
+		driftTolerance: decimal.NewFromInt(defaultDriftTolerance),
 	}
 }
 
 func NewDisplayWithWriter(w io.Writer, width int) *Display {
 	return &Display{
-		width:  calculateWidth(width),
-		writer: w,
+		width:          calculateWidth(width),
+		writer:         w,
+		currency:       defaultCurrency,
+		currencyPlaces: minPriceDecimals,
+		driftTolerance: decimal.NewFromInt(defaultDriftTolerance),
+	}
+}
+
+// SetQuoteCurrency labels values with currency and shows them at decimals,
+// or at cents when the precision is unknown.
+func (d *Display) SetQuoteCurrency(currency string, decimals int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if decimals <= 0 {
+		decimals = minPriceDecimals
+	}
+	d.currency = currency
+	d.currencyPlaces = decimals
+}
+
+// SetDriftTolerance sets how many percentage points an asset may drift from
+// its target allocation before it is highlighted.
+func (d *Display) SetDriftTolerance(tolerance decimal.Decimal) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.driftTolerance = tolerance
+}
+
+// SetOrders sets the open orders and recent fills listed below the holdings
+// on the next render. The panel is left out while both are empty.
+func (d *Display) SetOrders(open, fills []models.OrderView) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.openOrders = open
+	d.fills = fills
+}
+
+// SetAlert shows text below the frame until it is replaced; an empty text
+// clears it.
+func (d *Display) SetAlert(text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.alert = text
+}
+
+// SetPerformance shows the returns below the total; a summary without a
+// period hides the row.
+func (d *Display) SetPerformance(summary models.PerformanceSummary) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.performance = summary
+}
+
+func (d *Display) SetConnectionState(state models.ConnectionState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state = state
+}
+
+func (d *Display) FormatConnectionState(state models.ConnectionState) string {
+	switch state {
+	case models.StateConnecting:
+		return fmt.Sprintf("%s● CONNECTING%s", colorYellow, colorReset)
+	case models.StateConnected:
+		return fmt.Sprintf("%s● LIVE%s", colorGreen, colorReset)
+	case models.StateReconnecting:
+		return fmt.Sprintf("%s● RECONNECTING%s", colorYellow, colorReset)
+	case models.StateStale:
+		return fmt.Sprintf("%s● STALE%s", colorRed, colorReset)
 	}
+	return ""
 }
 
-func (d *Display) GetPriceColor(current, previous float64) string {
-	if current > previous {
+func (d *Display) GetPriceColor(current, previous decimal.Decimal) string {
+	switch current.Cmp(previous) {
+	case 1:
 		return colorGreen
-	} else if current < previous {
+	case -1:
 		return colorRed
 	}
 	return colorReset
 }
 
-func (d *Display) FormatPrice(price float64, color string) string {
-	return fmt.Sprintf("%s$%.2f%s", color, price, colorReset)
+// FormatPrice shows the pair's own precision but never fewer than cents.
+func (d *Display) FormatPrice(price decimal.Decimal, decimals int, color string) string {
+	if decimals < minPriceDecimals {
+		decimals = minPriceDecimals
+	}
+	return fmt.Sprintf("%s%s%s%s", color, currencySymbols[d.currency], price.StringFixed(int32(decimals)), colorReset)
+}
+
+// FormatPnL shows unrealized P&L with its percentage, green for a gain and
+// red for a loss.
+func (d *Display) FormatPnL(pnl, pct decimal.Decimal) string {
+	return d.GetPriceColor(pnl, decimal.Zero) + d.pnlText(pnl, pct) + colorReset
 }
 
-func (d *Display) FormatBalance(balance float64) string {
-	if balance >= 1000 {
-		return fmt.Sprintf("%.2f", balance)
+func (d *Display) pnlText(pnl, pct decimal.Decimal) string {
+	sign := ""
+	if pnl.Sign() >= 0 {
+		sign = "+"
+	}
+	pctSign := ""
+	if pct.Sign() >= 0 {
+		pctSign = "+"
+	}
+	return fmt.Sprintf("%s%s (%s%s%%)", sign, pnl.StringFixed(int32(d.currencyPlaces)), pctSign, pct.StringFixed(1))
+}
+
+// FormatValue prints an amount in the quote currency, using its symbol when
+// it has one and its code otherwise.
+func (d *Display) FormatValue(value decimal.Decimal) string {
+	amount := value.StringFixed(int32(d.currencyPlaces))
+	if symbol, ok := currencySymbols[d.currency]; ok {
+		return symbol + amount
+	}
+	return amount + " " + d.currency
+}
+
+// FormatBalance uses the asset's display precision when known, otherwise
+// eight decimals for small holdings and two for large ones.
+func (d *Display) FormatBalance(balance decimal.Decimal, decimals int) string {
+	if decimals > 0 {
+		return balance.StringFixed(int32(decimals))
+	}
+	if balance.Cmp(decimal.NewFromInt(1000)) >= 0 {
+		return balance.StringFixed(2)
 	}
-	return fmt.Sprintf("%.8f", balance)
+	return balance.StringFixed(8)
+}
+
+// FormatChange shows a percentage change with its sign, green when up and
+// red when down, padded to changeWidth before coloring.
+func (d *Display) FormatChange(pct decimal.Decimal) string {
+	text := changeText(pct)
+	return d.GetPriceColor(pct, decimal.Zero) + text + d.padding(text, changeWidth) + colorReset
+}
+
+func changeText(pct decimal.Decimal) string {
+	if pct.Sign() >= 0 {
+		return "+" + pct.StringFixed(2) + "%"
+	}
+	return pct.StringFixed(2) + "%"
+}
+
+// columns decides which optional columns a render shows.
+type columns struct {
+	targets bool
+	changes bool
+	session bool
+	cost    bool
+	ranges  bool
+	// spark is the width of the trend column, zero when it is hidden.
+	spark int
+}
+
+// layout fits the optional columns into the frame in order of priority:
+// cost and P&L, the target, the 24 hour change, the session change and
+// the 24 hour range, each shown only while the row still fits. The trend
+// column takes what width is left, at least sparkWidth, and is kept over
+// the range.
+func (d *Display) layout(assets []models.AssetValue) columns {
+	var changes, ranges, targets bool
+	for _, asset := range assets {
+		if !asset.Open24h.IsZero() || !asset.SessionOpen.IsZero() {
+			changes = true
+		}
+		if !asset.High24h.IsZero() {
+			ranges = true
+		}
+		if asset.HasTarget {
+			targets = true
+		}
+	}
+
+	var cols columns
+	spare := d.width - 2 - (assetWidth + balanceWidth + priceWidth + valueWidth + allocWidth + 4)
+	fit := func(wanted bool, width int) bool {
+		if !wanted || spare < width {
+			return false
+		}
+		spare -= width
+		return true
+	}
+	cols.cost = fit(hasCostBasis(assets), costWidth+pnlWidth+2)
+	cols.targets = fit(targets, targetWidth+1)
+	cols.changes = fit(changes, changeWidth+1)
+	cols.session = fit(cols.changes, changeWidth+1)
+	cols.ranges = fit(ranges, rangeWidth+1)
+
+	if buckets := sparkBuckets(assets); buckets > 1 && !d.view.hideTrend {
+		if spare < sparkWidth+1 && cols.ranges {
+			cols.ranges = false
+			spare += rangeWidth + 1
+		}
+		if spare >= sparkWidth+1 {
+			cols.spark = min(spare-1, buckets)
+		}
+	}
+	return cols
+}
+
+// sparkBuckets is the length of the longest price history, zero while no
+// asset has one.
+func sparkBuckets(assets []models.AssetValue) int {
+	buckets := 0
+	for _, asset := range assets {
+		if len(asset.Sparkline) > buckets {
+			buckets = len(asset.Sparkline)
+		}
+	}
+	return buckets
 }
 
 func (d *Display) RenderPortfolio(assets []models.AssetValue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.assets = assets
+	d.render()
+}
+
+// Refresh renders the last holdings again, for changes that come from
+// elsewhere than the stream.
+func (d *Display) Refresh() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.render()
+}
+
+// render builds the frame in memory and writes it at once, so the terminal
+// never shows half of it.
+func (d *Display) render() {
+	var buf bytes.Buffer
+	writer := d.writer
+	d.writer = &buf
+	defer func() {
+		d.writer = writer
+		writer.Write(buf.Bytes())
+	}()
+
+	assets := d.assets
 	fmt.Fprint(d.writer, "\033[H\033[2J")
-	d.renderHeader()
+	cols := d.layout(assets)
+	d.renderHeader(cols)
 
-	cryptoAssets, usdAsset := d.separateAssets(assets)
-	d.renderCryptoAssets(cryptoAssets)
+	cryptoAssets, cash := d.separateAssets(assets)
+	if d.view.interactive && len(cryptoAssets) > 0 {
+		// Pin the selection by name so a new sort keeps it on its asset.
+		d.view.selected = cryptoAssets[d.selectedIndex(cryptoAssets)].Asset
+	}
+	d.renderCryptoAssets(cryptoAssets, cols)
 
-	if usdAsset != nil {
+	if cash != nil {
 		d.renderDivider()
-		d.renderUSD(*usdAsset)
+		d.renderCash(*cash, cols)
+	}
+	if asset, ok := d.detailAsset(cryptoAssets); ok {
+		d.renderDetail(asset)
+	}
+	if !d.view.hideOrders {
+		d.renderOrders(assets)
 	}
 
-	totalUSD := d.calculateTotal(assets)
-	d.renderFooter(totalUSD)
+	total := d.calculateTotal(assets)
+	d.renderFooter(total)
 }
 
 func (d *Display) separateAssets(assets []models.AssetValue) ([]models.AssetValue, *models.AssetValue) {
 	var cryptoAssets []models.AssetValue
-	var usdAsset *models.AssetValue
+	var cash *models.AssetValue
 
 	for _, asset := range assets {
-		if asset.Asset == "USD" {
+		if asset.Asset == d.currency {
 			assetCopy := asset
-			usdAsset = &assetCopy
+			cash = &assetCopy
 		} else {
 			cryptoAssets = append(cryptoAssets, asset)
 		}
 	}
 
-	sort.Slice(cryptoAssets, func(i, j int) bool {
-		return cryptoAssets[i].USDValue > cryptoAssets[j].USDValue
-	})
-
-	return cryptoAssets, usdAsset
+	d.sortAssets(cryptoAssets)
+	return cryptoAssets, cash
 }
 
-func (d *Display) calculateTotal(assets []models.AssetValue) float64 {
-	total := 0.0
+func (d *Display) calculateTotal(assets []models.AssetValue) decimal.Decimal {
+	total := decimal.Zero
 	for _, asset := range assets {
-		total += asset.USDValue
+		total = total.Add(asset.Value)
 	}
 	return total
 }
 
-func (d *Display) renderHeader() {
+func hasCostBasis(assets []models.AssetValue) bool {
+	for _, asset := range assets {
+		if !asset.AvgCost.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Display) renderHeader(cols columns) {
 	title := "KRAKEN PORTFOLIO"
 	titlePadding := (d.width - len(title)) / 2
 
@@ -144,32 +429,193 @@ func (d *Display) renderHeader() {
 	fmt.Fprintf(d.writer, "%s╠%s╣%s\n",
 		colorCyan, strings.Repeat("═", d.width), colorReset)
 
-	fmt.Fprintf(d.writer, "%s║ %-*s %-*s %-*s %-*s ║%s\n",
-		colorCyan,
-		assetWidth, "ASSET",
-		balanceWidth, "BALANCE",
-		priceWidth, "PRICE",
-		valueWidth, "VALUE (USD)",
-		colorReset)
+	header := d.heading("ASSET", assetWidth, sortAsset) + " " +
+		fmt.Sprintf("%-*s", balanceWidth, "BALANCE") + " " +
+		d.heading("PRICE", priceWidth, sortPrice) + " " +
+		d.heading("VALUE ("+d.currency+")", valueWidth, sortValue)
+	header += fmt.Sprintf(" %-*s", allocWidth, "ALLOC")
+	if cols.targets {
+		header += fmt.Sprintf(" %-*s", targetWidth, "TARGET")
+	}
+	if cols.changes {
+		header += " " + d.heading("24H", changeWidth, sortChange)
+	}
+	if cols.session {
+		header += fmt.Sprintf(" %-*s", changeWidth, "SESSION")
+	}
+	if cols.cost {
+		header += fmt.Sprintf(" %-*s ", costWidth, "AVG COST") + d.heading("P&L", pnlWidth, sortPnL)
+	}
+	if cols.ranges {
+		header += fmt.Sprintf(" %-*s", rangeWidth, "24H LOW-HIGH")
+	}
+	if cols.spark > 0 {
+		header += fmt.Sprintf(" %-*s", cols.spark, "TREND")
+	}
+	fmt.Fprintf(d.writer, "%s║ %s ║%s\n", colorCyan, header, colorReset)
 
 	fmt.Fprintf(d.writer, "%s╠%s╣%s\n",
 		colorCyan, strings.Repeat("═", d.width), colorReset)
 }
 
-func (d *Display) renderCryptoAssets(assets []models.AssetValue) {
+func (d *Display) renderCryptoAssets(assets []models.AssetValue, cols columns) {
+	var selected string
+	if len(assets) > 0 {
+		selected = assets[d.selectedIndex(assets)].Asset
+	}
 	for _, asset := range assets {
 		priceColor := d.GetPriceColor(asset.Price, asset.PrevPrice)
-		priceStr := d.FormatPrice(asset.Price, priceColor)
-		balanceStr := d.FormatBalance(asset.Balance)
+		priceStr := d.FormatPrice(asset.Price, asset.PriceDecimals, priceColor)
+		balanceStr := d.FormatBalance(asset.Balance, asset.BalanceDecimals)
 
-		fmt.Fprintf(d.writer, "%s║ %-*s %-*s %-*s %-*.2f ║%s\n",
-			colorCyan,
+		row := fmt.Sprintf("%-*s %-*s %-*s %-*s",
 			assetWidth, asset.Asset,
 			balanceWidth, balanceStr,
 			priceWidth, priceStr,
-			valueWidth, asset.USDValue,
-			colorReset)
+			valueWidth, asset.Value.StringFixed(int32(d.currencyPlaces)))
+		row += " " + d.allocColumns(asset, cols)
+		if cols.changes {
+			row += " " + d.changeColumns(asset, cols)
+		}
+		if cols.cost {
+			row += " " + d.costColumns(asset)
+		}
+		if cols.ranges {
+			row += " " + d.rangeColumn(asset)
+		}
+		if cols.spark > 0 {
+			row += " " + d.sparkColumn(asset.Sparkline, cols.spark)
+		}
+		fmt.Fprintf(d.writer, "%s║%s%s ║%s\n", colorCyan, d.marker(asset, selected), row, colorReset)
+	}
+}
+
+// allocColumns shows the weight and, with targets configured, the target
+// and drift, highlighted once the drift is beyond the tolerance.
+func (d *Display) allocColumns(asset models.AssetValue, cols columns) string {
+	out := fmt.Sprintf("%-*s", allocWidth, asset.Weight.StringFixed(1)+"%")
+	if !cols.targets {
+		return out
+	}
+	if !asset.HasTarget {
+		return out + " " + fmt.Sprintf("%-*s", targetWidth, "-")
+	}
+
+	sign := ""
+	if asset.Drift.Sign() >= 0 {
+		sign = "+"
+	}
+	text := fmt.Sprintf("%s%% (%s%s)", asset.Target.StringFixed(1), sign, asset.Drift.StringFixed(1))
+	color := colorReset
+	if asset.Drift.Abs().GreaterThan(d.driftTolerance) {
+		color = colorYellow
+	}
+	return out + " " + color + text + d.padding(text, targetWidth) + colorCyan
+}
+
+func (d *Display) changeColumns(asset models.AssetValue, cols columns) string {
+	change := fmt.Sprintf("%-*s", changeWidth, "-")
+	if !asset.Open24h.IsZero() && !asset.Price.IsZero() {
+		change = d.FormatChange(asset.Change24hPct)
+	}
+	if !cols.session {
+		return change + colorCyan
+	}
+	session := fmt.Sprintf("%-*s", changeWidth, "-")
+	if !asset.SessionOpen.IsZero() && !asset.Price.IsZero() {
+		session = d.FormatChange(asset.SessionChangePct)
+	}
+	return change + colorCyan + " " + session + colorCyan
+}
+
+func (d *Display) rangeColumn(asset models.AssetValue) string {
+	if asset.High24h.IsZero() {
+		return fmt.Sprintf("%-*s", rangeWidth, "-")
+	}
+	decimals := asset.PriceDecimals
+	if decimals < minPriceDecimals {
+		decimals = minPriceDecimals
 	}
+	text := asset.Low24h.StringFixed(int32(decimals)) + "-" + asset.High24h.StringFixed(int32(decimals))
+	return fmt.Sprintf("%-*s", rangeWidth, text)
+}
+
+// sparkColumn draws the price history in width cells, each showing the last
+// price of its share of the buckets, scaled between the lowest and highest.
+// Buckets before the first price are left blank. The line is green when it
+// ends above where it started and red when below.
+func (d *Display) sparkColumn(series []decimal.Decimal, width int) string {
+	cells := make([]decimal.Decimal, min(width, len(series)))
+	var low, high, first decimal.Decimal
+	for i := range cells {
+		v := series[(i+1)*len(series)/len(cells)-1]
+		cells[i] = v
+		if v.IsZero() {
+			continue
+		}
+		if first.IsZero() {
+			first, low, high = v, v, v
+		}
+		if v.LessThan(low) {
+			low = v
+		}
+		if v.GreaterThan(high) {
+			high = v
+		}
+	}
+	if first.IsZero() {
+		return fmt.Sprintf("%-*s", width, "-")
+	}
+
+	var b strings.Builder
+	top := len(sparkBlocks) - 1
+	spread := high.Sub(low).Float64()
+	for _, v := range cells {
+		switch {
+		case v.IsZero():
+			b.WriteRune(' ')
+		case spread == 0:
+			b.WriteRune(sparkBlocks[top/2])
+		default:
+			level := int(v.Sub(low).Float64()/spread*float64(top) + 0.5)
+			b.WriteRune(sparkBlocks[level])
+		}
+	}
+	last := cells[len(cells)-1]
+	return d.GetPriceColor(last, first) + b.String() + d.padding(b.String(), width) + colorCyan
+}
+
+// costColumns pads before coloring so the escape codes do not eat into the
+// column width.
+func (d *Display) costColumns(asset models.AssetValue) string {
+	if asset.AvgCost.IsZero() {
+		return fmt.Sprintf("%-*s %-*s", costWidth, "-", pnlWidth, "-")
+	}
+
+	decimals := asset.PriceDecimals
+	if decimals < minPriceDecimals {
+		decimals = minPriceDecimals
+	}
+	avgCost := currencySymbols[d.currency] + asset.AvgCost.StringFixed(int32(decimals))
+
+	pnl := "-"
+	color := colorReset
+	if !asset.Price.IsZero() {
+		pnl = d.pnlText(asset.UnrealizedPnL, asset.PnLPct)
+		color = d.GetPriceColor(asset.UnrealizedPnL, decimal.Zero)
+	}
+	return fmt.Sprintf("%s%s %s%s%s%s",
+		avgCost, d.padding(avgCost, costWidth),
+		color, pnl, d.padding(pnl, pnlWidth), colorCyan)
+}
+
+// padding fills s out to width terminal columns, counting runes rather than
+// bytes so currency symbols do not skew the frame.
+func (d *Display) padding(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return strings.Repeat(" ", n)
+	}
+	return ""
 }
 
 func (d *Display) renderDivider() {
@@ -177,26 +623,165 @@ func (d *Display) renderDivider() {
 		colorCyan, strings.Repeat("─", d.width), colorReset)
 }
 
-func (d *Display) renderUSD(usd models.AssetValue) {
-	fmt.Fprintf(d.writer, "%s║ %-*s %-*.2f %-*s %-*.2f ║%s\n",
-		colorCyan,
-		assetWidth, usd.Asset,
-		balanceWidth, usd.Balance,
+func (d *Display) renderCash(cash models.AssetValue, cols columns) {
+	decimals := cash.BalanceDecimals
+	if decimals == 0 {
+		decimals = d.currencyPlaces
+	}
+
+	row := fmt.Sprintf("%-*s %-*s %-*s %-*s",
+		assetWidth, cash.Asset,
+		balanceWidth, d.FormatBalance(cash.Balance, decimals),
 		priceWidth, "-",
-		valueWidth, usd.USDValue,
-		colorReset)
+		valueWidth, cash.Value.StringFixed(int32(d.currencyPlaces)))
+	row += " " + d.allocColumns(cash, cols)
+	fmt.Fprintf(d.writer, "%s║ %s ║%s\n", colorCyan, row, colorReset)
 }
 
-func (d *Display) renderFooter(totalUSD float64) {
+// renderOrders lists open orders, with what they hold back of each balance,
+// and recent fills. The order type is dropped when the frame is too narrow.
+func (d *Display) renderOrders(assets []models.AssetValue) {
+	if len(d.openOrders) == 0 && len(d.fills) == 0 {
+		return
+	}
+	showType := sideWidth+pairWidth+typeWidth+priceWidth+volumeWidth+filledWidth+distanceWidth+6 <= d.width-2
+
+	if len(d.openOrders) > 0 {
+		d.renderSection("OPEN ORDERS", "PRICE", showType, true)
+		for _, order := range d.openOrders {
+			d.renderOrder(order, showType, true)
+		}
+		if reserved := d.reservedText(assets); reserved != "" {
+			d.renderLine(reserved)
+		}
+	}
+
+	if len(d.fills) > 0 {
+		d.renderSection("RECENT FILLS", "AVG PRICE", showType, false)
+		for _, fill := range d.fills {
+			d.renderOrder(fill, showType, false)
+		}
+	}
+}
+
+func (d *Display) renderSection(title, priceLabel string, showType, distance bool) {
+	fmt.Fprintf(d.writer, "%s╠%s╣%s\n",
+		colorCyan, strings.Repeat("═", d.width), colorReset)
+	d.renderLine(title)
+
+	header := fmt.Sprintf("%-*s %-*s", sideWidth, "SIDE", pairWidth, "PAIR")
+	if showType {
+		header += fmt.Sprintf(" %-*s", typeWidth, "TYPE")
+	}
+	header += fmt.Sprintf(" %-*s %-*s %-*s", priceWidth, priceLabel, volumeWidth, "VOLUME", filledWidth, "FILLED")
+	if distance {
+		header += fmt.Sprintf(" %-*s", distanceWidth, "DISTANCE")
+	}
+	fmt.Fprintf(d.writer, "%s║ %s ║%s\n", colorCyan, header, colorReset)
+	d.renderDivider()
+}
+
+// renderOrder shows buys green and sells red. The distance is how far the
+// limit price sits from the last price, blank until the pair is priced.
+func (d *Display) renderOrder(order models.OrderView, showType, distance bool) {
+	color := colorGreen
+	if order.Side == "sell" {
+		color = colorRed
+	}
+	decimals := order.PriceDecimals
+	if decimals < minPriceDecimals {
+		decimals = minPriceDecimals
+	}
+
+	price := "market"
+	if !order.Price.IsZero() {
+		price = order.Price.StringFixed(int32(decimals))
+	}
+
+	row := color + fmt.Sprintf("%-*s", sideWidth, order.Side) + colorCyan
+	row += fmt.Sprintf(" %-*s", pairWidth, order.Pair)
+	if showType {
+		row += fmt.Sprintf(" %-*s", typeWidth, order.OrderType)
+	}
+	row += fmt.Sprintf(" %-*s %-*s %-*s",
+		priceWidth, price,
+		volumeWidth, d.FormatBalance(order.Volume, order.LotDecimals),
+		filledWidth, order.FilledPct.StringFixed(1)+"%")
+	if distance {
+		text := "-"
+		if !order.Last.IsZero() && !order.Price.IsZero() {
+			text = changeText(order.DistancePct)
+		}
+		row += fmt.Sprintf(" %-*s", distanceWidth, text)
+	}
+	fmt.Fprintf(d.writer, "%s║ %s ║%s\n", colorCyan, row, colorReset)
+}
+
+func (d *Display) reservedText(assets []models.AssetValue) string {
+	var held []models.AssetValue
+	for _, asset := range assets {
+		if asset.Reserved.Sign() > 0 {
+			held = append(held, asset)
+		}
+	}
+	if len(held) == 0 {
+		return ""
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].Asset < held[j].Asset })
+
+	parts := make([]string, len(held))
+	for i, asset := range held {
+		parts[i] = d.FormatBalance(asset.Reserved, asset.BalanceDecimals) + " " + asset.Asset
+	}
+	return "Reserved: " + strings.Join(parts, ", ")
+}
+
+func (d *Display) renderLine(text string) {
+	fmt.Fprintf(d.writer, "%s║ %s%s ║%s\n",
+		colorCyan, text, d.padding(text, d.width-2), colorReset)
+}
+
+func (d *Display) renderFooter(total decimal.Decimal) {
 	fmt.Fprintf(d.writer, "%s╠%s╣%s\n",
 		colorCyan, strings.Repeat("═", d.width), colorReset)
 
-	fmt.Fprintf(d.writer, "%s║ TOTAL VALUE: $%-*.*f ║%s\n",
-		colorCyan, d.width-15, 2, totalUSD, colorReset)
+	value := d.FormatValue(total)
+	fmt.Fprintf(d.writer, "%s║ TOTAL VALUE: %s%s ║%s\n",
+		colorCyan, value, d.padding(value, d.width-15), colorReset)
+	if d.performance.Period != "" && !d.view.hidePerformance {
+		d.renderLine(d.performanceText())
+	}
 
 	fmt.Fprintf(d.writer, "%s╚%s╝%s\n",
 		colorCyan, strings.Repeat("═", d.width), colorReset)
 
-	fmt.Fprintf(d.writer, "%sPress Ctrl+C to exit%s\n",
-		colorGray, colorReset)
+	hint := "Press Ctrl+C to exit"
+	if d.view.interactive {
+		hint = keyHint
+	}
+	fmt.Fprintf(d.writer, "%s  %s%s%s\n",
+		d.FormatConnectionState(d.state), colorGray, hint, colorReset)
+
+	if d.alert != "" {
+		fmt.Fprintf(d.writer, "%s▲ %s%s\n", colorYellow, d.alert, colorReset)
+	}
+}
+
+// performanceText lists the returns, dropping the last figures until the
+// row fits the frame.
+func (d *Display) performanceText() string {
+	p := d.performance
+	parts := []string{strings.ToUpper(p.Period), "TWR " + changeText(p.TWR)}
+	if p.HasMWR {
+		parts = append(parts, "MWR "+changeText(p.MWR))
+	}
+	parts = append(parts, "MAX DD "+p.MaxDrawdown.Neg().StringFixed(2)+"%")
+	if p.HasRisk {
+		parts = append(parts, "VOL "+p.Volatility.StringFixed(1)+"%", "SHARPE "+p.Sharpe.StringFixed(2))
+	}
+
+	for len(parts) > 2 && utf8.RuneCountInString(strings.Join(parts, "  ")) > d.width-2 {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, "  ")
 }
@@ -0,0 +1,61 @@
+package ui
+
+// Key is a key press read from a terminal in raw mode: one of the named keys
+// below, or the character typed.
+type Key string
+
+const (
+	KeyUp    Key = "up"
+	KeyDown  Key = "down"
+	KeyLeft  Key = "left"
+	KeyRight Key = "right"
+	KeyEnter Key = "enter"
+	KeyEsc   Key = "esc"
+	KeyTab   Key = "tab"
+	KeyCtrlC Key = "ctrl+c"
+)
+
+// ParseKeys splits what one read returned into key presses. Escape
+// sequences it does not know are dropped whole.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == 0x1b:
+			if i+2 >= len(b) || (b[i+1] != '[' && b[i+1] != 'O') {
+				keys = append(keys, KeyEsc)
+				continue
+			}
+			// CSI and SS3 sequences end at the first byte in @ to ~.
+			j := i + 2
+			for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+				j++
+			}
+			if j == len(b) {
+				return keys
+			}
+			if j == i+2 {
+				if key, ok := arrows[b[j]]; ok {
+					keys = append(keys, key)
+				}
+			}
+			i = j
+		case c == 0x03:
+			keys = append(keys, KeyCtrlC)
+		case c == '\r' || c == '\n':
+			keys = append(keys, KeyEnter)
+		case c == '\t':
+			keys = append(keys, KeyTab)
+		case c >= 0x20 && c < 0x7f:
+			keys = append(keys, Key(rune(c)))
+		}
+	}
+	return keys
+}
+
+var arrows = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+}
@@ -0,0 +1,92 @@
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+const (
+	enterAltScreen = "\033[?1049h\033[?25l"
+	leaveAltScreen = "\033[?25h\033[?1049l"
+)
+
+var ErrNotTerminal = errors.New("stdin and stdout must be a terminal")
+
+// Terminal puts the terminal in raw mode on the alternate screen, so key
+// presses arrive one at a time and the shell's screen comes back on Close.
+// Writes go to stdout with newlines turned into the CRLF raw mode needs.
+type Terminal struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+	once  sync.Once
+}
+
+func OpenTerminal() (*Terminal, error) {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) {
+		return nil, ErrNotTerminal
+	}
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	out.WriteString(enterAltScreen)
+	return &Terminal{in: in, out: out, state: state}, nil
+}
+
+func (t *Terminal) Write(p []byte) (int, error) {
+	if _, err := t.out.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Width is the terminal's width in columns, or 80 when it cannot be read.
+func (t *Terminal) Width() int {
+	width, _, err := term.GetSize(int(t.out.Fd()))
+	if err != nil {
+		return 80
+	}
+	return width
+}
+
+// Keys reads key presses until ctx is cancelled or stdin fails. The read
+// in progress when ctx is cancelled is left behind.
+func (t *Terminal) Keys(ctx context.Context) <-chan Key {
+	keys := make(chan Key)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 64)
+		for {
+			n, err := t.in.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, key := range ParseKeys(buf[:n]) {
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return keys
+}
+
+// Close leaves the alternate screen and restores the terminal's mode. It
+// is safe to call more than once.
+func (t *Terminal) Close() error {
+	var err error
+	t.once.Do(func() {
+		t.out.WriteString(leaveAltScreen)
+		err = term.Restore(int(t.in.Fd()), t.state)
+	})
+	return err
+}
@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+// bookLevels is how many bids and asks the detail pane lists.
+const bookLevels = 5
+
+const keyHint = "↑↓ select  enter details  ←→ sort  r reverse  o orders  p returns  t trend  q quit"
+
+type sortColumn int
+
+const (
+	sortValue sortColumn = iota
+	sortAsset
+	sortPrice
+	sortChange
+	sortPnL
+)
+
+// sortColumns is the order the arrow keys step through.
+var sortColumns = []sortColumn{sortValue, sortAsset, sortPrice, sortChange, sortPnL}
+
+// view is what the keys change: the sort, the selected asset and whether
+// its detail pane is open, and which panels are hidden. The selection is
+// kept by name so it follows the asset when the order changes.
+type view struct {
+	interactive bool
+
+	sortBy   sortColumn
+	reversed bool
+
+	selected string
+	detail   bool
+	book     models.OrderBook
+
+	hideOrders      bool
+	hidePerformance bool
+	hideTrend       bool
+}
+
+// SetInteractive marks the selected row, shows the sorted column and lists
+// the keys below the frame.
+func (d *Display) SetInteractive(interactive bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.view.interactive = interactive
+}
+
+// SetOrderBook sets the book the detail pane shows. It is only shown for
+// the asset priced through its pair.
+func (d *Display) SetOrderBook(book models.OrderBook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.view.book = book
+}
+
+// DetailPair is the pair of the asset whose detail pane is open, empty when
+// it is closed or the asset has no pair of its own.
+func (d *Display) DetailPair() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	crypto, _ := d.separateAssets(d.assets)
+	if asset, ok := d.detailAsset(crypto); ok {
+		return asset.Pair
+	}
+	return ""
+}
+
+// HandleKey applies a key press and renders again. It reports whether the
+// key asks to quit.
+func (d *Display) HandleKey(key Key) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	v := &d.view
+	switch key {
+	case "q", "Q", KeyCtrlC:
+		return true
+	case KeyUp, "k":
+		d.moveSelection(-1)
+	case KeyDown, "j":
+		d.moveSelection(1)
+	case KeyEnter:
+		v.detail = !v.detail
+	case KeyEsc:
+		v.detail = false
+	case KeyRight, KeyTab, "s":
+		d.cycleSort(1)
+	case KeyLeft, "S":
+		d.cycleSort(-1)
+	case "r":
+		v.reversed = !v.reversed
+	case "o":
+		v.hideOrders = !v.hideOrders
+	case "p":
+		v.hidePerformance = !v.hidePerformance
+	case "t":
+		v.hideTrend = !v.hideTrend
+	default:
+		return false
+	}
+	d.render()
+	return false
+}
+
+func (d *Display) moveSelection(delta int) {
+	crypto, _ := d.separateAssets(d.assets)
+	if len(crypto) == 0 {
+		return
+	}
+	i := d.selectedIndex(crypto) + delta
+	i = max(0, min(i, len(crypto)-1))
+	d.view.selected = crypto[i].Asset
+}
+
+func (d *Display) cycleSort(step int) {
+	i := 0
+	for j, col := range sortColumns {
+		if col == d.view.sortBy {
+			i = j
+		}
+	}
+	i = (i + step + len(sortColumns)) % len(sortColumns)
+	d.view.sortBy = sortColumns[i]
+	d.view.reversed = false
+}
+
+// selectedIndex falls back to the first row when nothing is selected yet or
+// the selected asset is gone.
+func (d *Display) selectedIndex(crypto []models.AssetValue) int {
+	for i, asset := range crypto {
+		if asset.Asset == d.view.selected {
+			return i
+		}
+	}
+	return 0
+}
+
+func (d *Display) detailAsset(crypto []models.AssetValue) (models.AssetValue, bool) {
+	if !d.view.interactive || !d.view.detail || len(crypto) == 0 {
+		return models.AssetValue{}, false
+	}
+	return crypto[d.selectedIndex(crypto)], true
+}
+
+// sortAssets orders the rows by the sorted column, biggest first except for
+// names, breaking ties by value and then name so the order holds still.
+func (d *Display) sortAssets(assets []models.AssetValue) {
+	key := func(a models.AssetValue) decimal.Decimal {
+		switch d.view.sortBy {
+		case sortPrice:
+			return a.Price
+		case sortChange:
+			return a.Change24hPct
+		case sortPnL:
+			return a.UnrealizedPnL
+		}
+		return a.Value
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		a, b := assets[i], assets[j]
+		if d.view.sortBy == sortAsset {
+			if a.Asset != b.Asset {
+				return (a.Asset < b.Asset) != d.view.reversed
+			}
+			return false
+		}
+		if c := key(a).Cmp(key(b)); c != 0 {
+			return (c > 0) != d.view.reversed
+		}
+		if c := a.Value.Cmp(b.Value); c != 0 {
+			return c > 0
+		}
+		return a.Asset < b.Asset
+	})
+}
+
+// heading pads a column label, marking the sorted column with the direction
+// when the display is interactive.
+func (d *Display) heading(label string, width int, col sortColumn) string {
+	if d.view.interactive && d.view.sortBy == col {
+		descending := col != sortAsset
+		if d.view.reversed {
+			descending = !descending
+		}
+		if descending {
+			label += "▼"
+		} else {
+			label += "▲"
+		}
+	}
+	return label + d.padding(label, width)
+}
+
+// marker points at the selected row.
+func (d *Display) marker(asset models.AssetValue, selected string) string {
+	if !d.view.interactive || asset.Asset != selected {
+		return " "
+	}
+	return colorYellow + "▸" + colorCyan
+}
+
+// renderDetail shows the asset's 24 hour figures, its holding and the top
+// of its pair's order book.
+func (d *Display) renderDetail(asset models.AssetValue) {
+	decimals := max(asset.PriceDecimals, minPriceDecimals)
+	price := func(p decimal.Decimal) string {
+		if p.IsZero() {
+			return "-"
+		}
+		return p.StringFixed(int32(decimals))
+	}
+	change := func(open, pct decimal.Decimal) string {
+		if open.IsZero() || asset.Price.IsZero() {
+			return "-"
+		}
+		return changeText(pct)
+	}
+
+	fmt.Fprintf(d.writer, "%s╠%s╣%s\n",
+		colorCyan, strings.Repeat("═", d.width), colorReset)
+	title := asset.Asset
+	if asset.Pair != "" {
+		title += "  " + asset.Pair
+	}
+	d.renderLine(title)
+
+	d.renderLine(detailPair("Last", price(asset.Price), "24H", change(asset.Open24h, asset.Change24hPct)))
+	d.renderLine(detailPair("24H open", price(asset.Open24h), "Session", change(asset.SessionOpen, asset.SessionChangePct)))
+	d.renderLine(detailPair("24H low", price(asset.Low24h), "24H high", price(asset.High24h)))
+	volume := "-"
+	if !asset.Volume24h.IsZero() {
+		volume = asset.Volume24h.StringFixed(2) + " " + asset.Asset
+	}
+	d.renderLine(detailPair("Volume", volume, "Balance", d.FormatBalance(asset.Balance, asset.BalanceDecimals)))
+	if !asset.AvgCost.IsZero() {
+		pnl := "-"
+		if !asset.Price.IsZero() {
+			pnl = d.pnlText(asset.UnrealizedPnL, asset.PnLPct)
+		}
+		d.renderLine(detailPair("Avg cost", price(asset.AvgCost), "P&L", pnl))
+	}
+
+	d.renderDivider()
+	d.renderBook(asset, price)
+}
+
+func detailPair(label, value, label2, value2 string) string {
+	return fmt.Sprintf("%-10s %-14s %-10s %s", label, value, label2, value2)
+}
+
+func (d *Display) renderBook(asset models.AssetValue, price func(decimal.Decimal) string) {
+	book := d.view.book
+	switch {
+	case asset.Pair == "":
+		d.renderLine("No order book, " + asset.Asset + " is priced through more than one pair")
+		return
+	case book.Pair != asset.Pair:
+		d.renderLine("Loading order book...")
+		return
+	case len(book.Bids) == 0 && len(book.Asks) == 0:
+		d.renderLine("The order book is empty")
+		return
+	}
+
+	d.renderLine(fmt.Sprintf("%-*s %-*s   %-*s %s",
+		volumeWidth, "BID VOLUME", priceWidth, "BID", priceWidth, "ASK", "ASK VOLUME"))
+	for i := 0; i < bookLevels && (i < len(book.Bids) || i < len(book.Asks)); i++ {
+		var bid, bidVolume, ask, askVolume string
+		if i < len(book.Bids) {
+			bid, bidVolume = price(book.Bids[i].Price), d.FormatBalance(book.Bids[i].Volume, asset.BalanceDecimals)
+		}
+		if i < len(book.Asks) {
+			ask, askVolume = price(book.Asks[i].Price), d.FormatBalance(book.Asks[i].Volume, asset.BalanceDecimals)
+		}
+		d.renderLine(fmt.Sprintf("%-*s %-*s   %-*s %s",
+			volumeWidth, bidVolume, priceWidth, bid, priceWidth, ask, askVolume))
+	}
+
+	if len(book.Bids) > 0 && len(book.Asks) > 0 {
+		bid, ask := book.Bids[0].Price, book.Asks[0].Price
+		spread := ask.Sub(bid)
+		text := "Spread " + price(spread)
+		if mid := bid.Add(ask); mid.Sign() > 0 {
+			pct := spread.Mul(decimal.NewFromInt(200)).Div(mid, 4)
+			text += " (" + pct.StringFixed(3) + "%)"
+		}
+		d.renderLine(text)
+	}
+}
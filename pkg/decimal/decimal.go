@@ -0,0 +1,317 @@
+package decimal
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact base-10 number: coef * 10^-scale. The zero value is 0
+// and values are immutable, so they can be copied and shared freely.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+const maxExponent = 1000
+
+var (
+	Zero = Decimal{}
+	One  = NewFromInt(1)
+
+	ten = big.NewInt(10)
+)
+
+func New(value int64, exp int32) Decimal {
+	d := Decimal{coef: big.NewInt(value)}
+	if exp >= 0 {
+		d.coef.Mul(d.coef, pow10(exp))
+		return d
+	}
+	d.scale = -exp
+	return d
+}
+
+func NewFromInt(value int64) Decimal {
+	return New(value, 0)
+}
+
+// NewFromFloat converts f using its shortest decimal representation, so
+// 0.1 becomes exactly 0.1 rather than the nearest binary fraction.
+func NewFromFloat(f float64) Decimal {
+	d, err := Parse(strconv.FormatFloat(f, 'f', -1, 64))
+	if err != nil {
+		return Zero
+	}
+	return d
+}
+
+func Parse(s string) (Decimal, error) {
+	if s == "" {
+		return Zero, fmt.Errorf("decimal: empty string")
+	}
+
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa = s[:i]
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil || e > maxExponent || e < -maxExponent {
+			return Zero, fmt.Errorf("decimal: invalid exponent in %q", s)
+		}
+		exp = e
+	}
+
+	digits := mantissa
+	if digits != "" && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart == "" && fracPart == "" {
+		return Zero, fmt.Errorf("decimal: no digits in %q", s)
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Zero, fmt.Errorf("decimal: invalid syntax %q", s)
+	}
+
+	coef, ok := new(big.Int).SetString(intPart+fracPart, 10)
+	if !ok {
+		return Zero, fmt.Errorf("decimal: invalid syntax %q", s)
+	}
+	if mantissa[0] == '-' {
+		coef.Neg(coef)
+	}
+
+	scale := int64(len(fracPart)) - exp
+	if scale < 0 {
+		coef.Mul(coef, pow10(int32(-scale)))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+func (d Decimal) value() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+func (d Decimal) rescaled(scale int32) *big.Int {
+	v := new(big.Int).Set(d.value())
+	if scale > d.scale {
+		v.Mul(v, pow10(scale-d.scale))
+	}
+	return v
+}
+
+func align(a, b Decimal) (*big.Int, *big.Int, int32) {
+	scale := a.scale
+	if b.scale > scale {
+		scale = b.scale
+	}
+	return a.rescaled(scale), b.rescaled(scale), scale
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{coef: a.Add(a, b), scale: scale}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	a, b, scale := align(d, o)
+	return Decimal{coef: a.Sub(a, b), scale: scale}
+}
+
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{
+		coef:  new(big.Int).Mul(d.value(), o.value()),
+		scale: d.scale + o.scale,
+	}
+}
+
+// Div returns d / o rounded half away from zero to places decimals. It
+// panics if o is zero, like integer division.
+func (d Decimal) Div(o Decimal, places int32) Decimal {
+	if o.Sign() == 0 {
+		panic("decimal: division by zero")
+	}
+
+	// d/o = (dc * 10^(places+1+os-ds)) / oc * 10^-(places+1); the extra digit
+	// feeds the rounding step.
+	shift := places + 1 + o.scale - d.scale
+	num := new(big.Int).Set(d.value())
+	den := new(big.Int).Set(o.value())
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+
+	q := Decimal{coef: num.Quo(num, den), scale: places + 1}
+	return q.Round(places)
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.value()), scale: d.scale}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{coef: new(big.Int).Abs(d.value()), scale: d.scale}
+}
+
+// Round rounds half away from zero to places decimals.
+func (d Decimal) Round(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return d
+	}
+
+	divisor := pow10(d.scale - places)
+	q, r := new(big.Int).QuoRem(d.value(), divisor, new(big.Int))
+	if r.Abs(r).Lsh(r, 1).Cmp(divisor) >= 0 {
+		q.Add(q, big.NewInt(int64(d.Sign())))
+	}
+	return Decimal{coef: q, scale: places}
+}
+
+func (d Decimal) Truncate(places int32) Decimal {
+	if places < 0 {
+		places = 0
+	}
+	if places >= d.scale {
+		return d
+	}
+	q := new(big.Int).Quo(d.value(), pow10(d.scale-places))
+	return Decimal{coef: q, scale: places}
+}
+
+func (d Decimal) Cmp(o Decimal) int {
+	a, b, _ := align(d, o)
+	return a.Cmp(b)
+}
+
+func (d Decimal) Equal(o Decimal) bool {
+	return d.Cmp(o) == 0
+}
+
+func (d Decimal) GreaterThan(o Decimal) bool {
+	return d.Cmp(o) > 0
+}
+
+func (d Decimal) LessThan(o Decimal) bool {
+	return d.Cmp(o) < 0
+}
+
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String prints the exact value without trailing fractional zeros.
+func (d Decimal) String() string {
+	s := d.format(d.scale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// StringFixed rounds to places decimals and pads with zeros to exactly that
+// many.
+func (d Decimal) StringFixed(places int32) string {
+	if places < 0 {
+		places = 0
+	}
+	return d.Round(places).format(places)
+}
+
+func (d Decimal) format(places int32) string {
+	v := d.rescaled(places)
+	neg := v.Sign() < 0
+	digits := v.Abs(v).String()
+
+	if places > 0 {
+		if pad := int(places) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		split := len(digits) - int(places)
+		digits = digits[:split] + "." + digits[split:]
+	}
+
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+// UnmarshalJSON accepts both JSON strings, as the REST API and WebSocket v1
+// send them, and bare numbers, as WebSocket v2 does, without a float64
+// round trip.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Zero
+		return nil
+	}
+
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return err
+		}
+		s = unquoted
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func Sum(values ...Decimal) Decimal {
+	total := Zero
+	for _, v := range values {
+		total = total.Add(v)
+	}
+	return total
+}
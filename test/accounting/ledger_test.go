@@ -0,0 +1,260 @@
+package accounting_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/accounting"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func entry(id, refID, typ, asset, amount, fee, day string) models.LedgerEntry {
+	return models.LedgerEntry{
+		ID:     id,
+		RefID:  refID,
+		Type:   typ,
+		Asset:  asset,
+		Amount: decimal.MustParse(amount),
+		Fee:    decimal.MustParse(fee),
+		Time:   float64(date(day).Unix()),
+	}
+}
+
+// trade returns both ledger legs of a trade: amount of asset against quote
+// USD, with the fee charged on the USD side.
+func trade(refID, asset, amount, usd, fee, day string) []models.LedgerEntry {
+	return []models.LedgerEntry{
+		entry(refID+"-usd", refID, "trade", "ZUSD", usd, fee, day),
+		entry(refID+"-"+asset, refID, "trade", asset, amount, "0", day),
+	}
+}
+
+func btcLedger() []models.LedgerEntry {
+	var entries []models.LedgerEntry
+	entries = append(entries, trade("T1", "XXBT", "1", "-10000", "10", "2023-01-01")...)
+	entries = append(entries, trade("T2", "XXBT", "1", "-30000", "0", "2023-06-01")...)
+	entries = append(entries, trade("T3", "XXBT", "1", "-20000", "0", "2023-09-01")...)
+	entries = append(entries, trade("T4", "XXBT", "-1.5", "60000", "60", "2024-03-01")...)
+	return entries
+}
+
+func TestRealizeMethods(t *testing.T) {
+	tests := []struct {
+		method accounting.Method
+		cost   string
+		gain   string
+		rows   int
+	}{
+		{accounting.FIFO, "25010.00", "34930.00", 2},
+		{accounting.LIFO, "35000.00", "24940.00", 2},
+		{accounting.HIFO, "40000.00", "19940.00", 2},
+		{accounting.Average, "30005.00", "29935.00", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			report, err := accounting.Realize(btcLedger(), tt.method, accounting.Options{Quote: "ZUSD"})
+			require.NoError(t, err)
+
+			year := report.Year(2024)
+			assert.Equal(t, "59940.00", year.Proceeds.StringFixed(2))
+			assert.Equal(t, tt.cost, year.Cost.StringFixed(2))
+			assert.Equal(t, tt.gain, year.Gain.StringFixed(2))
+			assert.Len(t, report.Disposals, tt.rows)
+			assert.True(t, report.Year(2023).Gain.IsZero())
+
+			open := decimal.Zero
+			for _, lot := range report.Open["XXBT"] {
+				open = open.Add(lot.Quantity)
+			}
+			assert.Equal(t, "1.5", open.String())
+			assert.Empty(t, report.Warnings)
+		})
+	}
+}
+
+func TestRealizeFIFODisposalDates(t *testing.T) {
+	report, err := accounting.Realize(btcLedger(), accounting.FIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+	require.Len(t, report.Disposals, 2)
+
+	first, second := report.Disposals[0], report.Disposals[1]
+	assert.Equal(t, date("2023-01-01"), first.Acquired)
+	assert.Equal(t, date("2024-03-01"), first.Disposed)
+	assert.Equal(t, "1", first.Quantity.String())
+	assert.Equal(t, "39960", first.Proceeds.String())
+	assert.Equal(t, date("2023-06-01"), second.Acquired)
+	assert.Equal(t, "0.5", second.Quantity.String())
+	assert.Equal(t, "19980", second.Proceeds.String())
+}
+
+type fixedPrices map[string]string
+
+func (p fixedPrices) PriceAt(asset string, at time.Time) (decimal.Decimal, bool) {
+	price, ok := p[asset]
+	if !ok {
+		return decimal.Zero, false
+	}
+	return decimal.MustParse(price), true
+}
+
+func normalize(asset string) string {
+	return strings.TrimSuffix(asset, ".S")
+}
+
+func TestRealizeStakingRewardsAndTransfers(t *testing.T) {
+	entries := []models.LedgerEntry{
+		entry("L1", "R1", "transfer", "DOT", "-4", "0", "2023-01-01"),
+		entry("L2", "R2", "transfer", "DOT.S", "4", "0", "2023-01-01"),
+		entry("L3", "R3", "staking", "DOT.S", "10", "0", "2023-02-01"),
+	}
+	entries = append(entries, trade("T1", "DOT", "4", "-20", "0", "2022-12-01")...)
+	entries = append(entries, trade("T2", "DOT", "-14", "112", "0", "2024-01-10")...)
+
+	report, err := accounting.Realize(entries, accounting.FIFO, accounting.Options{
+		Quote:     "ZUSD",
+		Normalize: normalize,
+		Prices:    fixedPrices{"DOT": "5"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Income, 1)
+	assert.Equal(t, "50", report.Income[0].Value.String())
+	assert.Equal(t, "50.00", report.Year(2023).Income.StringFixed(2))
+
+	// 4 DOT bought for 20 and 10 rewarded at 5 each, sold for 8 apiece.
+	assert.Equal(t, "70.00", report.Year(2024).Cost.StringFixed(2))
+	assert.Equal(t, "42.00", report.Year(2024).Gain.StringFixed(2))
+	assert.Empty(t, report.Open)
+	assert.Empty(t, report.Warnings)
+	assert.Empty(t, report.UnpricedIn(0))
+}
+
+func TestRealizeWithdrawalsAndDeposits(t *testing.T) {
+	entries := trade("T1", "XETH", "1", "-1000", "0", "2023-01-01")
+	entries = append(entries, trade("T2", "XETH", "1", "-3000", "0", "2023-02-01")...)
+	entries = append(entries,
+		entry("L1", "W1", "withdrawal", "XETH", "-0.99", "0.01", "2023-03-01"),
+		entry("L2", "D1", "deposit", "XETH", "0.5", "0", "2023-04-01"),
+		entry("L3", "D2", "deposit", "ZUSD", "1000", "0", "2023-04-01"),
+	)
+
+	report, err := accounting.Realize(entries, accounting.FIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+
+	assert.Empty(t, report.Disposals)
+	open := report.Open["XETH"]
+	require.Len(t, open, 2)
+	assert.Equal(t, "1", open[0].Quantity.String())
+	assert.Equal(t, "3000", open[0].Cost.String())
+	assert.Equal(t, "0.5", open[1].Quantity.String())
+	assert.True(t, open[1].Cost.IsZero())
+	assert.Equal(t, []string{"XETH deposits have no price and were given a zero cost basis"}, report.Warnings)
+	// The deposit is still held, so no year's figures rest on it yet.
+	assert.Empty(t, report.UnpricedIn(0))
+}
+
+func TestRealizeUnpricedSwapCarriesBasis(t *testing.T) {
+	entries := trade("T1", "XXBT", "1", "-20000", "0", "2023-01-01")
+	entries = append(entries,
+		entry("L1", "S1", "trade", "XXBT", "-0.5", "0", "2023-05-01"),
+		entry("L2", "S1", "trade", "SOL", "400", "0", "2023-05-01"),
+	)
+	entries = append(entries, trade("T2", "SOL", "-400", "12000", "0", "2024-05-01")...)
+
+	report, err := accounting.Realize(entries, accounting.FIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+
+	assert.True(t, report.Year(2023).Gain.IsZero())
+	assert.Equal(t, "10000.00", report.Year(2024).Cost.StringFixed(2))
+	assert.Equal(t, "2000.00", report.Year(2024).Gain.StringFixed(2))
+	assert.Contains(t, report.Warnings, "XXBT to SOL swaps have no price; the cost basis was carried over")
+	assert.Equal(t, []string{"XXBT"}, report.UnpricedIn(2023))
+	assert.Equal(t, []string{"SOL"}, report.UnpricedIn(2024))
+	assert.Equal(t, []string{"SOL", "XXBT"}, report.UnpricedIn(0))
+}
+
+func TestRealizeUnpricedOnlyInYearsItReaches(t *testing.T) {
+	entries := []models.LedgerEntry{
+		entry("L1", "D1", "deposit", "XETH", "1", "0", "2020-01-01"),
+		entry("L2", "D2", "deposit", "SOL", "10", "0", "2020-01-01"),
+	}
+	entries = append(entries, trade("T1", "XETH", "1", "-3000", "0", "2023-01-01")...)
+	entries = append(entries, trade("T2", "XETH", "-1", "3500", "0", "2024-01-01")...)
+	entries = append(entries, trade("T3", "XETH", "-1", "4000", "0", "2025-01-01")...)
+
+	report, err := accounting.Realize(entries, accounting.HIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+
+	// HIFO sells the priced lot first, so only 2025 reaches the 2020
+	// deposit. The SOL was never sold.
+	assert.Empty(t, report.UnpricedIn(2020))
+	assert.Empty(t, report.UnpricedIn(2024))
+	assert.Equal(t, []string{"XETH"}, report.UnpricedIn(2025))
+	assert.Equal(t, []string{"XETH"}, report.UnpricedIn(0))
+}
+
+func TestRealizeOversell(t *testing.T) {
+	entries := trade("T1", "SOL", "1", "-100", "0", "2023-01-01")
+	entries = append(entries, trade("T2", "SOL", "-2", "300", "0", "2023-02-01")...)
+
+	report, err := accounting.Realize(entries, accounting.HIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+
+	require.Len(t, report.Disposals, 2)
+	assert.True(t, report.Disposals[1].Acquired.IsZero())
+	assert.Equal(t, "200.00", report.Year(2023).Gain.StringFixed(2))
+	assert.Len(t, report.Warnings, 1)
+}
+
+func TestParseMethod(t *testing.T) {
+	method, err := accounting.ParseMethod("HIFO")
+	assert.NoError(t, err)
+	assert.Equal(t, accounting.HIFO, method)
+
+	_, err = accounting.ParseMethod("lofo")
+	assert.Error(t, err)
+}
+
+func TestExport(t *testing.T) {
+	var reports []*accounting.Report
+	for _, method := range []accounting.Method{accounting.FIFO, accounting.HIFO} {
+		report, err := accounting.Realize(btcLedger(), method, accounting.Options{Quote: "ZUSD"})
+		require.NoError(t, err)
+		reports = append(reports, report)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, accounting.WriteCSV(&buf, reports, 2024))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, "method", rows[0][0])
+	assert.Equal(t, []string{"fifo", "XXBT", "T4", "2023-01-01", "2024-03-01", "1", "39960", "10010", "29950"}, rows[1])
+
+	buf.Reset()
+	require.NoError(t, accounting.WriteCSV(&buf, reports, 2023))
+	rows, err = csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, rows, 1)
+
+	buf.Reset()
+	require.NoError(t, accounting.WriteSummary(&buf, reports, 0))
+	assert.Contains(t, buf.String(), "34930.00")
+	assert.Contains(t, buf.String(), "19940.00")
+}
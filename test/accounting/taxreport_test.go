@@ -0,0 +1,95 @@
+package accounting_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/accounting"
+	"github.com/umit144/kraken-portfolio/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func displayName(asset string) string {
+	return strings.TrimPrefix(asset, "X")
+}
+
+func TestDisposalLongTerm(t *testing.T) {
+	d := accounting.Disposal{Acquired: date("2023-03-01"), Disposed: date("2024-03-01")}
+	assert.False(t, d.LongTerm())
+
+	d.Disposed = date("2024-03-02")
+	assert.True(t, d.LongTerm())
+	assert.Equal(t, "long", d.Term())
+
+	d.Acquired = date("0001-01-01")
+	assert.False(t, d.LongTerm())
+}
+
+func taxReport(t *testing.T) *accounting.TaxReport {
+	entries := trade("T0", "XXBT", "1", "-5000", "0", "2022-01-01")
+	entries = append(entries, btcLedger()...)
+	entries = append(entries, trade("T5", "XXBT", "-1", "15000", "0", "2024-04-01")...)
+
+	report, err := accounting.Realize(entries, accounting.FIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+
+	trades := []models.Trade{{TxID: "T4", OrderTxID: "O4", Pair: "XXBTZUSD"}}
+	return accounting.NewTaxReport(report, 2024, "USD", trades, displayName)
+}
+
+func TestNewTaxReport(t *testing.T) {
+	tr := taxReport(t)
+
+	// T4 sells the 2022 lot and half of the January 2023 lot; T5 sells the
+	// other half and half of the June lot, which is still short-term.
+	require.Len(t, tr.LongTerm, 3)
+	require.Len(t, tr.ShortTerm, 1)
+
+	assert.Equal(t, "1 XBT", tr.LongTerm[0].Description)
+	assert.Equal(t, "O4", tr.LongTerm[0].OrderTxID)
+	assert.Equal(t, "XXBTZUSD", tr.LongTerm[0].Pair)
+	assert.Equal(t, "0.5 XBT", tr.LongTerm[1].Description)
+	assert.Empty(t, tr.LongTerm[2].OrderTxID)
+	assert.Equal(t, date("2023-06-01"), tr.ShortTerm[0].Acquired)
+
+	assert.Equal(t, "15010.00", tr.LongTotal.Cost.StringFixed(2))
+	assert.Equal(t, "15000.00", tr.ShortTotal.Cost.StringFixed(2))
+	assert.Equal(t, "74940.00", tr.LongTotal.Proceeds.Add(tr.ShortTotal.Proceeds).StringFixed(2))
+}
+
+func TestWriteTaxCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, accounting.WriteTaxCSV(&buf, taxReport(t)))
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, "description", rows[0][0])
+	assert.Equal(t, "short", rows[1][6])
+	assert.Equal(t, []string{
+		"1 XBT", "2022-01-01", "2024-03-01", "39960", "5000", "34960",
+		"long", "XXBT", "1", "T4", "O4", "XXBTZUSD",
+	}, rows[2])
+}
+
+func TestWriteForm8949(t *testing.T) {
+	entries := trade("T1", "SOL", "1", "-100", "0", "2023-01-01")
+	entries = append(entries, trade("T2", "SOL", "-2", "150", "0", "2023-02-01")...)
+	report, err := accounting.Realize(entries, accounting.FIFO, accounting.Options{Quote: "ZUSD"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	tr := accounting.NewTaxReport(report, 2023, "USD", nil, displayName)
+	require.NoError(t, accounting.WriteForm8949(&buf, tr))
+
+	out := buf.String()
+	assert.Contains(t, out, "Part I - Short-Term")
+	assert.Contains(t, out, "Part II - Long-Term")
+	assert.Contains(t, out, "VARIOUS")
+	assert.Contains(t, out, "(25.00)")
+	assert.Contains(t, out, "Warnings:")
+}
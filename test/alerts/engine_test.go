@@ -0,0 +1,261 @@
+package alerts_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/alerts"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func prices(t time.Time, pairs ...string) alerts.Snapshot {
+	s := alerts.Snapshot{Time: t, Prices: make(map[string]decimal.Decimal)}
+	for i := 0; i < len(pairs); i += 2 {
+		s.Prices[pairs[i]] = decimal.MustParse(pairs[i+1])
+	}
+	return s
+}
+
+func mustRules(t *testing.T, s string) []alerts.Rule {
+	rules, err := alerts.ParseRules(s)
+	require.NoError(t, err)
+	return rules
+}
+
+func TestParseRules(t *testing.T) {
+	rules := mustRules(t, "btc>70000, ETH<2500,SOL~5%/1h,TOTAL>100000,TOTAL<50000,BTC>60%")
+	require.Len(t, rules, 6)
+
+	kinds := []alerts.Kind{alerts.Above, alerts.Below, alerts.Move, alerts.TotalAbove, alerts.TotalBelow, alerts.WeightAbove}
+	for i, kind := range kinds {
+		assert.Equal(t, kind, rules[i].Kind, rules[i].String())
+	}
+	assert.Equal(t, "BTC", rules[0].Asset)
+	assert.Equal(t, time.Hour, rules[2].Window)
+	assert.Equal(t, "SOL~5%/1h", rules[2].String())
+	assert.Equal(t, "BTC>60%", rules[5].String())
+
+	for _, bad := range []string{"BTC", "BTC>abc", "BTC>-5", ">100", "SOL~5%", "SOL~5%/soon", "TOTAL>50%", "BTC<10%"} {
+		_, err := alerts.ParseRules(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestEngineHysteresis(t *testing.T) {
+	engine := alerts.NewEngine(mustRules(t, "BTC>100"), alerts.Options{Hysteresis: decimal.MustParse("1")})
+
+	fired := engine.Evaluate(prices(start, "BTC", "101"))
+	require.Len(t, fired, 1)
+	assert.Equal(t, "BTC above 100: 101", fired[0].Message)
+
+	// Dipping to 99.5 stays within the 1% band, so 102 does not fire again.
+	assert.Empty(t, engine.Evaluate(prices(start.Add(time.Minute), "BTC", "99.5")))
+	assert.Empty(t, engine.Evaluate(prices(start.Add(2*time.Minute), "BTC", "102")))
+
+	assert.Empty(t, engine.Evaluate(prices(start.Add(3*time.Minute), "BTC", "98.9")))
+	assert.Len(t, engine.Evaluate(prices(start.Add(4*time.Minute), "BTC", "100.5")), 1)
+}
+
+func TestEngineCooldown(t *testing.T) {
+	engine := alerts.NewEngine(mustRules(t, "ETH<2500"), alerts.Options{Cooldown: 10 * time.Minute})
+
+	assert.Len(t, engine.Evaluate(prices(start, "ETH", "2400")), 1)
+	assert.Empty(t, engine.Evaluate(prices(start.Add(time.Minute), "ETH", "2600")))
+	assert.Empty(t, engine.Evaluate(prices(start.Add(5*time.Minute), "ETH", "2400")))
+	assert.Len(t, engine.Evaluate(prices(start.Add(11*time.Minute), "ETH", "2400")), 1)
+}
+
+func TestEngineMoveWithinWindow(t *testing.T) {
+	engine := alerts.NewEngine(mustRules(t, "SOL~5%/1h"), alerts.Options{})
+
+	assert.Empty(t, engine.Evaluate(prices(start, "SOL", "100")))
+	assert.Empty(t, engine.Evaluate(prices(start.Add(30*time.Minute), "SOL", "103")))
+
+	fired := engine.Evaluate(prices(start.Add(40*time.Minute), "SOL", "106"))
+	require.Len(t, fired, 1)
+	assert.Equal(t, "SOL moved +6.00% within 1h", fired[0].Message)
+
+	// Two hours on, the window starts at 106 and the rule re-arms; a drop of
+	// more than 5% from there fires again.
+	assert.Empty(t, engine.Evaluate(prices(start.Add(2*time.Hour), "SOL", "106")))
+	fired = engine.Evaluate(prices(start.Add(2*time.Hour+10*time.Minute), "SOL", "100"))
+	require.Len(t, fired, 1)
+	assert.Equal(t, "SOL moved -5.66% within 1h", fired[0].Message)
+}
+
+func TestEngineTotalAndWeight(t *testing.T) {
+	engine := alerts.NewEngine(mustRules(t, "TOTAL<50000,BTC>60%"), alerts.Options{})
+
+	// A partial portfolio has no total or weights yet.
+	assert.Empty(t, engine.Evaluate(alerts.Snapshot{Time: start}))
+
+	fired := engine.Evaluate(alerts.Snapshot{
+		Time:    start,
+		Total:   decimal.MustParse("40000"),
+		Weights: map[string]decimal.Decimal{"BTC": decimal.MustParse("62.5")},
+	})
+	require.Len(t, fired, 2)
+	assert.Equal(t, "Portfolio total below 50000: 40000.00", fired[0].Message)
+	assert.Equal(t, "BTC allocation above 60%: 62.5%", fired[1].Message)
+}
+
+func TestEngineWeightNormalizesAsset(t *testing.T) {
+	engine := alerts.NewEngine(mustRules(t, "BTC>60%"), alerts.Options{
+		Normalize: func(asset string) string { return map[string]string{"BTC": "XXBT"}[asset] },
+	})
+
+	fired := engine.Evaluate(alerts.Snapshot{
+		Time:    start,
+		Total:   decimal.MustParse("40000"),
+		Weights: map[string]decimal.Decimal{"XXBT": decimal.MustParse("62.5")},
+	})
+	require.Len(t, fired, 1)
+	assert.Equal(t, "BTC allocation above 60%: 62.5%", fired[0].Message)
+}
+
+func TestEngineAssets(t *testing.T) {
+	engine := alerts.NewEngine(mustRules(t, "BTC>1,BTC~1%/1m,TOTAL>1,ETH>50%,SOL<1"), alerts.Options{})
+	assert.Equal(t, []string{"BTC", "SOL"}, engine.Assets())
+}
+
+func TestEngineRunNotifies(t *testing.T) {
+	received := make(chan alerts.Alert, 1)
+	var buf bytes.Buffer
+	written := make(chan struct{})
+	writer := alerts.WriterNotifier{W: &buf}
+	engine := alerts.NewEngine(mustRules(t, "BTC>100"), alerts.Options{},
+		alerts.NotifierFunc(func(ctx context.Context, alert alerts.Alert) error {
+			defer close(written)
+			return writer.Notify(ctx, alert)
+		}),
+		alerts.NotifierFunc(func(_ context.Context, alert alerts.Alert) error {
+			received <- alert
+			return nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx)
+
+	engine.Evaluate(prices(start, "BTC", "150"))
+	select {
+	case alert := <-received:
+		assert.Equal(t, alerts.Above, alert.Rule.Kind)
+		assert.True(t, alert.Value.Equal(decimal.MustParse("150")))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the notifier")
+	}
+	select {
+	case <-written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the writer")
+	}
+	assert.Equal(t, "2024-03-01T12:00:00Z ALERT BTC above 100: 150\n", buf.String())
+}
+
+func TestEngineSlowNotifierDoesNotHoldUpOthers(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	received := make(chan alerts.Alert, 8)
+	engine := alerts.NewEngine(mustRules(t, "BTC>100"), alerts.Options{},
+		alerts.NotifierFunc(func(ctx context.Context, _ alerts.Alert) error {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return nil
+		}),
+		alerts.NotifierFunc(func(_ context.Context, alert alerts.Alert) error {
+			received <- alert
+			return nil
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx)
+
+	engine.Evaluate(prices(start, "BTC", "150"))
+	engine.Emit(alerts.EventDisconnected, "Lost the connection", start)
+	for _, want := range []alerts.EventType{alerts.EventAlert, alerts.EventDisconnected} {
+		select {
+		case alert := <-received:
+			assert.Equal(t, want, alert.Type)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout waiting for %s while another notifier is stuck", want)
+		}
+	}
+}
+
+func TestEngineEmitThrottlesAPIErrors(t *testing.T) {
+	received := make(chan alerts.Alert, 8)
+	engine := alerts.NewEngine(nil, alerts.Options{Cooldown: time.Minute}, alerts.NotifierFunc(func(_ context.Context, alert alerts.Alert) error {
+		received <- alert
+		return nil
+	}))
+
+	engine.Emit(alerts.EventAPIError, "Failed to refresh balances", start)
+	engine.Emit(alerts.EventAPIError, "Failed to refresh balances", start.Add(30*time.Second))
+	engine.Emit(alerts.EventAPIError, "WebSocket error", start.Add(30*time.Second))
+	engine.Emit(alerts.EventAPIError, "Failed to refresh balances", start.Add(2*time.Minute))
+	engine.Emit(alerts.EventDisconnected, "Lost the connection", start)
+	engine.Emit(alerts.EventDisconnected, "Lost the connection", start)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx)
+
+	var types []alerts.EventType
+	for len(types) < 5 {
+		select {
+		case alert := <-received:
+			types = append(types, alert.Type)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timeout after %d events", len(types))
+		}
+	}
+	assert.Equal(t, []alerts.EventType{
+		alerts.EventAPIError, alerts.EventAPIError, alerts.EventAPIError,
+		alerts.EventDisconnected, alerts.EventDisconnected,
+	}, types)
+	assert.Empty(t, received)
+}
+
+// logLines hands each line logged to it to the test.
+type logLines chan string
+
+func (l logLines) Write(p []byte) (int, error) {
+	l <- string(p)
+	return len(p), nil
+}
+
+func TestEngineLogsFailedNotifications(t *testing.T) {
+	lines := make(logLines, 4)
+	engine := alerts.NewEngine(nil, alerts.Options{Logger: log.New(lines, "", 0)},
+		alerts.NotifierFunc(func(context.Context, alerts.Alert) error {
+			return errors.New("webhook returned 500")
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go engine.Run(ctx)
+
+	engine.Emit(alerts.EventDisconnected, "Lost the connection", start)
+	select {
+	case line := <-lines:
+		assert.Equal(t, "Alert notification failed: webhook returned 500\n", line)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the failure to be logged")
+	}
+}
@@ -0,0 +1,134 @@
+package alerts_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/alerts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mail is what the stand-in server received in one session.
+type mail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP accepts one session on a local port, speaking just enough SMTP
+// for net/smtp, and returns its address.
+func serveSMTP(t *testing.T) (string, <-chan mail) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan mail, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP stand-in")
+
+		var m mail
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH PLAIN"):
+				decoded, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+				m.auth = string(decoded)
+				reply("235 Authentication successful")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				m.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				m.to = append(m.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				m.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				received <- m
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSMTPSendsAlert(t *testing.T) {
+	addr, received := serveSMTP(t)
+	notifier := &alerts.SMTP{
+		Addr:     addr,
+		From:     "tracker@example.com",
+		To:       []string{"me@example.com", "you@example.com"},
+		Username: "user",
+		Password: "pass",
+	}
+	require.NoError(t, notifier.Notify(context.Background(), sampleAlert(t)))
+
+	m := <-received
+	assert.Equal(t, "\x00user\x00pass", m.auth)
+	assert.Equal(t, "tracker@example.com", m.from)
+	assert.Equal(t, []string{"me@example.com", "you@example.com"}, m.to)
+	assert.Contains(t, m.data, "Subject: [kraken-portfolio] BTC above 70000: 70100\r\n")
+	assert.Contains(t, m.data, "To: me@example.com, you@example.com\r\n")
+	assert.Contains(t, m.data, "Rule: BTC>70000\r\n")
+}
+
+func TestSMTPEventSubject(t *testing.T) {
+	addr, received := serveSMTP(t)
+	notifier := &alerts.SMTP{Addr: addr, From: "tracker@example.com", To: []string{"me@example.com"}}
+	err := notifier.Notify(context.Background(), alerts.Alert{
+		Type:    alerts.EventAPIError,
+		Time:    start,
+		Message: "Failed to refresh balances: API error: [EAPI:Rate limit exceeded]\nsecond line",
+	})
+	require.NoError(t, err)
+
+	m := <-received
+	assert.Empty(t, m.auth)
+	assert.Contains(t, m.data, "Subject: [kraken-portfolio] api error: Failed to refresh balances: API error: [EAPI:Rate limit exceeded] second line\r\n")
+	assert.NotContains(t, m.data, "Rule:")
+}
+
+func TestSMTPConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	notifier := &alerts.SMTP{Addr: addr, From: "tracker@example.com", To: []string{"me@example.com"}}
+	assert.Error(t, notifier.Notify(context.Background(), sampleAlert(t)))
+}
@@ -0,0 +1,91 @@
+package alerts_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/alerts"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleAlert(t *testing.T) alerts.Alert {
+	return alerts.Alert{
+		Type:    alerts.EventAlert,
+		Rule:    mustRules(t, "BTC>70000")[0],
+		Value:   decimal.MustParse("70100"),
+		Time:    start,
+		Message: "BTC above 70000: 70100",
+	}
+}
+
+func TestWebhookRetriesAndSigns(t *testing.T) {
+	var attempts atomic.Int32
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get(alerts.SignatureHeader) != "sha256="+alerts.Sign("s3cret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		bodies <- body
+	}))
+	defer server.Close()
+
+	webhook := alerts.NewWebhook(server.URL, "s3cret")
+	webhook.Backoff = time.Millisecond
+	require.NoError(t, webhook.Notify(context.Background(), sampleAlert(t)))
+	assert.Equal(t, int32(3), attempts.Load())
+
+	var payload alerts.Payload
+	require.NoError(t, json.Unmarshal(<-bodies, &payload))
+	assert.Equal(t, alerts.EventAlert, payload.Type)
+	assert.Equal(t, "BTC>70000", payload.Rule)
+	assert.Equal(t, "BTC", payload.Asset)
+	assert.Equal(t, "70100", payload.Value)
+	assert.True(t, payload.Time.Equal(start))
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := alerts.NewWebhook(server.URL+"/bad", "")
+	webhook.Backoff = time.Millisecond
+	assert.Error(t, webhook.Notify(context.Background(), sampleAlert(t)))
+	assert.Equal(t, int32(1), attempts.Load(), "4xx responses are not retried")
+
+	attempts.Store(0)
+	webhook = alerts.NewWebhook(server.URL, "")
+	webhook.Backoff = time.Millisecond
+	webhook.Retries = 2
+	assert.Error(t, webhook.Notify(context.Background(), sampleAlert(t)))
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestWebhookEventPayload(t *testing.T) {
+	payload := alerts.NewPayload(alerts.Alert{Type: alerts.EventDisconnected, Time: start, Message: "Lost the connection"})
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"disconnected","time":"2024-03-01T12:00:00Z","message":"Lost the connection"}`, string(data))
+}
@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/alerts"
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestGetAssetValuesWeightsAndDrift(t *testing.T) {
+	client := api.NewClient(&config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: "test-secret",
+		TargetWeights: map[string]decimal.Decimal{
+			"BTC": decimal.MustParse("50"),
+			"SOL": decimal.MustParse("40"),
+			"USD": decimal.MustParse("10"),
+		},
+	})
+	client.SetRegistry(newQuoteRegistry())
+	client.SetBalances(map[string]decimal.Decimal{
+		"XXBT":  decimal.MustParse("0.1"),
+		"SOL":   decimal.MustParse("10"),
+		"SOL.S": decimal.MustParse("10"),
+		"ZUSD":  decimal.MustParse("2000"),
+	})
+	client.UpdatePrice("XBT/USD", decimal.MustParse("60000"))
+	client.UpdatePrice("SOL/USD", decimal.MustParse("100"))
+
+	assets := make(map[string]models.AssetValue)
+	for _, asset := range client.GetAssetValues() {
+		assets[asset.Asset] = asset
+	}
+
+	// 6000 in BTC, 1000 each in SOL and SOL.S and 2000 in cash.
+	want := map[string]struct{ weight, drift string }{
+		"XBT":   {"60", "10"},
+		"SOL":   {"10", "-20"},
+		"SOL.S": {"10", "-20"},
+		"USD":   {"20", "10"},
+	}
+	for name, w := range want {
+		asset, ok := assets[name]
+		if !ok {
+			t.Fatalf("Missing %s in %+v", name, assets)
+		}
+		if !asset.HasTarget {
+			t.Errorf("%s: expected a target", name)
+		}
+		if !asset.Weight.Equal(decimal.MustParse(w.weight)) {
+			t.Errorf("%s: got weight %v, want %v", name, asset.Weight, w.weight)
+		}
+		if !asset.Drift.Equal(decimal.MustParse(w.drift)) {
+			t.Errorf("%s: got drift %v, want %v", name, asset.Drift, w.drift)
+		}
+	}
+}
+
+func TestConnectRejectsUnknownTargetAsset(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	cfg.TargetWeights = map[string]decimal.Decimal{"NOPE": decimal.MustParse("10")}
+	client := api.NewClient(cfg)
+	defer client.Close()
+
+	if err := client.Connect(context.Background()); !errors.Is(err, api.ErrUnknownTargetAsset) {
+		t.Errorf("Expected ErrUnknownTargetAsset, got %v", err)
+	}
+}
+
+func TestWeightRulesUseRegistryKeys(t *testing.T) {
+	client := api.NewClient(&config.Config{ApiKey: "test-key", ApiSecret: "test-secret"})
+	client.SetRegistry(newTestRegistry())
+	client.SetBalances(map[string]decimal.Decimal{
+		"XXBT":  decimal.MustParse("0.1"),
+		"DOT":   decimal.MustParse("100"),
+		"DOT.S": decimal.MustParse("300"),
+		"ZUSD":  decimal.MustParse("2000"),
+	})
+
+	if _, _, ok := client.Weights(); ok {
+		t.Fatal("Expected no weights before every holding is priced")
+	}
+	client.UpdatePrice("XBT/USD", decimal.MustParse("60000"))
+	client.UpdatePrice("DOT/USD", decimal.MustParse("5"))
+
+	// 6000 in BTC, 500 in DOT and 1500 staked, and 2000 in cash.
+	weights, total, ok := client.Weights()
+	if !ok {
+		t.Fatal("Expected weights once every holding is priced")
+	}
+	if !total.Equal(decimal.MustParse("10000")) {
+		t.Errorf("got total %v, want 10000", total)
+	}
+	for key, want := range map[string]string{"XXBT": "60", "DOT": "20", "ZUSD": "20"} {
+		if !weights[key].Equal(decimal.MustParse(want)) {
+			t.Errorf("%s: got weight %v, want %v", key, weights[key], want)
+		}
+	}
+
+	rules, err := alerts.ParseRules("BTC>55%,DOT>18%,XBT>65%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := alerts.NewEngine(rules, alerts.Options{Normalize: client.ResolveAsset})
+	fired := engine.Evaluate(alerts.Snapshot{Time: time.Now(), Total: total, Weights: weights})
+	if len(fired) != 2 || fired[0].Rule.Asset != "BTC" || fired[1].Rule.Asset != "DOT" {
+		t.Errorf("Expected the BTC and DOT rules to fire, got %+v", fired)
+	}
+}
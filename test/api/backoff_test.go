@@ -0,0 +1,40 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+)
+
+func TestBackoffGrowth(t *testing.T) {
+	backoff := api.NewBackoff(time.Second, 10*time.Second)
+
+	ceilings := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	}
+
+	for i, ceiling := range ceilings {
+		got := backoff.Next()
+		if got < ceiling/2 || got > ceiling {
+			t.Errorf("attempt %d: got %v, want between %v and %v", i, got, ceiling/2, ceiling)
+		}
+	}
+}
+
+func TestBackoffReset(t *testing.T) {
+	backoff := api.NewBackoff(time.Second, time.Minute)
+	for i := 0; i < 5; i++ {
+		backoff.Next()
+	}
+
+	backoff.Reset()
+	if got := backoff.Next(); got > time.Second {
+		t.Errorf("got %v after reset, want at most %v", got, time.Second)
+	}
+}
@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/krakenfake"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestStreamingDailyAndSessionChange(t *testing.T) {
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			fake, client := newFakeClientVersion(t, version)
+			defer fake.Close()
+
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer client.Close()
+			if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			rendered := make(chan string, 16)
+			go client.StartStreaming(context.Background(), renderTo(rendered))
+
+			stats := krakenfake.TickerStats{Last: "3000", Open: "2500", Low: "2400", High: "3100"}
+			if err := fake.PushTickerStats("ETH/USD", stats); err != nil {
+				t.Fatal(err)
+			}
+			waitForRender(t, rendered, "+20.00%")
+
+			stats.Last, stats.High = "3300", "3300"
+			if err := fake.PushTickerStats("ETH/USD", stats); err != nil {
+				t.Fatal(err)
+			}
+			waitForRender(t, rendered, "+10.00%")
+
+			var eth models.AssetValue
+			for _, asset := range client.GetAssetValues() {
+				if asset.Asset == "ETH" {
+					eth = asset
+				}
+			}
+			checks := map[string]decimal.Decimal{
+				"Open24h":          eth.Open24h,
+				"Change24hPct":     eth.Change24hPct,
+				"Low24h":           eth.Low24h,
+				"High24h":          eth.High24h,
+				"SessionOpen":      eth.SessionOpen,
+				"SessionChangePct": eth.SessionChangePct,
+			}
+			want := map[string]string{
+				"Open24h":          "2500",
+				"Change24hPct":     "32",
+				"Low24h":           "2400",
+				"High24h":          "3300",
+				"SessionOpen":      "3000",
+				"SessionChangePct": "10",
+			}
+			for field, got := range checks {
+				if !got.Equal(decimal.MustParse(want[field])) {
+					t.Errorf("%s: got %v, want %v", field, got, want[field])
+				}
+			}
+		})
+	}
+}
+
+func TestChangesThroughInvertedAndTwoLegRoutes(t *testing.T) {
+	client := api.NewClient(&config.Config{
+		ApiKey:        "test-key",
+		ApiSecret:     "test-secret",
+		QuoteCurrency: "EUR",
+	})
+	client.SetRegistry(newQuoteRegistry())
+	client.SetBalances(map[string]decimal.Decimal{
+		"SOL":  decimal.MustParse("10"),
+		"ZUSD": decimal.MustParse("100"),
+	})
+
+	client.UpdateTicker(models.Ticker{
+		Symbol: "EUR/USD",
+		Last:   decimal.MustParse("1.25"),
+		Open:   decimal.MustParse("1.25"),
+		Low:    decimal.MustParse("1"),
+		High:   decimal.MustParse("1.25"),
+	})
+	client.UpdateTicker(models.Ticker{
+		Symbol: "SOL/USD",
+		Last:   decimal.MustParse("150"),
+		Open:   decimal.MustParse("100"),
+		Low:    decimal.MustParse("90"),
+		High:   decimal.MustParse("160"),
+	})
+
+	assets := make(map[string]models.AssetValue)
+	for _, asset := range client.GetAssetValues() {
+		assets[asset.Asset] = asset
+	}
+
+	usd := assets["USD"]
+	if !usd.Low24h.Equal(decimal.MustParse("0.8")) || !usd.High24h.Equal(decimal.One) {
+		t.Errorf("USD: got range %v-%v, want 0.8-1", usd.Low24h, usd.High24h)
+	}
+
+	sol := assets["SOL"]
+	if !sol.Open24h.Equal(decimal.MustParse("80")) {
+		t.Errorf("SOL: got open %v, want 80", sol.Open24h)
+	}
+	if !sol.Change24hPct.Equal(decimal.MustParse("50")) {
+		t.Errorf("SOL: got change %v%%, want 50%%", sol.Change24hPct)
+	}
+	if !sol.High24h.IsZero() || !sol.Low24h.IsZero() {
+		t.Errorf("SOL: expected no range through two pairs, got %v-%v", sol.Low24h, sol.High24h)
+	}
+}
+
+func TestV2TickerWithoutChangeLeavesOpenUnknown(t *testing.T) {
+	fake, client := newFakeClientVersion(t, "v2")
+	defer fake.Close()
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := make(chan string, 16)
+	go client.StartStreaming(context.Background(), renderTo(rendered))
+
+	frame := map[string]interface{}{
+		"channel": "ticker",
+		"type":    "update",
+		"data":    []map[string]interface{}{{"symbol": "ETH/USD", "last": 3000}},
+	}
+	if err := fake.PushFrame("ETH/USD", frame); err != nil {
+		t.Fatal(err)
+	}
+	waitForRender(t, rendered, "TOTAL VALUE: $6100.00")
+
+	for _, asset := range client.GetAssetValues() {
+		if asset.Asset == "ETH" && (!asset.Open24h.IsZero() || !asset.Change24hPct.IsZero()) {
+			t.Errorf("Expected no 24h open without a change, got open %v, change %v%%", asset.Open24h, asset.Change24hPct)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/krakenfake"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func trade(pair, side string, time float64, vol, cost, fee string) models.Trade {
+	return models.Trade{
+		Pair: pair,
+		Type: side,
+		Time: time,
+		Vol:  decimal.MustParse(vol),
+		Cost: decimal.MustParse(cost),
+		Fee:  decimal.MustParse(fee),
+	}
+}
+
+func loadCostBasis(t *testing.T, fake *krakenfake.Server, client *api.Client) {
+	t.Helper()
+	ctx := context.Background()
+	if err := client.LoadRegistry(ctx); err != nil {
+		t.Fatalf("LoadRegistry failed: %v", err)
+	}
+	if err := client.GetBalances(ctx); err != nil {
+		t.Fatalf("GetBalances failed: %v", err)
+	}
+	if err := client.LoadCostBasis(ctx); err != nil {
+		t.Fatalf("LoadCostBasis failed: %v", err)
+	}
+}
+
+func TestCostBasisAverageCost(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	fake.AddTrade("T1", trade("XETHZUSD", "buy", 1700000000, "1", "2000", "5"))
+	fake.AddTrade("T2", trade("XETHZUSD", "buy", 1700000100, "2", "6000", "10"))
+	fake.AddTrade("T3", trade("XETHZUSD", "sell", 1700000200, "1", "3500", "5"))
+	loadCostBasis(t, fake, client)
+
+	client.UpdatePrice("ETH/USD", decimal.MustParse("3000"))
+
+	var eth models.AssetValue
+	for _, asset := range client.GetAssetValues() {
+		if asset.Asset == "ETH" {
+			eth = asset
+		}
+	}
+
+	// 3 ETH bought for 8015 including fees; selling one leaves the average
+	// at 8015 / 3 on the 2 ETH the fake holds.
+	if got := eth.AvgCost.StringFixed(4); got != "2671.6667" {
+		t.Errorf("got average cost %v", got)
+	}
+	if got := eth.CostBasis.StringFixed(2); got != "5343.33" {
+		t.Errorf("got cost basis %v", got)
+	}
+	if got := eth.UnrealizedPnL.StringFixed(2); got != "656.67" {
+		t.Errorf("got unrealized P&L %v", got)
+	}
+	if got := eth.PnLPct.StringFixed(2); got != "12.29" {
+		t.Errorf("got P&L %% %v", got)
+	}
+
+	lots := client.Lots()["XETH"]
+	if len(lots) != 2 {
+		t.Fatalf("Expected 2 open lots, got %+v", lots)
+	}
+	if got := lots[0].Quantity.Add(lots[1].Quantity).String(); got != "2" {
+		t.Errorf("got open quantity %v, want 2", got)
+	}
+}
+
+func TestCostBasisDisposals(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	fake.AddPair("SOLXBT", models.AssetPair{Altname: "SOLXBT", WsName: "SOL/XBT", Base: "SOL", Quote: "XXBT"})
+	fake.AddTrade("T1", trade("XXBTZUSD", "buy", 1700000000, "1", "50000", "0"))
+	fake.AddTrade("T2", trade("SOLXBT", "buy", 1700000100, "10", "0.5", "0"))
+	fake.AddTrade("T3", trade("XETHZUSD", "buy", 1700000200, "1", "2000", "0"))
+	fake.AddTrade("T4", trade("XETHZUSD", "sell", 1700000300, "3", "6000", "0"))
+	loadCostBasis(t, fake, client)
+
+	lots := client.Lots()
+	if got := lots["XXBT"]; len(got) != 1 || got[0].Quantity.String() != "0.5" || got[0].Cost.String() != "25000" {
+		t.Errorf("Expected paying for SOL to halve the XBT lot, got %+v", got)
+	}
+	if _, ok := lots["SOL"]; ok {
+		t.Errorf("Expected no lot for SOL bought with XBT, got %+v", lots["SOL"])
+	}
+	if _, ok := lots["XETH"]; ok {
+		t.Errorf("Expected overselling to close the ETH lots, got %+v", lots["XETH"])
+	}
+}
+
+func TestFetchHistoryPages(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	total := krakenfake.PageSize*2 + 7
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("T%03d", i)
+		fake.AddTrade(id, trade("SOLUSD", "buy", float64(1700000000+i), "1", "100", "0"))
+		fake.AddLedgerEntry("L"+id, models.LedgerEntry{
+			RefID:  id,
+			Time:   float64(1700000000 + i),
+			Type:   "trade",
+			Asset:  "SOL",
+			Amount: decimal.One,
+		})
+	}
+
+	trades, err := client.FetchTrades(context.Background())
+	if err != nil {
+		t.Fatalf("FetchTrades failed: %v", err)
+	}
+	if len(trades) != total {
+		t.Fatalf("got %d trades, want %d", len(trades), total)
+	}
+	if trades[0].TxID != "T000" || trades[total-1].TxID != fmt.Sprintf("T%03d", total-1) {
+		t.Errorf("Expected trades oldest first, got %s ... %s", trades[0].TxID, trades[total-1].TxID)
+	}
+
+	entries, err := client.FetchLedgers(context.Background())
+	if err != nil {
+		t.Fatalf("FetchLedgers failed: %v", err)
+	}
+	if len(entries) != total || entries[0].ID != "LT000" || entries[0].RefID != "T000" {
+		t.Errorf("Unexpected ledger entries: %d, first %+v", len(entries), entries[0])
+	}
+}
+
+func TestUpdateLedgersFetchesOnlyNewEntries(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	var starts []string
+	ledgers := fake.Handler("Ledgers")
+	fake.HandlePrivate("Ledgers", func(form url.Values) (interface{}, error) {
+		starts = append(starts, form.Get("start"))
+		return ledgers(form)
+	})
+	deposit := func(id string, at float64) {
+		fake.AddLedgerEntry(id, models.LedgerEntry{RefID: "R" + id, Time: at, Type: "deposit", Asset: "ZUSD", Amount: decimal.One})
+	}
+	deposit("L1", 1700000000)
+	deposit("L2", 1700000100)
+
+	entries, err := client.UpdateLedgers(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("UpdateLedgers failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	// L3 shares L2's second, so it is only found by asking from before it.
+	deposit("L3", 1700000100)
+	deposit("L4", 1700000200)
+	entries, err = client.UpdateLedgers(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("UpdateLedgers failed: %v", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+	}
+	if fmt.Sprint(ids) != "[L1 L2 L3 L4]" {
+		t.Errorf("Expected every entry once, oldest first, got %v", ids)
+	}
+	if len(starts) != 2 || starts[0] != "" || starts[1] != "1700000099" {
+		t.Errorf("Expected a full fetch then one from before the last entry, got starts %q", starts)
+	}
+}
@@ -0,0 +1,57 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestFetchOrderBook(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	level := func(price, volume string) models.BookLevel {
+		return models.BookLevel{Price: decimal.MustParse(price), Volume: decimal.MustParse(volume)}
+	}
+	fake.SetBook("ETH/USD", models.OrderBook{
+		Bids: []models.BookLevel{level("2999.5", "1.5"), level("2999", "3"), level("2998", "10")},
+		Asks: []models.BookLevel{level("3000.5", "0.25"), level("3001", "2")},
+	})
+
+	book, err := client.FetchOrderBook(context.Background(), "ETH/USD", 2)
+	if err != nil {
+		t.Fatalf("FetchOrderBook failed: %v", err)
+	}
+	if book.Pair != "ETH/USD" {
+		t.Errorf("Expected the book named by wsname, got %q", book.Pair)
+	}
+	if len(book.Bids) != 2 || len(book.Asks) != 2 {
+		t.Fatalf("Expected 2 levels a side, got %d bids and %d asks", len(book.Bids), len(book.Asks))
+	}
+	if !book.Bids[0].Price.Equal(decimal.MustParse("2999.5")) || !book.Asks[0].Volume.Equal(decimal.MustParse("0.25")) {
+		t.Errorf("Unexpected top of book: %+v / %+v", book.Bids[0], book.Asks[0])
+	}
+
+	if _, err := client.FetchOrderBook(context.Background(), "DOGE/USD", 2); err == nil {
+		t.Error("Expected an error for an unknown pair")
+	}
+}
+
+func TestAssetValuesCarryPairAndVolume(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	client.SetBalances(map[string]decimal.Decimal{"XETH": decimal.MustParse("1")})
+	client.UpdateTicker(models.Ticker{
+		Symbol: "ETH/USD",
+		Last:   decimal.MustParse("3000"),
+		Volume: decimal.MustParse("1234.5"),
+	})
+
+	eth := client.GetAssetValues()[0]
+	if eth.Pair != "ETH/USD" || !eth.Volume24h.Equal(decimal.MustParse("1234.5")) {
+		t.Errorf("got pair %q and volume %v, want ETH/USD and 1234.5", eth.Pair, eth.Volume24h)
+	}
+}
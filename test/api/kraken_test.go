@@ -1,15 +1,21 @@
 package api_test
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/umit144/kraken-portfolio/internal/api"
 	"github.com/umit144/kraken-portfolio/internal/config"
 	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
 )
 
 func TestNewClient(t *testing.T) {
@@ -22,15 +28,15 @@ func TestNewClient(t *testing.T) {
 	if client == nil {
 		t.Error("Expected non-nil client")
 	}
-	if client.Prices == nil {
+	if client.Prices() == nil {
 		t.Error("Expected non-nil prices map")
 	}
-	if client.PrevPrices == nil {
-		t.Error("Expected non-nil previous prices map")
-	}
-	if client.Balances == nil {
+	if client.Balances() == nil {
 		t.Error("Expected non-nil balances map")
 	}
+	if client.Registry() == nil {
+		t.Error("Expected non-nil registry")
+	}
 }
 
 func TestGenerateSignature(t *testing.T) {
@@ -59,18 +65,18 @@ func TestUpdatePrice(t *testing.T) {
 
 	tests := []struct {
 		name     string
-		price    float64
-		expected float64
+		price    string
+		expected string
 	}{
-		{"initial price", 3000.0, 3000.0},
-		{"price increase", 3100.0, 3100.0},
-		{"price decrease", 2900.0, 2900.0},
+		{"initial price", "3000.0", "3000"},
+		{"price increase", "3100.5", "3100.5"},
+		{"price decrease", "2900.25", "2900.25"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client.UpdatePrice(pair, tt.price)
-			got := client.GetPrice(pair)
+			client.UpdatePrice(pair, decimal.MustParse(tt.price))
+			got := client.GetPrice(pair).String()
 			if got != tt.expected {
 				t.Errorf("got %v, want %v", got, tt.expected)
 			}
@@ -87,18 +93,18 @@ func TestPricePrevTracking(t *testing.T) {
 	client := api.NewClient(cfg)
 	pair := "ETH/USD"
 
-	initialPrice := 3000.0
+	initialPrice := decimal.MustParse("3000")
 	client.UpdatePrice(pair, initialPrice)
-	if got := client.GetPrice(pair); got != initialPrice {
+	if got := client.GetPrice(pair); !got.Equal(initialPrice) {
 		t.Errorf("got %v, want %v", got, initialPrice)
 	}
 
-	newPrice := 3100.0
+	newPrice := decimal.MustParse("3100")
 	client.UpdatePrice(pair, newPrice)
-	if got := client.GetPrice(pair); got != newPrice {
+	if got := client.GetPrice(pair); !got.Equal(newPrice) {
 		t.Errorf("got %v, want %v", got, newPrice)
 	}
-	if got := client.PrevPrices[pair]; got != initialPrice {
+	if got := client.GetPrevPrice(pair); !got.Equal(initialPrice) {
 		t.Errorf("got %v, want %v", got, initialPrice)
 	}
 }
@@ -111,14 +117,14 @@ func TestGetAssetValues(t *testing.T) {
 
 	client := api.NewClient(cfg)
 
-	client.Balances = map[string]float64{
-		"XETH": 2.5,
-		"SOL":  10.0,
-		"ZUSD": 1000.0,
-	}
+	client.SetBalances(map[string]decimal.Decimal{
+		"XETH": decimal.MustParse("2.5"),
+		"SOL":  decimal.MustParse("10"),
+		"ZUSD": decimal.MustParse("1000"),
+	})
 
-	client.UpdatePrice("ETH/USD", 3000.0)
-	client.UpdatePrice("SOL/USD", 100.0)
+	client.UpdatePrice("ETH/USD", decimal.MustParse("3000"))
+	client.UpdatePrice("SOL/USD", decimal.MustParse("100"))
 
 	assets := client.GetAssetValues()
 	if len(assets) == 0 {
@@ -131,21 +137,27 @@ func TestGetAssetValues(t *testing.T) {
 	}
 
 	eth := assetMap["ETH"]
-	if eth.Balance != 2.5 || eth.Price != 3000.0 || eth.USDValue != 7500.0 {
+	if !assetEquals(eth, "2.5", "3000", "7500") {
 		t.Errorf("Unexpected ETH values: %+v", eth)
 	}
 
 	sol := assetMap["SOL"]
-	if sol.Balance != 10.0 || sol.Price != 100.0 || sol.USDValue != 1000.0 {
+	if !assetEquals(sol, "10", "100", "1000") {
 		t.Errorf("Unexpected SOL values: %+v", sol)
 	}
 
 	usd := assetMap["USD"]
-	if usd.Balance != 1000.0 || usd.Price != 1.0 || usd.USDValue != 1000.0 {
+	if !assetEquals(usd, "1000", "1", "1000") {
 		t.Errorf("Unexpected USD values: %+v", usd)
 	}
 }
 
+func assetEquals(asset models.AssetValue, balance, price, value string) bool {
+	return asset.Balance.Equal(decimal.MustParse(balance)) &&
+		asset.Price.Equal(decimal.MustParse(price)) &&
+		asset.Value.Equal(decimal.MustParse(value))
+}
+
 func TestConnect(t *testing.T) {
 	if os.Getenv("INTEGRATION_TEST") != "true" {
 		t.Skip("Skipping integration test")
@@ -157,15 +169,28 @@ func TestConnect(t *testing.T) {
 	}
 
 	client := api.NewClient(cfg)
-	err := client.Connect()
+	priced := make(chan struct{}, 1)
+	client.OnPriceUpdate = func() {
+		select {
+		case priced <- struct{}{}:
+		default:
+		}
+	}
+	err := client.Connect(context.Background())
 	if err != nil {
-		t.Errorf("Connect failed: %v", err)
+		t.Fatalf("Connect failed: %v", err)
 	}
 	defer client.Close()
 
-	time.Sleep(2 * time.Second)
-	if client.WsConn == nil {
-		t.Error("Expected non-nil WebSocket connection")
+	// Only a live socket delivers prices; the state alone could be stale.
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	select {
+	case <-priced:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timeout waiting for a price from the WebSocket")
+	}
+	if client.State() != models.StateConnected {
+		t.Errorf("Expected connected state, got %v", client.State())
 	}
 }
 
@@ -180,7 +205,7 @@ func TestStreamingUpdates(t *testing.T) {
 	}
 
 	client := api.NewClient(cfg)
-	err := client.Connect()
+	err := client.Connect(context.Background())
 	if err != nil {
 		t.Fatalf("Connect failed: %v", err)
 	}
@@ -188,7 +213,7 @@ func TestStreamingUpdates(t *testing.T) {
 
 	updates := make(chan []models.AssetValue, 1)
 	go func() {
-		client.StartStreaming(func(assets []models.AssetValue) {
+		client.StartStreaming(context.Background(), func(assets []models.AssetValue) {
 			select {
 			case updates <- assets:
 			default:
@@ -230,14 +255,14 @@ func TestUSDHandling(t *testing.T) {
 	}
 
 	client := api.NewClient(cfg)
-	client.Balances["ZUSD"] = 1000.0
+	client.SetBalances(map[string]decimal.Decimal{"ZUSD": decimal.MustParse("1000")})
 
 	assets := client.GetAssetValues()
 	var foundUSD bool
 	for _, asset := range assets {
 		if asset.Asset == "USD" {
 			foundUSD = true
-			if asset.Balance != 1000.0 || asset.Price != 1.0 || asset.USDValue != 1000.0 {
+			if !assetEquals(asset, "1000", "1", "1000") {
 				t.Errorf("Unexpected USD values: %+v", asset)
 			}
 			break
@@ -248,3 +273,95 @@ func TestUSDHandling(t *testing.T) {
 		t.Error("USD asset not found in results")
 	}
 }
+
+func TestGetBalancesFromConfiguredEndpoint(t *testing.T) {
+	var gotPath, gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("API-Key")
+		fmt.Fprint(w, `{"error":[],"result":{"XETH":"2.5","ZUSD":"100.0","DOT":"0.0"}}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: base64.StdEncoding.EncodeToString([]byte("test-secret")),
+		RestURL:   server.URL,
+	}
+
+	client := api.NewClient(cfg, api.WithHTTPClient(server.Client()))
+	if err := client.GetBalances(context.Background()); err != nil {
+		t.Fatalf("GetBalances failed: %v", err)
+	}
+
+	if gotPath != "/0/private/Balance" {
+		t.Errorf("got path %v, want /0/private/Balance", gotPath)
+	}
+	if gotKey != "test-key" {
+		t.Errorf("got API-Key %v, want test-key", gotKey)
+	}
+	if balances := client.Balances(); len(balances) != 2 || balances["XETH"].String() != "2.5" {
+		t.Errorf("Unexpected balances: %+v", balances)
+	}
+}
+
+func TestRestURLOptionOverridesConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error":["EAPI:Invalid key"]}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: "test-secret",
+		RestURL:   "http://127.0.0.1:0",
+	}
+
+	client := api.NewClient(cfg, api.WithRestURL(server.URL+"/"))
+	err := client.GetBalances(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "EAPI:Invalid key") {
+		t.Errorf("Expected API error from configured server, got %v", err)
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	cfg := &config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: "test-secret",
+	}
+
+	client := api.NewClient(cfg)
+	client.SetBalances(map[string]decimal.Decimal{"XETH": decimal.One, "ZUSD": decimal.NewFromInt(100)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				client.UpdatePrice("ETH/USD", decimal.NewFromInt(int64(3000+i+j)))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				client.GetAssetValues()
+				client.Prices()
+				client.GetPrevPrice("ETH/USD")
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				balances := client.Balances()
+				balances["SOL"] = decimal.NewFromInt(int64(i + j))
+				client.SetBalances(balances)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := client.Balances()["XETH"]; !got.Equal(decimal.One) {
+		t.Errorf("got %v, want 1.0", got)
+	}
+}
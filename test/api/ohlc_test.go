@@ -0,0 +1,46 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestFetchOHLC(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []models.Candle
+	for i := 0; i < 800; i++ {
+		price := decimal.NewFromInt(int64(1000 + i))
+		candles = append(candles, models.Candle{
+			Time: start.AddDate(0, 0, i), Open: price, High: price, Low: price, Close: price,
+		})
+	}
+	fake.SetCandles("ETH/USD", candles)
+
+	got, err := client.FetchOHLC(context.Background(), "ETHUSD", 24*time.Hour, start.AddDate(0, 0, 797))
+	if err != nil {
+		t.Fatalf("FetchOHLC failed: %v", err)
+	}
+	if len(got) != 2 || !got[0].Time.Equal(start.AddDate(0, 0, 798)) || got[1].Close.String() != "1799" {
+		t.Errorf("Unexpected candles after since: %+v", got)
+	}
+
+	// Only the latest candles are served, however far back since goes.
+	got, err = client.FetchOHLC(context.Background(), "ETHUSD", 24*time.Hour, time.Time{})
+	if err != nil {
+		t.Fatalf("FetchOHLC failed: %v", err)
+	}
+	if len(got) != 720 || got[0].Close.String() != "1080" {
+		t.Errorf("Expected the last 720 candles, got %d from %s", len(got), got[0].Close)
+	}
+
+	if _, err := client.FetchOHLC(context.Background(), "DOGEUSD", 24*time.Hour, time.Time{}); err == nil {
+		t.Error("Expected an error for an unknown pair")
+	}
+}
@@ -0,0 +1,210 @@
+package api_test
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func placeLimit(t *testing.T, client *api.Client, pair, side, volume, price string) string {
+	t.Helper()
+	result, err := client.AddOrder(context.Background(), models.OrderRequest{
+		Pair:      pair,
+		Type:      side,
+		OrderType: "limit",
+		Volume:    decimal.MustParse(volume),
+		Price:     decimal.MustParse(price),
+	})
+	if err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	return result.TxID[0]
+}
+
+func TestLoadOrdersReservesBalances(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+	cfg.TradingEnabled = true
+	client := api.NewClient(cfg)
+	ctx := context.Background()
+
+	if err := client.LoadRegistry(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.GetBalances(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	sell := placeLimit(t, client, "ETHUSD", "sell", "0.5", "3200")
+	placeLimit(t, client, "XBTUSD", "buy", "0.001", "50000")
+	filled := placeLimit(t, client, "ETHUSD", "sell", "0.1", "2900")
+	if err := fake.FillOrder(sell, "0.25", "3200"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.FillOrder(filled, "0.1", "2950"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.UpdatePrice("ETH/USD", decimal.MustParse("3000"))
+	if err := client.LoadOrders(ctx); err != nil {
+		t.Fatalf("LoadOrders failed: %v", err)
+	}
+
+	views := client.OpenOrderViews()
+	if len(views) != 2 {
+		t.Fatalf("Expected 2 open orders, got %d", len(views))
+	}
+	for _, view := range views {
+		switch view.Pair {
+		case "ETH/USD":
+			if view.Side != "sell" || !view.FilledPct.Equal(decimal.MustParse("50")) {
+				t.Errorf("ETH order: got %s %v%% filled", view.Side, view.FilledPct)
+			}
+			if !view.DistancePct.Equal(decimal.MustParse("6.6667")) {
+				t.Errorf("ETH order: distance %v, want 6.6667", view.DistancePct)
+			}
+		case "XBT/USD":
+			if !view.Last.IsZero() || !view.DistancePct.IsZero() {
+				t.Errorf("Unpriced XBT order has a distance: %v", view.DistancePct)
+			}
+		default:
+			t.Errorf("Unexpected order on %s", view.Pair)
+		}
+	}
+
+	fills := client.RecentFills(5)
+	if len(fills) != 1 || fills[0].TxID != filled {
+		t.Fatalf("Expected the filled order, got %+v", fills)
+	}
+	if !fills[0].Price.Equal(decimal.MustParse("2950")) || !fills[0].Volume.Equal(decimal.MustParse("0.1")) {
+		t.Errorf("Fill: got %v @ %v", fills[0].Volume, fills[0].Price)
+	}
+
+	for _, asset := range client.GetAssetValues() {
+		switch asset.Asset {
+		case "ETH":
+			if !asset.Reserved.Equal(decimal.MustParse("0.25")) || !asset.Available.Equal(decimal.MustParse("1.75")) {
+				t.Errorf("ETH: reserved %v, available %v", asset.Reserved, asset.Available)
+			}
+		case "USD":
+			if !asset.Reserved.Equal(decimal.MustParse("50")) || !asset.Available.Equal(decimal.MustParse("50")) {
+				t.Errorf("USD: reserved %v, available %v", asset.Reserved, asset.Available)
+			}
+		}
+	}
+}
+
+func TestPrivateFeedRefreshesOrders(t *testing.T) {
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			fake, cfg := newFakeConfig(version)
+			defer fake.Close()
+
+			cfg.PrivateFeed = true
+			cfg.PrivateWsURL = cfg.WsURL
+			cfg.TradingEnabled = true
+			client := api.NewClient(cfg)
+			ctx := context.Background()
+
+			if err := client.Connect(ctx); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer client.Close()
+			if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+				t.Fatal(err)
+			}
+			if err := client.LoadOrders(ctx); err != nil {
+				t.Fatalf("LoadOrders failed: %v", err)
+			}
+
+			go client.StartStreaming(ctx, func([]models.AssetValue) {})
+			for _, want := range map[string][]string{"v1": {"ownTrades", "openOrders"}, "v2": {"balances", "executions"}}[version] {
+				channel, err := fake.WaitForPrivateSubscription(5 * time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if channel != want {
+					t.Fatalf("Subscribed to %s, want %s", channel, want)
+				}
+			}
+
+			placeLimit(t, client, "SOLUSD", "buy", "1", "90")
+
+			pairs, err := fake.WaitForSubscription(5 * time.Second)
+			if err != nil {
+				t.Fatalf("Expected a subscription for the order's pair: %v", err)
+			}
+			if len(pairs) != 1 || pairs[0] != "SOL/USD" {
+				t.Fatalf("Unexpected subscription: %v", pairs)
+			}
+			if orders := client.OpenOrders(); len(orders) != 1 || orders[0].Descr.Pair != "SOLUSD" {
+				t.Errorf("Open orders not refreshed: %+v", orders)
+			}
+		})
+	}
+}
+
+func TestPrivateFeedBatchesOrderRefreshes(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	var mu sync.Mutex
+	calls := 0
+	openOrders := fake.Handler("OpenOrders")
+	fake.HandlePrivate("OpenOrders", func(form url.Values) (interface{}, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return openOrders(form)
+	})
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+
+	cfg.PrivateFeed = true
+	cfg.PrivateWsURL = cfg.WsURL
+	cfg.TradingEnabled = true
+	client := api.NewClient(cfg)
+	ctx := context.Background()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if err := client.LoadOrders(ctx); err != nil {
+		t.Fatalf("LoadOrders failed: %v", err)
+	}
+
+	go client.StartStreaming(ctx, func([]models.AssetValue) {})
+	for i := 0; i < 2; i++ {
+		if _, err := fake.WaitForPrivateSubscription(5 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Let the refresh for the subscription's snapshot run first.
+	time.Sleep(time.Second)
+	before := count()
+
+	for _, price := range []string{"90", "91", "92"} {
+		placeLimit(t, client, "SOLUSD", "buy", "1", price)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.OpenOrders()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for the orders, got %+v", client.OpenOrders())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(time.Second)
+
+	if n := count() - before; n != 1 {
+		t.Errorf("Expected one refresh for a burst of order updates, got %d", n)
+	}
+}
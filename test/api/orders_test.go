@@ -0,0 +1,147 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestFetchPrices(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	cfg.TargetWeights = map[string]decimal.Decimal{"BTC": decimal.MustParse("50")}
+	fake.SetPrice("ETH/USD", "3000")
+	fake.SetPrice("XBT/USD", "60000")
+
+	client := api.NewClient(cfg)
+	ctx := context.Background()
+	if err := client.LoadRegistry(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.GetBalances(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.FetchPrices(ctx); err != nil {
+		t.Fatalf("FetchPrices failed: %v", err)
+	}
+
+	if got := client.GetPrice("ETH/USD"); !got.Equal(decimal.MustParse("3000")) {
+		t.Errorf("ETH/USD: got %v, want 3000", got)
+	}
+	if got := client.GetPrice("XBT/USD"); !got.Equal(decimal.MustParse("60000")) {
+		t.Errorf("XBT/USD: got %v, want 60000 for the target asset", got)
+	}
+}
+
+func TestAddOrderValidate(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+	ctx := context.Background()
+
+	result, err := client.AddOrder(ctx, models.OrderRequest{
+		Pair:      "ETHUSD",
+		Type:      "buy",
+		OrderType: "market",
+		Volume:    decimal.MustParse("0.5"),
+		Validate:  true,
+	})
+	if err != nil {
+		t.Fatalf("AddOrder failed: %v", err)
+	}
+	if result.Descr.Order != "buy 0.50000000 ETHUSD @ market" {
+		t.Errorf("Unexpected description %q", result.Descr.Order)
+	}
+	if len(result.TxID) != 0 || len(fake.Orders()) != 0 {
+		t.Errorf("Validated order was placed: %v", result.TxID)
+	}
+
+	_, err = client.AddOrder(ctx, models.OrderRequest{
+		Pair:      "ETHUSD",
+		Type:      "sell",
+		OrderType: "market",
+		Volume:    decimal.MustParse("0.001"),
+		Validate:  true,
+	})
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(apiErr.Error(), "Order minimum not met") {
+		t.Errorf("Expected an order minimum error, got %v", err)
+	}
+}
+
+func TestTradingDisabledRefusesOrders(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+	ctx := context.Background()
+
+	_, err := client.AddOrder(ctx, models.OrderRequest{
+		Pair:      "ETHUSD",
+		Type:      "buy",
+		OrderType: "market",
+		Volume:    decimal.MustParse("0.5"),
+	})
+	if !errors.Is(err, api.ErrTradingDisabled) {
+		t.Errorf("AddOrder: expected ErrTradingDisabled, got %v", err)
+	}
+	if _, err := client.CancelOrder(ctx, "O00001-FAKE"); !errors.Is(err, api.ErrTradingDisabled) {
+		t.Errorf("CancelOrder: expected ErrTradingDisabled, got %v", err)
+	}
+	if _, err := client.CancelAll(ctx); !errors.Is(err, api.ErrTradingDisabled) {
+		t.Errorf("CancelAll: expected ErrTradingDisabled, got %v", err)
+	}
+	if len(fake.Orders()) != 0 {
+		t.Errorf("Orders reached the exchange: %v", fake.Orders())
+	}
+}
+
+func TestPlaceAndCancelOrders(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+	cfg.TradingEnabled = true
+	client := api.NewClient(cfg)
+	ctx := context.Background()
+
+	var txids []string
+	for _, price := range []string{"2500", "2600"} {
+		result, err := client.AddOrder(ctx, models.OrderRequest{
+			Pair:      "ETHUSD",
+			Type:      "buy",
+			OrderType: "limit",
+			Volume:    decimal.MustParse("0.5"),
+			Price:     decimal.MustParse(price),
+		})
+		if err != nil {
+			t.Fatalf("AddOrder failed: %v", err)
+		}
+		if len(result.TxID) != 1 {
+			t.Fatalf("Expected one txid, got %v", result.TxID)
+		}
+		txids = append(txids, result.TxID[0])
+	}
+	if got := fake.Orders()[txids[0]]; got.OrderType != "limit" || !got.Price.Equal(decimal.MustParse("2500")) {
+		t.Errorf("Unexpected order %+v", got)
+	}
+
+	count, err := client.CancelOrder(ctx, txids[0])
+	if err != nil || count != 1 {
+		t.Fatalf("CancelOrder: got %d, %v", count, err)
+	}
+	if _, err := client.CancelOrder(ctx, txids[0]); err == nil {
+		t.Error("Expected an error cancelling the same order twice")
+	}
+
+	count, err = client.CancelAll(ctx)
+	if err != nil || count != 1 {
+		t.Fatalf("CancelAll: got %d, %v", count, err)
+	}
+	for id, order := range fake.Orders() {
+		if order.Status != "canceled" {
+			t.Errorf("%s is still %s", id, order.Status)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/krakenfake"
+	"github.com/umit144/kraken-portfolio/internal/models"
+)
+
+func TestPrivateFeedUpdatesBalances(t *testing.T) {
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			fake, cfg := newFakeConfig(version)
+			defer fake.Close()
+
+			cfg.PrivateFeed = true
+			cfg.PrivateWsURL = cfg.WsURL
+			client := api.NewClient(cfg)
+
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer client.Close()
+
+			if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			rendered := make(chan string, 64)
+			go client.StartStreaming(context.Background(), renderTo(rendered))
+
+			if _, err := fake.WaitForPrivateSubscription(5 * time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := fake.PushBalance("XETH", "3.0"); err != nil {
+				t.Fatal(err)
+			}
+			if err := fake.PushTicker("ETH/USD", "3000.0"); err != nil {
+				t.Fatal(err)
+			}
+			waitForRender(t, rendered, "TOTAL VALUE: $9100.00")
+
+			if err := fake.PushBalance("SOL", "10.0"); err != nil {
+				t.Fatal(err)
+			}
+			pairs, err := fake.WaitForSubscription(2 * time.Second)
+			if err != nil {
+				t.Fatalf("Expected subscription for new holding: %v", err)
+			}
+			if len(pairs) != 1 || pairs[0] != "SOL/USD" {
+				t.Fatalf("Unexpected subscription: %v", pairs)
+			}
+
+			if err := fake.PushTicker("SOL/USD", "100.0"); err != nil {
+				t.Fatal(err)
+			}
+			waitForRender(t, rendered, "TOTAL VALUE: $10100.00")
+		})
+	}
+}
+
+func TestPrivateFeedRefreshesCostBasis(t *testing.T) {
+	for _, version := range []string{"v1", "v2"} {
+		t.Run(version, func(t *testing.T) {
+			fake, cfg := newFakeConfig(version)
+			defer fake.Close()
+
+			cfg.PrivateFeed = true
+			cfg.PrivateWsURL = cfg.WsURL
+			client := api.NewClient(cfg)
+
+			if err := client.Connect(context.Background()); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer client.Close()
+			if err := client.LoadCostBasis(context.Background()); err != nil {
+				t.Fatalf("LoadCostBasis failed: %v", err)
+			}
+
+			go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+			if _, err := fake.WaitForPrivateSubscription(5 * time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			fake.AddTrade("T1", trade("XETHZUSD", "buy", 1700000000, "1", "2000", "0"))
+			if err := fake.PushBalance("XETH", "3.0"); err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for len(client.Lots()["XETH"]) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("Timeout waiting for the cost basis to pick up the trade")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestPrivateFeedRetriesTokenErrors(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	calls := 0
+	fake.HandlePrivate("GetWebSocketsToken", func(url.Values) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("EAPI:Rate limit exceeded")
+		}
+		return map[string]interface{}{"token": krakenfake.Token, "expires": 900}, nil
+	})
+
+	cfg.PrivateFeed = true
+	cfg.PrivateWsURL = cfg.WsURL
+	client := api.NewClient(cfg)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	if _, err := fake.WaitForPrivateSubscription(5 * time.Second); err != nil {
+		t.Fatalf("Expected the private feed to retry after a rate limit: %v", err)
+	}
+}
+
+func TestPrivateFeedStopsWhenKeyIsRefused(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	fake.HandlePrivate("GetWebSocketsToken", func(url.Values) (interface{}, error) {
+		return nil, errors.New("EGeneral:Permission denied")
+	})
+
+	cfg.PrivateFeed = true
+	cfg.PrivateWsURL = cfg.WsURL
+	client := api.NewClient(cfg)
+	errs := make(chan error, 4)
+	client.OnError = func(err error) { errs <- err }
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "disabled") || !strings.Contains(err.Error(), "Permission denied") {
+			t.Errorf("Unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the private feed to give up")
+	}
+}
+
+func TestPrivateFeedFetchesOnlyNewTrades(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	var mu sync.Mutex
+	var starts []string
+	history := fake.Handler("TradesHistory")
+	fake.HandlePrivate("TradesHistory", func(form url.Values) (interface{}, error) {
+		mu.Lock()
+		starts = append(starts, form.Get("start"))
+		mu.Unlock()
+		return history(form)
+	})
+	fake.AddTrade("T1", trade("XETHZUSD", "buy", 1700000000, "1", "2000", "0"))
+
+	cfg.PrivateFeed = true
+	cfg.PrivateWsURL = cfg.WsURL
+	client := api.NewClient(cfg)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if err := client.LoadCostBasis(context.Background()); err != nil {
+		t.Fatalf("LoadCostBasis failed: %v", err)
+	}
+
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	if _, err := fake.WaitForPrivateSubscription(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.AddTrade("T2", trade("XETHZUSD", "buy", 1700000100, "1", "3000", "0"))
+	for _, balance := range []string{"3.0", "4.0"} {
+		if err := fake.PushBalance("XETH", balance); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.Lots()["XETH"]) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for the cost basis to pick up the new trade")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(starts) < 2 || starts[0] != "" {
+		t.Fatalf("Expected a full load then refreshes, got starts %q", starts)
+	}
+	for _, start := range starts[1:] {
+		if start != "1699999999" {
+			t.Errorf("Expected refreshes to start just before the last known trade, got %q", start)
+		}
+	}
+	if lots := client.Lots()["XETH"]; len(lots) != 2 {
+		t.Errorf("Expected each trade once, got %+v", lots)
+	}
+}
+
+func TestV2ExecutionsRefreshCostBasisOnFill(t *testing.T) {
+	fake, cfg := newFakeConfig("v2")
+	defer fake.Close()
+
+	cfg.PrivateFeed = true
+	cfg.PrivateWsURL = cfg.WsURL
+	cfg.TradingEnabled = true
+	client := api.NewClient(cfg)
+	ctx := context.Background()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if err := client.LoadCostBasis(ctx); err != nil {
+		t.Fatalf("LoadCostBasis failed: %v", err)
+	}
+
+	go client.StartStreaming(ctx, func([]models.AssetValue) {})
+	for i := 0; i < 2; i++ {
+		if _, err := fake.WaitForPrivateSubscription(5 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	txid := placeLimit(t, client, "ETHUSD", "buy", "1", "2000")
+	fake.AddTrade("T1", trade("XETHZUSD", "buy", 1700000000, "1", "2000", "0"))
+	if err := fake.FillOrder(txid, "1", "2000"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.Lots()["XETH"]) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for a fill to refresh the cost basis")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
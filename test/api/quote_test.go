@@ -0,0 +1,171 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func newQuoteRegistry() *api.Registry {
+	assets := map[string]models.AssetInfo{
+		"XXBT": {Altname: "XBT", Decimals: 10, DisplayDecimals: 5},
+		"SOL":  {Altname: "SOL", Decimals: 10, DisplayDecimals: 5},
+		"ZUSD": {Altname: "USD", Decimals: 4, DisplayDecimals: 2},
+		"ZEUR": {Altname: "EUR", Decimals: 4, DisplayDecimals: 2},
+		"CHF":  {Altname: "CHF", Decimals: 4, DisplayDecimals: 2},
+	}
+	pairs := map[string]models.AssetPair{
+		"XXBTZUSD": {WsName: "XBT/USD", Base: "XXBT", Quote: "ZUSD", PairDecimals: 1},
+		"XXBTZEUR": {WsName: "XBT/EUR", Base: "XXBT", Quote: "ZEUR", PairDecimals: 1},
+		"SOLUSD":   {WsName: "SOL/USD", Base: "SOL", Quote: "ZUSD", PairDecimals: 2},
+		"SOLXBT":   {WsName: "SOL/XBT", Base: "SOL", Quote: "XXBT", PairDecimals: 7},
+		"ZEURZUSD": {WsName: "EUR/USD", Base: "ZEUR", Quote: "ZUSD", PairDecimals: 5},
+		"USDCHF":   {WsName: "USD/CHF", Base: "ZUSD", Quote: "CHF", PairDecimals: 5},
+	}
+	return api.NewRegistry(assets, pairs)
+}
+
+func TestRegistryRouteFor(t *testing.T) {
+	registry := newQuoteRegistry()
+
+	tests := []struct {
+		asset  string
+		quote  string
+		pairs  []string
+		invert []bool
+	}{
+		{"XXBT", "EUR", []string{"XBT/EUR"}, []bool{false}},
+		{"ZUSD", "EUR", []string{"EUR/USD"}, []bool{true}},
+		{"SOL", "EUR", []string{"SOL/USD", "EUR/USD"}, []bool{false, true}},
+		{"SOL", "CHF", []string{"SOL/USD", "USD/CHF"}, []bool{false, false}},
+		{"SOL", "BTC", []string{"SOL/XBT"}, []bool{false}},
+		{"ZEUR.HOLD", "EUR", []string{}, []bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.asset+"/"+tt.quote, func(t *testing.T) {
+			route, ok := registry.RouteFor(tt.asset, tt.quote)
+			if !ok {
+				t.Fatal("Expected a route")
+			}
+			if len(route) != len(tt.pairs) {
+				t.Fatalf("got %d legs, want %d", len(route), len(tt.pairs))
+			}
+			for i, leg := range route {
+				if leg.Pair.WsName != tt.pairs[i] || leg.Invert != tt.invert[i] {
+					t.Errorf("leg %d: got %s (invert %v), want %s (invert %v)",
+						i, leg.Pair.WsName, leg.Invert, tt.pairs[i], tt.invert[i])
+				}
+			}
+		})
+	}
+
+	if _, ok := registry.RouteFor("UNKNOWN", "EUR"); ok {
+		t.Error("Expected no route for an unknown asset")
+	}
+}
+
+func TestGetAssetValuesInEUR(t *testing.T) {
+	client := api.NewClient(&config.Config{
+		ApiKey:        "test-key",
+		ApiSecret:     "test-secret",
+		QuoteCurrency: "EUR",
+	})
+	client.SetRegistry(newQuoteRegistry())
+	client.SetBalances(map[string]decimal.Decimal{
+		"XXBT":     decimal.MustParse("0.5"),
+		"SOL":      decimal.MustParse("10"),
+		"ZUSD":     decimal.MustParse("110"),
+		"ZEUR":     decimal.MustParse("100"),
+		"EUR.HOLD": decimal.MustParse("50"),
+	})
+
+	client.UpdatePrice("XBT/EUR", decimal.MustParse("50000"))
+	client.UpdatePrice("SOL/USD", decimal.MustParse("110"))
+	client.UpdatePrice("EUR/USD", decimal.MustParse("1.1"))
+
+	if got := client.QuoteCurrency(); got != "EUR" {
+		t.Errorf("got quote currency %v, want EUR", got)
+	}
+
+	assetMap := make(map[string]models.AssetValue)
+	for _, asset := range client.GetAssetValues() {
+		assetMap[asset.Asset] = asset
+	}
+
+	want := map[string]string{
+		"XBT": "25000.00",
+		"SOL": "1000.00",
+		"USD": "100.00",
+		"EUR": "150.00",
+	}
+	if len(assetMap) != len(want) {
+		t.Fatalf("Expected %d assets, got %+v", len(want), assetMap)
+	}
+	for asset, value := range want {
+		if got := assetMap[asset].Value.StringFixed(2); got != value {
+			t.Errorf("%s: got value %v, want %v", asset, got, value)
+		}
+	}
+	if got := assetMap["EUR"].Price; !got.Equal(decimal.One) {
+		t.Errorf("Expected EUR to be priced at one, got %v", got)
+	}
+}
+
+func TestStreamingSubscribesFXPairs(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	fake.AddAsset("ZEUR", models.AssetInfo{Altname: "EUR", Decimals: 4, DisplayDecimals: 2})
+	fake.AddPair("ZEURZUSD", models.AssetPair{Altname: "EURUSD", WsName: "EUR/USD", Base: "ZEUR", Quote: "ZUSD", PairDecimals: 5})
+
+	cfg.QuoteCurrency = "EUR"
+	client := api.NewClient(cfg)
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	pairs, err := fake.WaitForSubscription(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscribed := make(map[string]bool)
+	for _, pair := range pairs {
+		subscribed[pair] = true
+	}
+	if len(pairs) != 2 || !subscribed["ETH/USD"] || !subscribed["EUR/USD"] {
+		t.Fatalf("Unexpected subscription: %v", pairs)
+	}
+
+	rendered := make(chan string, 16)
+	go client.StartStreaming(context.Background(), renderToCurrency(rendered, "EUR"))
+
+	if err := fake.PushTicker("EUR/USD", "1.25"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.PushTicker("ETH/USD", "3000.0"); err != nil {
+		t.Fatal(err)
+	}
+	// 2 ETH at 3000 USD plus 100 USD, at 1.25 USD per EUR.
+	waitForRender(t, rendered, "TOTAL VALUE: €4880.00")
+}
+
+func TestConnectRejectsUnknownQuoteCurrency(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+
+	cfg.QuoteCurrency = "XYZ"
+	client := api.NewClient(cfg)
+	defer client.Close()
+
+	if err := client.Connect(context.Background()); !errors.Is(err, api.ErrUnknownQuoteCurrency) {
+		t.Errorf("Expected ErrUnknownQuoteCurrency, got %v", err)
+	}
+}
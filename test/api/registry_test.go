@@ -0,0 +1,233 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func newTestRegistry() *api.Registry {
+	assets := map[string]models.AssetInfo{
+		"XXBT":  {Altname: "XBT", Decimals: 10},
+		"XETH":  {Altname: "ETH", Decimals: 10},
+		"DOT":   {Altname: "DOT", Decimals: 10},
+		"DOT.S": {Altname: "DOT.S", Decimals: 10},
+		"ADA":   {Altname: "ADA", Decimals: 8},
+		"XXRP":  {Altname: "XRP", Decimals: 8},
+		"ZUSD":  {Altname: "USD", Decimals: 4},
+		"ZEUR":  {Altname: "EUR", Decimals: 4},
+	}
+	pairs := map[string]models.AssetPair{
+		"XXBTZUSD": {Altname: "XBTUSD", WsName: "XBT/USD", Base: "XXBT", Quote: "ZUSD"},
+		"XETHZUSD": {Altname: "ETHUSD", WsName: "ETH/USD", Base: "XETH", Quote: "ZUSD"},
+		"DOTUSD":   {Altname: "DOTUSD", WsName: "DOT/USD", Base: "DOT", Quote: "ZUSD"},
+		"ADAUSD":   {Altname: "ADAUSD", WsName: "ADA/USD", Base: "ADA", Quote: "ZUSD"},
+		"XXRPZUSD": {Altname: "XRPUSD", WsName: "XRP/USD", Base: "XXRP", Quote: "ZUSD"},
+		"ADAEUR":   {Altname: "ADAEUR", WsName: "ADA/EUR", Base: "ADA", Quote: "ZEUR"},
+	}
+	return api.NewRegistry(assets, pairs)
+}
+
+func TestRegistryUSDPair(t *testing.T) {
+	registry := newTestRegistry()
+
+	tests := []struct {
+		asset  string
+		wsname string
+		found  bool
+	}{
+		{"XXBT", "XBT/USD", true},
+		{"XBT", "XBT/USD", true},
+		{"XETH", "ETH/USD", true},
+		{"ETH2.S", "ETH/USD", true},
+		{"DOT", "DOT/USD", true},
+		{"DOT.S", "DOT/USD", true},
+		{"DOT28.S", "DOT/USD", true},
+		{"XXRP", "XRP/USD", true},
+		{"ZEUR", "", false},
+		{"UNKNOWN", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.asset, func(t *testing.T) {
+			pair, ok := registry.USDPair(tt.asset)
+			if ok != tt.found {
+				t.Fatalf("got found=%v, want %v", ok, tt.found)
+			}
+			if pair.WsName != tt.wsname {
+				t.Errorf("got %v, want %v", pair.WsName, tt.wsname)
+			}
+		})
+	}
+}
+
+func TestRegistryPrefersRegularPair(t *testing.T) {
+	assets := map[string]models.AssetInfo{
+		"XXBT": {Altname: "XBT", Decimals: 10},
+		"SOL":  {Altname: "SOL", Decimals: 10},
+		"ZUSD": {Altname: "USD", Decimals: 4},
+	}
+	pairs := map[string]models.AssetPair{
+		"XXBTZUSD.d": {Altname: "XBTUSD.d", WsName: "XBT/USD.d", Base: "XXBT", Quote: "ZUSD"},
+		"XXBTZUSD":   {Altname: "XBTUSD", WsName: "XBT/USD", Base: "XXBT", Quote: "ZUSD"},
+		"SOLUSD2":    {Altname: "SOLUSD2", WsName: "SOL/USD2", Base: "SOL", Quote: "ZUSD"},
+		"SOLUSD":     {Altname: "SOLUSD", WsName: "SOL/USD", Base: "SOL", Quote: "ZUSD"},
+	}
+
+	// Map order varies between runs, so build it a few times.
+	for i := 0; i < 20; i++ {
+		registry := api.NewRegistry(assets, pairs)
+		if pair, _ := registry.USDPair("XXBT"); pair.WsName != "XBT/USD" {
+			t.Fatalf("got %s, want the regular XBT/USD over its dark pool variant", pair.WsName)
+		}
+		if pair, _ := registry.USDPair("SOL"); pair.WsName != "SOL/USD" {
+			t.Fatalf("got %s, want SOL/USD, the lowest key", pair.WsName)
+		}
+	}
+}
+
+func TestRegistryDisplayName(t *testing.T) {
+	registry := newTestRegistry()
+
+	tests := map[string]string{
+		"XXBT":  "XBT",
+		"DOT.S": "DOT.S",
+		"ZUSD":  "USD",
+		"XABC":  "ABC",
+		"NEW":   "NEW",
+	}
+
+	for asset, want := range tests {
+		if got := registry.DisplayName(asset); got != want {
+			t.Errorf("DisplayName(%s): got %v, want %v", asset, got, want)
+		}
+	}
+}
+
+func TestRegistryCache(t *testing.T) {
+	dir := t.TempDir()
+	registry := newTestRegistry()
+
+	if err := registry.Save(dir); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := api.LoadRegistryCache(dir)
+	if err != nil {
+		t.Fatalf("LoadRegistryCache failed: %v", err)
+	}
+	if loaded.Stale() {
+		t.Error("Expected freshly saved registry not to be stale")
+	}
+	if pair, ok := loaded.USDPair("DOT.S"); !ok || pair.WsName != "DOT/USD" {
+		t.Errorf("Unexpected pair after reload: %+v", pair)
+	}
+}
+
+func TestGetAssetValuesWithRegistry(t *testing.T) {
+	cfg := &config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: "test-secret",
+	}
+
+	client := api.NewClient(cfg)
+	client.SetRegistry(newTestRegistry())
+	client.SetBalances(map[string]decimal.Decimal{
+		"DOT":      decimal.MustParse("10"),
+		"DOT.S":    decimal.MustParse("5"),
+		"XXRP":     decimal.MustParse("100"),
+		"ZUSD":     decimal.MustParse("500"),
+		"USD.HOLD": decimal.MustParse("250"),
+		"UNKNOWN":  decimal.MustParse("1"),
+	})
+
+	client.UpdatePrice("DOT/USD", decimal.MustParse("5"))
+	client.UpdatePrice("XRP/USD", decimal.MustParse("0.5"))
+
+	assetMap := make(map[string]models.AssetValue)
+	for _, asset := range client.GetAssetValues() {
+		assetMap[asset.Asset] = asset
+	}
+
+	if len(assetMap) != 4 {
+		t.Errorf("Expected 4 assets, got %d: %+v", len(assetMap), assetMap)
+	}
+	if got := assetMap["DOT.S"].Value; got.String() != "25" {
+		t.Errorf("Unexpected DOT.S value: %v", got)
+	}
+	if got := assetMap["XRP"].Value; got.String() != "50" {
+		t.Errorf("Unexpected XRP value: %v", got)
+	}
+	if got := assetMap["USD"].Balance; got.String() != "750" {
+		t.Errorf("Unexpected USD balance: %v", got)
+	}
+}
+
+func TestGetAssetValuesPrecision(t *testing.T) {
+	assets := map[string]models.AssetInfo{
+		"XXBT": {Altname: "XBT", Decimals: 10, DisplayDecimals: 5},
+		"ZUSD": {Altname: "USD", Decimals: 4, DisplayDecimals: 2},
+	}
+	pairs := map[string]models.AssetPair{
+		"XXBTZUSD": {Altname: "XBTUSD", WsName: "XBT/USD", Base: "XXBT", Quote: "ZUSD", PairDecimals: 1},
+	}
+
+	client := api.NewClient(&config.Config{ApiKey: "test-key", ApiSecret: "test-secret"})
+	client.SetRegistry(api.NewRegistry(assets, pairs))
+	client.SetBalances(map[string]decimal.Decimal{"XXBT": decimal.MustParse("0.1")})
+	client.UpdatePrice("XBT/USD", decimal.MustParse("60000.3"))
+
+	values := client.GetAssetValues()
+	if len(values) != 1 {
+		t.Fatalf("Expected 1 asset, got %d", len(values))
+	}
+	btc := values[0]
+	if btc.BalanceDecimals != 5 || btc.PriceDecimals != 1 {
+		t.Errorf("Unexpected precision: balance %d, price %d", btc.BalanceDecimals, btc.PriceDecimals)
+	}
+	// 0.1 * 60000.3 is exactly 6000.03; float64 arithmetic gives 6000.030000000001.
+	if got := btc.Value.String(); got != "6000.03" {
+		t.Errorf("Unexpected USD value: %s", got)
+	}
+}
+
+func TestLoadRegistryFromEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/public/Assets":
+			fmt.Fprint(w, `{"error":[],"result":{"DOT":{"altname":"DOT","decimals":10},"ZUSD":{"altname":"USD","decimals":4}}}`)
+		case "/0/public/AssetPairs":
+			fmt.Fprint(w, `{"error":[],"result":{"DOTUSD":{"altname":"DOTUSD","wsname":"DOT/USD","base":"DOT","quote":"ZUSD"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cfg := &config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: "test-secret",
+		CacheDir:  dir,
+		RestURL:   server.URL,
+	}
+
+	client := api.NewClient(cfg)
+	if err := client.LoadRegistry(context.Background()); err != nil {
+		t.Fatalf("LoadRegistry failed: %v", err)
+	}
+	if pair, ok := client.Registry().USDPair("DOT.S"); !ok || pair.WsName != "DOT/USD" {
+		t.Errorf("Unexpected pair: %+v", pair)
+	}
+
+	if _, err := api.LoadRegistryCache(dir); err != nil {
+		t.Errorf("Expected registry to be cached: %v", err)
+	}
+}
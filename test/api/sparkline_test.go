@@ -0,0 +1,74 @@
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestSparklineFollowsPriceUpdates(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := api.NewClient(&config.Config{
+		ApiKey:          "test-key",
+		ApiSecret:       "test-secret",
+		SparklineWindow: time.Hour,
+	}, api.WithClock(func() time.Time { return now }))
+	client.SetBalances(map[string]decimal.Decimal{"XETH": decimal.MustParse("1")})
+
+	client.UpdatePrice("ETH/USD", decimal.MustParse("3000"))
+	now = now.Add(10 * time.Second)
+	client.UpdatePrice("ETH/USD", decimal.MustParse("3010"))
+	now = now.Add(time.Minute)
+	client.UpdatePrice("ETH/USD", decimal.MustParse("3020"))
+	now = now.Add(3 * time.Minute)
+
+	line := client.GetAssetValues()[0].Sparkline
+	if len(line) != api.SparklineBuckets {
+		t.Fatalf("got %d buckets, want %d", len(line), api.SparklineBuckets)
+	}
+	// One bucket a minute: the last price of the first minute, the next
+	// minute's, and that price held through the quiet minutes since.
+	want := []string{"3010", "3020", "3020", "3020", "3020"}
+	tail := line[len(line)-len(want):]
+	for i, w := range want {
+		if tail[i].String() != w {
+			t.Errorf("bucket %d: got %v, want %s", i, tail[i], w)
+		}
+	}
+	if !line[len(line)-len(want)-1].IsZero() {
+		t.Errorf("expected buckets before the first price to be zero, got %v", line[len(line)-len(want)-1])
+	}
+
+	// Older buckets roll out of the window.
+	now = now.Add(2 * time.Hour)
+	client.UpdatePrice("ETH/USD", decimal.MustParse("2900"))
+	line = client.GetAssetValues()[0].Sparkline
+	if line[0].String() != "3020" || line[len(line)-1].String() != "2900" {
+		t.Errorf("got %v ... %v, want 3020 ... 2900", line[0], line[len(line)-1])
+	}
+}
+
+func TestSparklineThroughTwoLegRoute(t *testing.T) {
+	client := api.NewClient(&config.Config{
+		ApiKey:        "test-key",
+		ApiSecret:     "test-secret",
+		QuoteCurrency: "EUR",
+	})
+	client.SetRegistry(newQuoteRegistry())
+	client.SetBalances(map[string]decimal.Decimal{"SOL": decimal.MustParse("10")})
+
+	client.UpdatePrice("SOL/USD", decimal.MustParse("150"))
+	if line := client.GetAssetValues()[0].Sparkline; line != nil {
+		t.Errorf("expected no sparkline before EUR/USD is priced, got %d buckets", len(line))
+	}
+
+	client.UpdateTicker(models.Ticker{Symbol: "EUR/USD", Last: decimal.MustParse("1.25")})
+	line := client.GetAssetValues()[0].Sparkline
+	if got := line[len(line)-1]; !got.Equal(decimal.MustParse("120")) {
+		t.Errorf("got %v, want 120", got)
+	}
+}
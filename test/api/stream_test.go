@@ -0,0 +1,421 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/config"
+	"github.com/umit144/kraken-portfolio/internal/krakenfake"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/ui"
+
+	"github.com/gorilla/websocket"
+)
+
+func newFakeClient(t *testing.T) (*krakenfake.Server, *api.Client) {
+	return newFakeClientVersion(t, "v1")
+}
+
+func newFakeClientVersion(t *testing.T, version string) (*krakenfake.Server, *api.Client) {
+	fake, cfg := newFakeConfig(version)
+	return fake, api.NewClient(cfg)
+}
+
+func newFakeConfig(version string) (*krakenfake.Server, *config.Config) {
+	secret := base64.StdEncoding.EncodeToString([]byte("test-secret"))
+	fake := krakenfake.New("test-key", secret)
+	fake.SetBalances(map[string]string{
+		"XETH": "2.0",
+		"ZUSD": "100.0",
+	})
+
+	cfg := &config.Config{
+		ApiKey:    "test-key",
+		ApiSecret: secret,
+		RestURL:   fake.URL(),
+		WsURL:     fake.WsURL(),
+		WsVersion: version,
+	}
+	if version == "v2" {
+		cfg.WsURL = fake.WsV2URL()
+	}
+	return fake, cfg
+}
+
+func renderTo(rendered chan<- string) func([]models.AssetValue) {
+	return renderToCurrency(rendered, "USD")
+}
+
+func renderToCurrency(rendered chan<- string, currency string) func([]models.AssetValue) {
+	return func(assets []models.AssetValue) {
+		var buf bytes.Buffer
+		display := ui.NewDisplayWithWriter(&buf, 80)
+		display.SetQuoteCurrency(currency, 2)
+		display.RenderPortfolio(assets)
+		select {
+		case rendered <- buf.String():
+		default:
+		}
+	}
+}
+
+func waitForRender(t *testing.T, rendered <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case output := <-rendered:
+			if strings.Contains(output, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timeout waiting for render containing %q", want)
+		}
+	}
+}
+
+func TestStreamingEndToEnd(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	pairs, err := fake.WaitForSubscription(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 || pairs[0] != "ETH/USD" {
+		t.Fatalf("Unexpected subscription: %v", pairs)
+	}
+
+	rendered := make(chan string, 16)
+	go client.StartStreaming(context.Background(), renderTo(rendered))
+
+	if err := fake.PushTicker("ETH/USD", "3000.0"); err != nil {
+		t.Fatal(err)
+	}
+	waitForRender(t, rendered, "TOTAL VALUE: $6100.00")
+}
+
+func TestStreamingReconnects(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	states := make(chan models.ConnectionState, 16)
+	client.OnStateChange = func(state models.ConnectionState) {
+		states <- state
+	}
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := make(chan string, 16)
+	go client.StartStreaming(context.Background(), renderTo(rendered))
+
+	fake.SetBalances(map[string]string{
+		"XETH": "3.0",
+		"ZUSD": "100.0",
+	})
+	fake.DropConnections()
+
+	pairs, err := fake.WaitForSubscription(5 * time.Second)
+	if err != nil {
+		t.Fatalf("Expected resubscription after drop: %v", err)
+	}
+	if len(pairs) != 1 || pairs[0] != "ETH/USD" {
+		t.Fatalf("Unexpected resubscription: %v", pairs)
+	}
+
+	for _, want := range []models.ConnectionState{models.StateConnected, models.StateReconnecting, models.StateConnected} {
+		select {
+		case got := <-states:
+			if got != want {
+				t.Errorf("got state %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timeout waiting for state %v", want)
+		}
+	}
+
+	if err := fake.PushTicker("ETH/USD", "3000.0"); err != nil {
+		t.Fatal(err)
+	}
+	waitForRender(t, rendered, "TOTAL VALUE: $9100.00")
+}
+
+func TestStreamingV2EndToEnd(t *testing.T) {
+	fake, client := newFakeClientVersion(t, "v2")
+	defer fake.Close()
+	fake.SetBalances(map[string]string{
+		"XXBT": "0.5",
+		"XETH": "2.0",
+	})
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	pairs, err := fake.WaitForSubscription(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 2 {
+		t.Fatalf("Unexpected subscription: %v", pairs)
+	}
+
+	rendered := make(chan string, 16)
+	go client.StartStreaming(context.Background(), renderTo(rendered))
+
+	if err := fake.PushTicker("XBT/USD", "60000.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.PushTicker("ETH/USD", "3000.0"); err != nil {
+		t.Fatal(err)
+	}
+	waitForRender(t, rendered, "TOTAL VALUE: $36000.00")
+}
+
+func TestV2Symbol(t *testing.T) {
+	tests := map[string]string{
+		"XBT/USD":  "BTC/USD",
+		"XDG/EUR":  "DOGE/EUR",
+		"ETH/XBT":  "ETH/BTC",
+		"DOT/USD":  "DOT/USD",
+		"XBTC/USD": "XBTC/USD",
+	}
+
+	for wsname, want := range tests {
+		if got := api.V2Symbol(wsname); got != want {
+			t.Errorf("V2Symbol(%s): got %v, want %v", wsname, got, want)
+		}
+	}
+}
+
+func TestCloseWhileStreaming(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+		close(stopped)
+	}()
+
+	readers := make(chan struct{})
+	go func() {
+		defer close(readers)
+		for i := 0; i < 100; i++ {
+			client.GetAssetValues()
+			client.State()
+			fake.PushTicker("ETH/USD", "3000.0")
+		}
+	}()
+	<-readers
+
+	if err := client.Close(); err != nil {
+		t.Logf("Close: %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("StartStreaming did not return after Close")
+	}
+}
+
+func TestStreamingStopsOnContextCancel(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- client.StartStreaming(ctx, func([]models.AssetValue) {})
+	}()
+
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("StartStreaming did not return after cancel")
+	}
+
+	code, err := fake.WaitForClose(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != websocket.CloseNormalClosure {
+		t.Errorf("got close code %v, want %v", code, websocket.CloseNormalClosure)
+	}
+}
+
+func TestConnectHonoursCancelledContext(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.Connect(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestConnectFailsOnRejectedHandshake(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+	cfg.WsURL = "ws" + strings.TrimPrefix(fake.URL(), "http") + "/missing"
+
+	client := api.NewClient(cfg)
+	err := client.Connect(context.Background())
+	if !errors.Is(err, websocket.ErrBadHandshake) {
+		t.Errorf("got %v, want websocket.ErrBadHandshake", err)
+	}
+}
+
+func TestStreamingRetriesUnavailableHandshake(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	fake.RejectHandshakes(http.StatusServiceUnavailable)
+	fake.DropConnections()
+
+	if _, err := fake.WaitForSubscription(10 * time.Second); err != nil {
+		t.Fatalf("Expected resubscription after a 503 handshake: %v", err)
+	}
+	if client.State() != models.StateConnected {
+		t.Errorf("got state %v, want %v", client.State(), models.StateConnected)
+	}
+}
+
+func TestStreamingStopsOnRefusedHandshake(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	}()
+	fake.RejectHandshakes(http.StatusUnauthorized)
+	fake.DropConnections()
+
+	select {
+	case err := <-done:
+		var handshakeErr *api.HandshakeError
+		if !errors.As(err, &handshakeErr) || handshakeErr.StatusCode != http.StatusUnauthorized {
+			t.Errorf("got %v, want a 401 HandshakeError", err)
+		}
+		if !errors.Is(err, websocket.ErrBadHandshake) {
+			t.Errorf("got %v, want websocket.ErrBadHandshake", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for StartStreaming to give up")
+	}
+}
+
+func TestOnErrorReportsErrorFrames(t *testing.T) {
+	fake, cfg := newFakeConfig("v1")
+	defer fake.Close()
+	var logs bytes.Buffer
+	client := api.NewClient(cfg, api.WithLogger(log.New(&logs, "", 0)))
+
+	errs := make(chan error, 4)
+	client.OnError = func(err error) { errs <- err }
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := fake.WaitForSubscription(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	frame := map[string]string{"event": "error", "errorMessage": "Rate limit exceeded"}
+	if err := fake.PushFrame("ETH/USD", frame); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "Rate limit exceeded") {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if !strings.Contains(logs.String(), "Rate limit exceeded") {
+			t.Errorf("Expected the error in the client's log, got %q", logs.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for OnError")
+	}
+}
+
+func TestStateBeforeConnect(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	if client.State() != models.StateConnecting {
+		t.Errorf("Expected a new client to be connecting, got %v", client.State())
+	}
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+	if client.State() != models.StateConnected {
+		t.Errorf("Expected connected after dialling, got %v", client.State())
+	}
+}
@@ -0,0 +1,68 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+)
+
+func TestWatchStreamsUnheldAssets(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	var updates atomic.Int32
+	client.OnPriceUpdate = func() { updates.Add(1) }
+	client.Watch("SOL")
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	pairs, err := fake.WaitForSubscription(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, pair := range pairs {
+		found = found || pair == "SOL/USD"
+	}
+	if !found {
+		t.Fatalf("Watched pair not subscribed: %v", pairs)
+	}
+
+	go client.StartStreaming(context.Background(), func([]models.AssetValue) {})
+	if err := fake.PushTicker("SOL/USD", "150.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if price, ok := client.PriceOf("SOL"); ok && price.Equal(decimal.MustParse("150")) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for the watched price")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if updates.Load() == 0 {
+		t.Error("OnPriceUpdate was not called")
+	}
+}
+
+func TestConnectRejectsUnknownWatchedAsset(t *testing.T) {
+	fake, client := newFakeClient(t)
+	defer fake.Close()
+
+	client.Watch("NOPE")
+	err := client.Connect(context.Background())
+	if !errors.Is(err, api.ErrUnknownWatchedAsset) {
+		t.Fatalf("Expected ErrUnknownWatchedAsset, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package backfill_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/api"
+	"github.com/umit144/kraken-portfolio/internal/backfill"
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func entry(day int, asset, amount, fee string) models.LedgerEntry {
+	e := models.LedgerEntry{
+		Time:   float64(start.AddDate(0, 0, day).Add(12 * time.Hour).Unix()),
+		Asset:  asset,
+		Amount: decimal.MustParse(amount),
+	}
+	if fee != "" {
+		e.Fee = decimal.MustParse(fee)
+	}
+	return e
+}
+
+func candles(closes ...string) []models.Candle {
+	out := make([]models.Candle, len(closes))
+	for i, c := range closes {
+		out[i] = models.Candle{Time: start.AddDate(0, 0, i), Close: decimal.MustParse(c)}
+	}
+	return out
+}
+
+func options() backfill.Options {
+	return backfill.Options{Registry: api.StaticRegistry(), Quote: "ZUSD", End: start.AddDate(0, 0, 4)}
+}
+
+func TestBuildValuesDailyBalances(t *testing.T) {
+	entries := []models.LedgerEntry{
+		entry(0, "ZUSD", "1000", ""),
+		// A trade on day 1: 500 USD and a fee for 1 ETH.
+		entry(1, "ZUSD", "-500", "1"),
+		entry(1, "XETH", "1", ""),
+		entry(3, "XETH", "-0.5", ""),
+	}
+	closes := backfill.Closes{}
+	closes.Add("ETH/USD", candles("480", "500", "550", "600"))
+
+	points, warnings := backfill.Build(entries, closes, options())
+	assert.Empty(t, warnings)
+	require.Len(t, points, 4)
+
+	assert.Equal(t, start, points[0].Time)
+	assert.Equal(t, "USD", points[0].Currency)
+	assert.Equal(t, []string{"1000", "999", "1049", "799"}, totals(points))
+
+	eth, ok := points[2].Holding("ETH")
+	require.True(t, ok)
+	assert.Equal(t, "1", eth.Balance.String())
+	assert.Equal(t, "550", eth.Price.String())
+	assert.Equal(t, points[2].Total, points[2].High)
+}
+
+func TestBuildSkipsDaysWithoutACloseAndLeavesOutUnpricedAssets(t *testing.T) {
+	entries := []models.LedgerEntry{
+		entry(0, "XXBT", "1", ""),
+		entry(0, "DOGE", "100", ""),
+	}
+	closes := backfill.Closes{}
+	closes.Add("XBT/USD", []models.Candle{
+		{Time: start, Close: decimal.NewFromInt(40000)},
+		{Time: start.AddDate(0, 0, 2), Close: decimal.NewFromInt(42000)},
+	})
+
+	points, warnings := backfill.Build(entries, closes, options())
+	require.Len(t, points, 2)
+	assert.Equal(t, start.AddDate(0, 0, 2), points[1].Time)
+	assert.Len(t, points[0].Holdings, 1)
+	assert.Equal(t, []string{
+		"2 days were skipped because XBT had no daily close",
+		"DOGE has no price in USD and was left out",
+	}, warnings)
+}
+
+func TestPairs(t *testing.T) {
+	entries := []models.LedgerEntry{
+		entry(0, "ZUSD", "100", ""),
+		entry(1, "XXBT", "1", ""),
+		entry(1, "XETH", "1", ""),
+		entry(2, "XXBT", "1", ""),
+	}
+	var names []string
+	for _, p := range backfill.Pairs(entries, options()) {
+		names = append(names, p.WsName)
+	}
+	assert.Equal(t, []string{"ETH/USD", "XBT/USD"}, names)
+}
+
+func totals(points []snapshot.Snapshot) []string {
+	out := make([]string, len(points))
+	for i, p := range points {
+		out[i] = p.Total.String()
+	}
+	return out
+}
+
+func TestPricesAtDailyClose(t *testing.T) {
+	closes := backfill.Closes{}
+	closes.Add("ETH/USD", candles("480", "500"))
+	closes.Add("XBT/USD", candles("40000", "42000"))
+	prices := backfill.Prices{Closes: closes, Opts: options()}
+
+	price, ok := prices.PriceAt("XETH", start.AddDate(0, 0, 1).Add(15*time.Hour))
+	require.True(t, ok)
+	assert.Equal(t, "500", price.String())
+
+	_, ok = prices.PriceAt("XETH", start.AddDate(0, 0, 2))
+	assert.False(t, ok, "a day without a close has no price")
+	_, ok = prices.PriceAt("DOGE", start)
+	assert.False(t, ok, "an asset without a route has no price")
+}
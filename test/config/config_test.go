@@ -3,6 +3,7 @@ package config_test
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/umit144/kraken-portfolio/internal/config"
 
@@ -211,3 +212,274 @@ func TestConfigValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfigEndpoints(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultRestURL, cfg.RestURL)
+	assert.Equal(t, config.DefaultWsURL, cfg.WsURL)
+
+	os.Setenv("KRAKEN_REST_URL", "http://localhost:8080")
+	os.Setenv("KRAKEN_WS_URL", "ws://localhost:8080/ws")
+	defer os.Unsetenv("KRAKEN_REST_URL")
+	defer os.Unsetenv("KRAKEN_WS_URL")
+
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080", cfg.RestURL)
+	assert.Equal(t, "ws://localhost:8080/ws", cfg.WsURL)
+}
+
+func TestLoadConfigWsVersion(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	os.Setenv("KRAKEN_WS_VERSION", "v2")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_WS_VERSION")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", cfg.WsVersion)
+	assert.Equal(t, config.DefaultWsV2URL, cfg.WsURL)
+	assert.NoError(t, cfg.Validate())
+
+	cfg.WsVersion = "v3"
+	assert.Equal(t, config.ErrInvalidWsVersion, cfg.Validate())
+}
+
+func TestLoadConfigPrivateFeed(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_PRIVATE_FEED")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.True(t, cfg.PrivateFeed)
+	assert.Equal(t, config.DefaultPrivateWsURL, cfg.PrivateWsURL)
+
+	os.Setenv("KRAKEN_PRIVATE_FEED", "false")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.False(t, cfg.PrivateFeed)
+
+	os.Setenv("KRAKEN_PRIVATE_FEED", "sometimes")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigQuoteCurrency(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_QUOTE_CURRENCY")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultQuoteCurrency, cfg.QuoteCurrency)
+
+	os.Setenv("KRAKEN_QUOTE_CURRENCY", " eur ")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", cfg.QuoteCurrency)
+}
+
+func TestParseTargetWeights(t *testing.T) {
+	weights, err := config.ParseTargetWeights(" btc=60, ETH=30%,usd=10 ,")
+	assert.NoError(t, err)
+	assert.Len(t, weights, 3)
+	assert.Equal(t, "60", weights["BTC"].String())
+	assert.Equal(t, "30", weights["ETH"].String())
+	assert.Equal(t, "10", weights["USD"].String())
+
+	for _, bad := range []string{"BTC", "BTC=abc", "BTC=-5", "=5", "BTC=60,btc=10", "BTC=60,ETH=50"} {
+		_, err := config.ParseTargetWeights(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLoadConfigTargetAllocation(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_TARGET_ALLOCATION")
+	defer os.Unsetenv("KRAKEN_DRIFT_TOLERANCE")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.TargetWeights)
+	assert.True(t, cfg.DriftTolerance.Equal(config.DefaultDriftTolerance))
+
+	os.Setenv("KRAKEN_TARGET_ALLOCATION", "BTC=70,ETH=30")
+	os.Setenv("KRAKEN_DRIFT_TOLERANCE", "2.5")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Len(t, cfg.TargetWeights, 2)
+	assert.Equal(t, "2.5", cfg.DriftTolerance.String())
+
+	os.Setenv("KRAKEN_DRIFT_TOLERANCE", "-1")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+
+	os.Setenv("KRAKEN_DRIFT_TOLERANCE", "2.5")
+	os.Setenv("KRAKEN_TARGET_ALLOCATION", "BTC=70,ETH=40")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigTradingEnabled(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_TRADING_ENABLED")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.False(t, cfg.TradingEnabled)
+
+	os.Setenv("KRAKEN_TRADING_ENABLED", "true")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.True(t, cfg.TradingEnabled)
+
+	os.Setenv("KRAKEN_TRADING_ENABLED", "sometimes")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigAlerts(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_ALERTS")
+	defer os.Unsetenv("KRAKEN_ALERT_HYSTERESIS")
+	defer os.Unsetenv("KRAKEN_ALERT_COOLDOWN")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.AlertRules)
+	assert.Equal(t, config.DefaultAlertCooldown, cfg.AlertCooldown)
+	assert.True(t, cfg.AlertHysteresis.Equal(config.DefaultAlertHysteresis))
+
+	os.Setenv("KRAKEN_ALERTS", "BTC>70000,ETH~5%/30m")
+	os.Setenv("KRAKEN_ALERT_HYSTERESIS", "1%")
+	os.Setenv("KRAKEN_ALERT_COOLDOWN", "1h")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "BTC>70000,ETH~5%/30m", cfg.AlertRules)
+	assert.Equal(t, "1", cfg.AlertHysteresis.String())
+	assert.Equal(t, time.Hour, cfg.AlertCooldown)
+
+	os.Setenv("KRAKEN_ALERT_COOLDOWN", "-1h")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigNotifiers(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	os.Setenv("KRAKEN_WEBHOOK_URL", "https://hooks.example.com/kraken")
+	os.Setenv("KRAKEN_WEBHOOK_SECRET", "s3cret")
+	os.Setenv("KRAKEN_SMTP_ADDR", "smtp.example.com:587")
+	os.Setenv("KRAKEN_SMTP_TO", "me@example.com, you@example.com")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_WEBHOOK_URL")
+	defer os.Unsetenv("KRAKEN_WEBHOOK_SECRET")
+	defer os.Unsetenv("KRAKEN_SMTP_ADDR")
+	defer os.Unsetenv("KRAKEN_SMTP_TO")
+	defer os.Unsetenv("KRAKEN_SMTP_FROM")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/kraken", cfg.WebhookURL)
+	assert.Equal(t, "s3cret", cfg.WebhookSecret)
+	assert.Equal(t, []string{"me@example.com", "you@example.com"}, cfg.SMTPTo)
+	assert.ErrorIs(t, cfg.Validate(), config.ErrIncompleteSMTP)
+
+	os.Setenv("KRAKEN_SMTP_FROM", "tracker@example.com")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoadConfigSnapshots(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_SNAPSHOT_DIR")
+	defer os.Unsetenv("KRAKEN_SNAPSHOT_INTERVAL")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultSnapshotInterval, cfg.SnapshotInterval)
+
+	os.Setenv("KRAKEN_SNAPSHOT_DIR", "")
+	os.Setenv("KRAKEN_SNAPSHOT_INTERVAL", "30s")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.SnapshotDir)
+	assert.Equal(t, 30*time.Second, cfg.SnapshotInterval)
+
+	os.Setenv("KRAKEN_SNAPSHOT_INTERVAL", "0s")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigPerformance(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_PERFORMANCE_PERIOD")
+	defer os.Unsetenv("KRAKEN_RISK_FREE_RATE")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultPerformancePeriod, cfg.PerformancePeriod)
+	assert.True(t, cfg.RiskFreeRate.IsZero())
+
+	os.Setenv("KRAKEN_PERFORMANCE_PERIOD", " YTD ")
+	os.Setenv("KRAKEN_RISK_FREE_RATE", "4.5%")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "ytd", cfg.PerformancePeriod)
+	assert.Equal(t, "4.5", cfg.RiskFreeRate.String())
+
+	os.Setenv("KRAKEN_RISK_FREE_RATE", "high")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigSparklineWindow(t *testing.T) {
+	os.Setenv("KRAKEN_API_KEY", "test-key")
+	os.Setenv("KRAKEN_API_SECRET", "test-secret")
+	defer os.Unsetenv("KRAKEN_API_KEY")
+	defer os.Unsetenv("KRAKEN_API_SECRET")
+	defer os.Unsetenv("KRAKEN_SPARKLINE_WINDOW")
+
+	cfg, err := config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, config.DefaultSparklineWindow, cfg.SparklineWindow)
+
+	os.Setenv("KRAKEN_SPARKLINE_WINDOW", "2h")
+	cfg, err = config.LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, cfg.SparklineWindow)
+
+	os.Setenv("KRAKEN_SPARKLINE_WINDOW", "0s")
+	_, err = config.LoadConfig("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package decimal_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "0", want: "0"},
+		{input: "1.50000000", want: "1.5"},
+		{input: "-0.00000001", want: "-0.00000001"},
+		{input: "1e-8", want: "0.00000001"},
+		{input: "2.5E3", want: "2500"},
+		{input: "+12.340", want: "12.34"},
+		{input: ".5", want: "0.5"},
+		{input: "5.", want: "5"},
+		{input: "123456789012345678901234567890.123456789", want: "123456789012345678901234567890.123456789"},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+		{input: "12abc", wantErr: true},
+		{input: "1.2.3", wantErr: true},
+		{input: " 1", wantErr: true},
+		{input: "-", wantErr: true},
+		{input: ".", wantErr: true},
+		{input: "1e", wantErr: true},
+		{input: "1e99999", wantErr: true},
+		{input: "NaN", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := decimal.Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	total := decimal.Zero
+	for i := 0; i < 10; i++ {
+		total = total.Add(decimal.MustParse("0.1"))
+	}
+	assert.Equal(t, "1", total.String())
+	assert.True(t, total.Equal(decimal.One))
+
+	value := decimal.MustParse("0.12345678").Mul(decimal.MustParse("65432.1"))
+	assert.Equal(t, "8078.036374638", value.String())
+
+	diff := decimal.MustParse("0.3").Sub(decimal.MustParse("0.1")).Sub(decimal.MustParse("0.2"))
+	assert.True(t, diff.IsZero())
+}
+
+func TestDivAndRound(t *testing.T) {
+	tests := []struct {
+		name string
+		got  decimal.Decimal
+		want string
+	}{
+		{"one third", decimal.One.Div(decimal.NewFromInt(3), 4), "0.3333"},
+		{"two thirds", decimal.NewFromInt(2).Div(decimal.NewFromInt(3), 4), "0.6667"},
+		{"negative half", decimal.MustParse("-1").Div(decimal.NewFromInt(8), 2), "-0.13"},
+		{"round half up", decimal.MustParse("2.345").Round(2), "2.35"},
+		{"round half negative", decimal.MustParse("-2.345").Round(2), "-2.35"},
+		{"truncate", decimal.MustParse("2.349").Truncate(2), "2.34"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.got.String())
+		})
+	}
+
+	assert.Panics(t, func() { decimal.One.Div(decimal.Zero, 2) })
+}
+
+func TestStringFixed(t *testing.T) {
+	assert.Equal(t, "1234.57", decimal.MustParse("1234.5678").StringFixed(2))
+	assert.Equal(t, "0.10000000", decimal.MustParse("0.1").StringFixed(8))
+	assert.Equal(t, "-0.05", decimal.MustParse("-0.045").StringFixed(2))
+	assert.Equal(t, "0.00", decimal.Zero.StringFixed(2))
+	assert.Equal(t, "3", decimal.MustParse("2.5").StringFixed(0))
+}
+
+func TestJSON(t *testing.T) {
+	var payload struct {
+		Quoted decimal.Decimal `json:"quoted"`
+		Number decimal.Decimal `json:"number"`
+	}
+
+	err := json.Unmarshal([]byte(`{"quoted":"0.00000001","number":65000.12345678901}`), &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00000001", payload.Quoted.String())
+	assert.Equal(t, "65000.12345678901", payload.Number.String())
+
+	out, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"quoted":"0.00000001","number":"65000.12345678901"}`, string(out))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"quoted":"12abc"}`), &payload))
+}
+
+func TestNewFromFloat(t *testing.T) {
+	assert.Equal(t, "0.1", decimal.NewFromFloat(0.1).String())
+	assert.Equal(t, "3000", decimal.NewFromFloat(3000.0).String())
+	assert.Equal(t, "-1.25", decimal.NewFromFloat(-1.25).String())
+}
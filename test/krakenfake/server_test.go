@@ -0,0 +1,161 @@
+package krakenfake_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/krakenfake"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = base64.StdEncoding.EncodeToString([]byte("test-secret"))
+
+func privatePost(t *testing.T, fake *krakenfake.Server, key, sign, data string) map[string]interface{} {
+	req, err := http.NewRequest("POST", fake.URL()+"/0/private/Balance", strings.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Set("API-Key", key)
+	req.Header.Set("API-Sign", sign)
+
+	resp, err := fake.Client().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func TestPrivateSignatureVerification(t *testing.T) {
+	fake := krakenfake.New("test-key", testSecret)
+	defer fake.Close()
+	fake.SetBalances(map[string]string{"XXBT": "0.5"})
+
+	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+	data := "nonce=" + nonce
+
+	tests := []struct {
+		name    string
+		key     string
+		sign    string
+		wantErr string
+	}{
+		{
+			name:    "wrong key",
+			key:     "other-key",
+			sign:    krakenfake.Sign(testSecret, "/0/private/Balance", data, nonce),
+			wantErr: "EAPI:Invalid key",
+		},
+		{
+			name:    "wrong signature",
+			key:     "test-key",
+			sign:    krakenfake.Sign(testSecret, "/0/private/Ledgers", data, nonce),
+			wantErr: "EAPI:Invalid signature",
+		},
+		{
+			name: "valid request",
+			key:  "test-key",
+			sign: krakenfake.Sign(testSecret, "/0/private/Balance", data, nonce),
+		},
+		{
+			name:    "replayed nonce",
+			key:     "test-key",
+			sign:    krakenfake.Sign(testSecret, "/0/private/Balance", data, nonce),
+			wantErr: "EAPI:Invalid nonce",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := privatePost(t, fake, tt.key, tt.sign, data)
+			errs := body["error"].([]interface{})
+			if tt.wantErr == "" {
+				assert.Empty(t, errs)
+				assert.Equal(t, map[string]interface{}{"XXBT": "0.5"}, body["result"])
+			} else {
+				assert.Equal(t, []interface{}{tt.wantErr}, errs)
+			}
+		})
+	}
+}
+
+func TestWebSocketSubscribeAndPush(t *testing.T) {
+	fake := krakenfake.New("test-key", testSecret)
+	defer fake.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial(fake.WsURL(), nil)
+	assert.NoError(t, err)
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(map[string]interface{}{
+		"event":        "subscribe",
+		"pair":         []string{"XBT/USD"},
+		"subscription": map[string]string{"name": "ticker"},
+	}))
+
+	pairs, err := fake.WaitForSubscription(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"XBT/USD"}, pairs)
+	assert.NoError(t, fake.PushTicker("XBT/USD", "65000.0"))
+	assert.Error(t, fake.PushTicker("ETH/USD", "3000.0"))
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var frame []interface{}
+		_, message, err := ws.ReadMessage()
+		require.NoError(t, err)
+		if json.Unmarshal(message, &frame) != nil {
+			continue
+		}
+
+		assert.Equal(t, "XBT/USD", frame[3])
+		ticker := frame[1].(map[string]interface{})
+		assert.Equal(t, "65000.0", ticker["c"].([]interface{})[0])
+		return
+	}
+}
+
+func TestWebSocketV2SubscriptionAcks(t *testing.T) {
+	fake := krakenfake.New("test-key", testSecret)
+	defer fake.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial(fake.WsV2URL(), nil)
+	assert.NoError(t, err)
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(map[string]interface{}{
+		"method": "subscribe",
+		"params": map[string]interface{}{
+			"channel": "ticker",
+			"symbol":  []string{"BTC/USD", "FOO/USD"},
+		},
+	}))
+
+	pairs, err := fake.WaitForSubscription(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"XBT/USD"}, pairs)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var acks []map[string]interface{}
+	for len(acks) < 2 {
+		var msg map[string]interface{}
+		if err := ws.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg["method"] == "subscribe" {
+			acks = append(acks, msg)
+		}
+	}
+
+	assert.Equal(t, true, acks[0]["success"])
+	assert.Equal(t, "BTC/USD", acks[0]["result"].(map[string]interface{})["symbol"])
+	assert.Equal(t, false, acks[1]["success"])
+	assert.Contains(t, acks[1]["error"], "FOO/USD")
+}
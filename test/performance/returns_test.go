@@ -0,0 +1,162 @@
+package performance_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/performance"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// daily builds one point per day from start.
+func daily(values ...string) []performance.Point {
+	points := make([]performance.Point, len(values))
+	for i, v := range values {
+		points[i] = performance.Point{Time: start.AddDate(0, 0, i), Value: decimal.MustParse(v)}
+	}
+	return points
+}
+
+func flow(day int, amount string) performance.Flow {
+	return performance.Flow{Time: start.AddDate(0, 0, day).Add(-time.Hour), Amount: decimal.MustParse(amount)}
+}
+
+func TestAnalyzeWithoutFlows(t *testing.T) {
+	r, err := performance.Analyze(daily("100", "110", "99", "121"), nil, performance.Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "21", r.TWR.String())
+	assert.True(t, r.HasMWR)
+	assert.Equal(t, "21", r.MWR.Round(2).String())
+	assert.Equal(t, "10", r.MaxDrawdown.String())
+	assert.Equal(t, "21", r.Gain.String())
+	assert.True(t, r.HasRisk)
+	assert.True(t, r.Volatility.Sign() > 0)
+}
+
+func TestAnalyzeTakesOutDeposits(t *testing.T) {
+	// 10% on the first 100, then a deposit of 100 and 20/210 more.
+	r, err := performance.Analyze(daily("100", "210", "230"), []performance.Flow{flow(1, "100")}, performance.Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "100", r.Deposits.String())
+	assert.Equal(t, "30", r.Gain.String())
+	assert.Equal(t, "20.4762", r.TWR.String())
+	// The deposit was invested for about half the period:
+	// 100(1+m) + 100(1+m)^0.52 = 230.
+	assert.InDelta(t, 20.03, r.MWR.Float64(), 0.01)
+}
+
+func TestAnalyzeWithdrawalIsNotADrawdown(t *testing.T) {
+	r, err := performance.Analyze(daily("100", "50", "50"), []performance.Flow{flow(1, "-50")}, performance.Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "50", r.Withdrawals.String())
+	assert.Equal(t, "0", r.TWR.String())
+	assert.Equal(t, "0", r.MaxDrawdown.String())
+	assert.Equal(t, "0", r.Gain.String())
+}
+
+func TestAnalyzeIgnoresFlowsOutsideThePeriod(t *testing.T) {
+	flows := []performance.Flow{flow(0, "1000"), flow(5, "1000")}
+	r, err := performance.Analyze(daily("100", "110"), flows, performance.Options{})
+	require.NoError(t, err)
+	assert.True(t, r.Deposits.IsZero())
+	assert.Equal(t, "10", r.TWR.String())
+}
+
+func TestAnalyzeAnnualizesIRR(t *testing.T) {
+	points := []performance.Point{
+		{Time: start, Value: decimal.NewFromInt(100)},
+		{Time: start.Add(time.Duration(365.25*24*float64(time.Hour)) / 2), Value: decimal.NewFromInt(110)},
+	}
+	r, err := performance.Analyze(points, nil, performance.Options{})
+	require.NoError(t, err)
+	assert.True(t, r.HasIRR)
+	assert.InDelta(t, 21, r.IRR.Float64(), 0.01)
+}
+
+func TestAnalyzeSharpe(t *testing.T) {
+	r, err := performance.Analyze(daily("100", "101", "102.01", "102.5", "103.6"), nil, performance.Options{})
+	require.NoError(t, err)
+	require.True(t, r.HasRisk)
+	assert.True(t, r.Sharpe.Sign() > 0)
+
+	// A risk-free rate above the return makes the ratio negative.
+	r, err = performance.Analyze(daily("100", "101", "102.01", "102.5", "103.6"), nil, performance.Options{RiskFree: decimal.NewFromInt(1000000)})
+	require.NoError(t, err)
+	assert.True(t, r.Sharpe.Sign() < 0)
+}
+
+func TestAnalyzeNeedsTwoPoints(t *testing.T) {
+	_, err := performance.Analyze(daily("100"), nil, performance.Options{})
+	assert.ErrorIs(t, err, performance.ErrTooFewPoints)
+}
+
+func TestFlowsFromLedger(t *testing.T) {
+	at := float64(start.Unix())
+	entries := []models.LedgerEntry{
+		{RefID: "D1", Time: at, Type: "deposit", Asset: "ZUSD", Amount: decimal.MustParse("500")},
+		{RefID: "D2", Time: at + 60, Type: "deposit", Asset: "XXBT", Amount: decimal.MustParse("0.1"), Fee: decimal.MustParse("0.001")},
+		{RefID: "T1", Time: at + 120, Type: "trade", Asset: "XXBT", Amount: decimal.MustParse("0.2")},
+		{RefID: "W1", Time: at + 180, Type: "withdrawal", Asset: "XETH", Amount: decimal.MustParse("-2")},
+		{RefID: "D3", Time: at + 240, Type: "deposit", Asset: "DOGE", Amount: decimal.MustParse("10")},
+	}
+	prices := map[string]string{"ZUSD": "1", "XXBT": "50000", "XETH": "3000"}
+	flows, warnings := performance.Flows(entries, func(asset string, _ time.Time) (decimal.Decimal, bool) {
+		p, ok := prices[asset]
+		if !ok {
+			return decimal.Zero, false
+		}
+		return decimal.MustParse(p), true
+	})
+
+	require.Len(t, flows, 3)
+	assert.Equal(t, "500", flows[0].Amount.String())
+	assert.Equal(t, "5000", flows[1].Amount.String())
+	assert.Equal(t, "-6000", flows[2].Amount.String())
+	assert.Equal(t, start.Add(3*time.Minute), flows[2].Time)
+	assert.Equal(t, []string{"DOGE deposits or withdrawals have no price and were left out"}, warnings)
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Time{
+		"30d": now.AddDate(0, 0, -30),
+		"2w":  now.AddDate(0, 0, -14),
+		"1y":  now.AddDate(-1, 0, 0),
+		"12h": now.Add(-12 * time.Hour),
+		"YTD": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"all": {},
+	}
+	for s, want := range cases {
+		got, err := performance.ParsePeriod(s, now)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+
+	for _, bad := range []string{"", "soon", "-3d", "0d"} {
+		_, err := performance.ParsePeriod(bad, now)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	r, err := performance.Analyze(daily("100", "210", "230"), []performance.Flow{flow(1, "100")}, performance.Options{})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, performance.WriteReport(&buf, r, "USD", []string{"DOGE deposits have no price"}))
+	out := buf.String()
+	assert.Contains(t, out, "Performance from 2024-01-01 00:00 to 2024-01-03 00:00 UTC")
+	assert.Contains(t, out, "Deposits                 100.00 USD")
+	assert.Contains(t, out, "Time-weighted return     20.48%")
+	assert.Contains(t, out, "  - DOGE deposits have no price")
+}
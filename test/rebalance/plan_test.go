@@ -0,0 +1,132 @@
+package rebalance_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/rebalance"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	btcPair = models.AssetPair{Altname: "XBTUSD", LotDecimals: 8, OrderMin: "0.0001"}
+	ethPair = models.AssetPair{Altname: "ETHUSD", LotDecimals: 8, OrderMin: "0.002"}
+)
+
+func holding(asset, balance, price string, pair models.AssetPair) rebalance.Holding {
+	b, p := decimal.MustParse(balance), decimal.MustParse(price)
+	return rebalance.Holding{Asset: asset, Name: asset, Balance: b, Price: p, Value: b.Mul(p), Pair: pair}
+}
+
+// portfolio is 60% BTC, 20% ETH and 20% cash, worth 10000.
+func portfolio() []rebalance.Holding {
+	return []rebalance.Holding{
+		holding("XXBT", "0.1", "60000", btcPair),
+		holding("XETH", "1", "2000", ethPair),
+		holding("ZUSD", "2000", "1", models.AssetPair{}),
+	}
+}
+
+func targets(weights ...string) map[string]decimal.Decimal {
+	out := make(map[string]decimal.Decimal)
+	for i := 0; i < len(weights); i += 2 {
+		out[weights[i]] = decimal.MustParse(weights[i+1])
+	}
+	return out
+}
+
+func options(t map[string]decimal.Decimal) rebalance.Options {
+	return rebalance.Options{Quote: "ZUSD", Targets: t, FeeRate: decimal.MustParse("0.0026")}
+}
+
+func TestBuildSellsBeforeBuys(t *testing.T) {
+	plan, err := rebalance.Build(portfolio(), options(targets("XXBT", "50", "XETH", "40")))
+	require.NoError(t, err)
+	require.Len(t, plan.Orders, 2)
+
+	sell, buy := plan.Orders[0], plan.Orders[1]
+	assert.Equal(t, "sell", sell.Side)
+	assert.Equal(t, "XBTUSD", sell.Pair)
+	assert.Equal(t, "0.01666666", sell.Volume.String())
+	assert.Equal(t, "999.9996", sell.Value.String())
+
+	assert.Equal(t, "buy", buy.Side)
+	assert.Equal(t, "1", buy.Volume.String())
+	assert.Equal(t, "5.2", buy.Fee.String())
+
+	assert.Equal(t, "10000", plan.Total.String())
+	assert.Equal(t, "7.80", plan.Fees.StringFixed(2))
+	assert.Equal(t, "992.19960104", plan.CashAfter.String())
+	assert.Empty(t, plan.Skipped)
+	assert.Empty(t, plan.Notes)
+}
+
+func TestBuildScalesBuysToCash(t *testing.T) {
+	plan, err := rebalance.Build(portfolio(), options(targets("XXBT", "50", "XETH", "40", "ZUSD", "10")))
+	require.NoError(t, err)
+	require.Len(t, plan.Orders, 2)
+
+	buy := plan.Orders[1]
+	assert.True(t, buy.Volume.LessThan(decimal.One))
+	assert.True(t, buy.Volume.GreaterThan(decimal.MustParse("0.99")))
+	assert.False(t, plan.CashAfter.LessThan(decimal.NewFromInt(1000)), "cash fell below its target: %v", plan.CashAfter)
+	assert.Len(t, plan.Notes, 1)
+}
+
+func TestBuildNoCashSkipsBuys(t *testing.T) {
+	holdings := portfolio()[:2]
+	plan, err := rebalance.Build(holdings, options(targets("XETH", "50")))
+	require.NoError(t, err)
+
+	assert.Empty(t, plan.Orders)
+	assert.Equal(t, []rebalance.Skip{{Name: "XETH", Reason: "not enough cash"}}, plan.Skipped)
+}
+
+func TestBuildTolerance(t *testing.T) {
+	opts := options(targets("XXBT", "50", "XETH", "40"))
+	opts.Tolerance = decimal.NewFromInt(15)
+
+	plan, err := rebalance.Build(portfolio(), opts)
+	require.NoError(t, err)
+	require.Len(t, plan.Orders, 1)
+	assert.Equal(t, "XETH", plan.Orders[0].Asset)
+}
+
+func TestBuildMinimumsAndMissingPairs(t *testing.T) {
+	holdings := []rebalance.Holding{
+		holding("XXBT", "0.1665", "60000", btcPair),
+		holding("FOO", "10", "1", models.AssetPair{}),
+		holding("ZUSD", "0", "1", models.AssetPair{}),
+	}
+	plan, err := rebalance.Build(holdings, options(targets("XXBT", "99.95", "FOO", "0")))
+	require.NoError(t, err)
+
+	assert.Empty(t, plan.Orders)
+	assert.ElementsMatch(t, []rebalance.Skip{
+		{Name: "XXBT", Reason: "below the minimum order of 0.0001"},
+		{Name: "FOO", Reason: "no direct pair with the quote currency"},
+	}, plan.Skipped)
+}
+
+func TestBuildEmptyPortfolio(t *testing.T) {
+	_, err := rebalance.Build(nil, options(targets("XXBT", "100")))
+	assert.ErrorIs(t, err, rebalance.ErrEmptyPortfolio)
+}
+
+func TestWritePlan(t *testing.T) {
+	plan, err := rebalance.Build(portfolio(), options(targets("XXBT", "50", "XETH", "40")))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, rebalance.WritePlan(&buf, plan, "USD"))
+	out := buf.String()
+	assert.Contains(t, out, "Portfolio value: 10000.00 USD")
+	assert.Contains(t, out, "XBTUSD")
+	assert.Contains(t, out, "0.01666666")
+	assert.Contains(t, out, "Estimated fees: 7.80 USD")
+	assert.Contains(t, out, "Cash: 2000.00 -> 992.20 USD")
+}
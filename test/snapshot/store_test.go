@@ -0,0 +1,244 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/snapshot"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+
+// point is a portfolio of one BTC at price, plus 100 USD.
+func point(t *testing.T, at time.Time, price string) snapshot.Snapshot {
+	p := decimal.MustParse(price)
+	snap, ok := snapshot.FromAssets([]models.AssetValue{
+		{Asset: "USD", Balance: decimal.NewFromInt(100), Price: decimal.NewFromInt(1), Value: decimal.NewFromInt(100)},
+		{Asset: "BTC", Balance: decimal.NewFromInt(1), Price: p, Value: p},
+	}, "USD", at)
+	require.True(t, ok)
+	return snap
+}
+
+func open(t *testing.T, dir string, opts snapshot.Options) *snapshot.Store {
+	store, err := snapshot.Open(dir, opts)
+	require.NoError(t, err)
+	return store
+}
+
+func totals(points []snapshot.Snapshot) []string {
+	out := make([]string, len(points))
+	for i, p := range points {
+		out[i] = p.Total.String()
+	}
+	return out
+}
+
+func TestFromAssetsNeedsEveryPrice(t *testing.T) {
+	_, ok := snapshot.FromAssets([]models.AssetValue{
+		{Asset: "BTC", Balance: decimal.NewFromInt(1)},
+	}, "USD", start)
+	assert.False(t, ok)
+
+	snap := point(t, start, "50000")
+	assert.Equal(t, "50100", snap.Total.String())
+	assert.Equal(t, "BTC", snap.Holdings[0].Asset)
+}
+
+func TestAppendMergesWithinMinute(t *testing.T) {
+	store := open(t, t.TempDir(), snapshot.Options{})
+
+	require.NoError(t, store.Append(point(t, start, "100")))
+	require.NoError(t, store.Append(point(t, start.Add(20*time.Second), "300")))
+	require.NoError(t, store.Append(point(t, start.Add(40*time.Second), "50")))
+	require.NoError(t, store.Append(point(t, start.Add(time.Minute), "200")))
+
+	points, err := store.Query(snapshot.Minute, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+
+	first := points[0]
+	assert.Equal(t, start, first.Time)
+	assert.Equal(t, "150", first.Total.String())
+	assert.Equal(t, "200", first.Open.String())
+	assert.Equal(t, "400", first.High.String())
+	assert.Equal(t, "150", first.Low.String())
+	// The minute still being filled is included.
+	assert.Equal(t, "300", points[1].Total.String())
+
+	assert.ErrorIs(t, store.Append(point(t, start, "100")), snapshot.ErrOutOfOrder)
+}
+
+func TestRollups(t *testing.T) {
+	store := open(t, t.TempDir(), snapshot.Options{})
+
+	// 23:00-00:59 every 10 minutes, then one point at 01:00.
+	for i := 0; i <= 12; i++ {
+		price := "100"
+		if i == 3 {
+			price = "900"
+		}
+		if i >= 6 {
+			price = "500"
+		}
+		require.NoError(t, store.Append(point(t, start.Add(time.Duration(i)*10*time.Minute), price)))
+	}
+
+	hours, err := store.Query(snapshot.Hour, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, hours, 2)
+	assert.Equal(t, start, hours[0].Time)
+	assert.Equal(t, []string{"200", "600"}, totals(hours))
+	assert.Equal(t, "1000", hours[0].High.String())
+	assert.Equal(t, "200", hours[0].Low.String())
+
+	days, err := store.Query(snapshot.Day, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+	assert.Equal(t, start.Truncate(24*time.Hour), days[0].Time)
+	assert.Equal(t, "200", days[0].Open.String())
+	assert.Equal(t, "1000", days[0].High.String())
+}
+
+func TestReopenCatchesUp(t *testing.T) {
+	dir := t.TempDir()
+	store := open(t, dir, snapshot.Options{})
+	require.NoError(t, store.Append(point(t, start, "100")))
+	require.NoError(t, store.Append(point(t, start.Add(30*time.Minute), "300")))
+	require.NoError(t, store.Close())
+
+	// Picking up again in the same minute, and next in a later hour.
+	store = open(t, dir, snapshot.Options{})
+	require.NoError(t, store.Append(point(t, start.Add(30*time.Minute+30*time.Second), "500")))
+	require.NoError(t, store.Append(point(t, start.Add(3*time.Hour), "700")))
+
+	minutes, err := store.Query(snapshot.Minute, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"200", "600", "800"}, totals(minutes))
+	assert.Equal(t, "400", minutes[1].Open.String())
+
+	hours, err := store.Query(snapshot.Hour, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, hours, 1)
+	assert.Equal(t, "600", hours[0].Total.String())
+	assert.Equal(t, "200", hours[0].Low.String())
+}
+
+func TestQueryRange(t *testing.T) {
+	store := open(t, t.TempDir(), snapshot.Options{})
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.Append(point(t, start.Add(time.Duration(i)*time.Minute), "100")))
+	}
+
+	points, err := store.Query(snapshot.Minute, start.Add(time.Minute), start.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, start.Add(time.Minute), points[0].Time)
+}
+
+func TestPruneDropsExpiredFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := open(t, dir, snapshot.Options{Retention: map[snapshot.Resolution]time.Duration{snapshot.Minute: 24 * time.Hour}})
+	require.NoError(t, store.Append(point(t, start, "100")))
+	require.NoError(t, store.Append(point(t, start.Add(2*time.Hour), "200")))
+	require.NoError(t, store.Close())
+
+	require.NoError(t, store.Prune(start.Add(36*time.Hour)))
+	points, err := store.Query(snapshot.Minute, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"300"}, totals(points))
+
+	// The rollups have no retention and survive.
+	hours, err := store.Query(snapshot.Hour, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, hours, 1)
+}
+
+func TestCorruptLineIsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	store := open(t, dir, snapshot.Options{})
+	require.NoError(t, store.Append(point(t, start, "100")))
+	require.NoError(t, store.Close())
+
+	path := filepath.Join(dir, "1m", "2024-03-01.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time":"2024-03-01T23:01:00Z","tot`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	points, err := open(t, dir, snapshot.Options{}).Query(snapshot.Minute, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, points, 1)
+}
+
+func TestWriteCSV(t *testing.T) {
+	points := []snapshot.Snapshot{point(t, start, "100")}
+	later := point(t, start.Add(time.Hour), "200")
+	later.Holdings = later.Holdings[:1]
+	points = append(points, later)
+
+	var buf bytes.Buffer
+	require.NoError(t, snapshot.WriteCSV(&buf, points))
+	assert.Equal(t, "time,currency,total,open,high,low,BTC,USD\n"+
+		"2024-03-01T23:00:00Z,USD,200,200,200,200,100,100\n"+
+		"2024-03-02T00:00:00Z,USD,300,300,300,300,200,\n", buf.String())
+}
+
+func TestPricesPriceAt(t *testing.T) {
+	prices := snapshot.Prices{point(t, start, "100"), point(t, start.Add(time.Hour), "200")}
+	prices[1].Holdings = append(prices[1].Holdings, snapshot.Holding{Asset: "ETH", Price: decimal.NewFromInt(10)})
+
+	price, ok := prices.PriceAt("BTC", start.Add(30*time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, "100", price.String())
+
+	// Before the first snapshot, and for an asset only held later, the next
+	// one is used.
+	price, _ = prices.PriceAt("BTC", start.Add(-time.Hour))
+	assert.Equal(t, "100", price.String())
+	price, _ = prices.PriceAt("ETH", start)
+	assert.Equal(t, "10", price.String())
+
+	price, ok = prices.PriceAt("USD", start)
+	assert.True(t, ok)
+	assert.Equal(t, "1", price.String())
+
+	_, ok = prices.PriceAt("SOL", start)
+	assert.False(t, ok)
+}
+
+func TestBackfillKeepsRecordedDays(t *testing.T) {
+	dir := t.TempDir()
+	store := open(t, dir, snapshot.Options{})
+	require.NoError(t, store.Append(point(t, start, "100")))
+
+	day := 24 * time.Hour
+	var points []snapshot.Snapshot
+	for i := 3; i >= 0; i-- {
+		points = append(points, point(t, start.Truncate(day).Add(-time.Duration(i)*day), "50"))
+	}
+	written, err := store.Backfill(points)
+	require.NoError(t, err)
+	assert.Equal(t, 3, written)
+	require.NoError(t, store.Close())
+
+	days, err := open(t, dir, snapshot.Options{}).Query(snapshot.Day, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, days, 3)
+	assert.Equal(t, start.Truncate(day).Add(-3*day), days[0].Time)
+	assert.Equal(t, "150", days[2].Total.String())
+
+	// An empty store takes everything.
+	written, err = open(t, t.TempDir(), snapshot.Options{}).Backfill(points)
+	require.NoError(t, err)
+	assert.Equal(t, 4, written)
+}
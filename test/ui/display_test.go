@@ -2,11 +2,14 @@ package ui_test
 
 import (
 	"bytes"
+	"strconv"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/umit144/kraken-portfolio/internal/models"
 	"github.com/umit144/kraken-portfolio/internal/ui"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,33 +23,33 @@ func TestGetPriceColor(t *testing.T) {
 	display := ui.NewDisplayWithWriter(nil, 80)
 	tests := []struct {
 		name     string
-		current  float64
-		previous float64
+		current  string
+		previous string
 		want     string
 	}{
 		{
 			name:     "price increase",
-			current:  3000.0,
-			previous: 2900.0,
+			current:  "3000.0",
+			previous: "2900.0",
 			want:     "\033[32m",
 		},
 		{
 			name:     "price decrease",
-			current:  2800.0,
-			previous: 2900.0,
+			current:  "2800.0",
+			previous: "2900.0",
 			want:     "\033[31m",
 		},
 		{
 			name:     "price unchanged",
-			current:  3000.0,
-			previous: 3000.0,
+			current:  "3000.0",
+			previous: "3000.0",
 			want:     "\033[0m",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := display.GetPriceColor(tt.current, tt.previous)
+			got := display.GetPriceColor(decimal.MustParse(tt.current), decimal.MustParse(tt.previous))
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -55,28 +58,36 @@ func TestGetPriceColor(t *testing.T) {
 func TestFormatPrice(t *testing.T) {
 	display := ui.NewDisplayWithWriter(nil, 80)
 	tests := []struct {
-		name  string
-		price float64
-		color string
-		want  string
+		name     string
+		price    string
+		decimals int
+		color    string
+		want     string
 	}{
 		{
 			name:  "positive price green",
-			price: 3000.0,
+			price: "3000.0",
 			color: "\033[32m",
 			want:  "\033[32m$3000.00\033[0m",
 		},
 		{
 			name:  "positive price red",
-			price: 2900.0,
+			price: "2900.0",
 			color: "\033[31m",
 			want:  "\033[31m$2900.00\033[0m",
 		},
+		{
+			name:     "pair precision",
+			price:    "0.123456",
+			decimals: 5,
+			color:    "\033[0m",
+			want:     "\033[0m$0.12346\033[0m",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := display.FormatPrice(tt.price, tt.color)
+			got := display.FormatPrice(decimal.MustParse(tt.price), tt.decimals, tt.color)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -85,30 +96,37 @@ func TestFormatPrice(t *testing.T) {
 func TestFormatBalance(t *testing.T) {
 	display := ui.NewDisplayWithWriter(nil, 80)
 	tests := []struct {
-		name    string
-		balance float64
-		want    string
+		name     string
+		balance  string
+		decimals int
+		want     string
 	}{
 		{
 			name:    "large balance",
-			balance: 1234.5678,
+			balance: "1234.5678",
 			want:    "1234.57",
 		},
 		{
 			name:    "small balance",
-			balance: 0.12345678,
+			balance: "0.12345678",
 			want:    "0.12345678",
 		},
 		{
 			name:    "zero balance",
-			balance: 0.0,
+			balance: "0",
 			want:    "0.00000000",
 		},
+		{
+			name:     "asset precision",
+			balance:  "1234.5678",
+			decimals: 5,
+			want:     "1234.56780",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := display.FormatBalance(tt.balance)
+			got := display.FormatBalance(decimal.MustParse(tt.balance), tt.decimals)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -121,24 +139,24 @@ func TestRenderPortfolio(t *testing.T) {
 	assets := []models.AssetValue{
 		{
 			Asset:     "ETH",
-			Balance:   1.5,
-			Price:     3000.0,
-			PrevPrice: 2900.0,
-			USDValue:  4500.0,
+			Balance:   decimal.MustParse("1.5"),
+			Price:     decimal.MustParse("3000.0"),
+			PrevPrice: decimal.MustParse("2900.0"),
+			Value:     decimal.MustParse("4500.0"),
 		},
 		{
 			Asset:     "SOL",
-			Balance:   10.0,
-			Price:     100.0,
-			PrevPrice: 110.0,
-			USDValue:  1000.0,
+			Balance:   decimal.MustParse("10.0"),
+			Price:     decimal.MustParse("100.0"),
+			PrevPrice: decimal.MustParse("110.0"),
+			Value:     decimal.MustParse("1000.0"),
 		},
 		{
 			Asset:     "USD",
-			Balance:   1000.0,
-			Price:     1.0,
-			PrevPrice: 1.0,
-			USDValue:  1000.0,
+			Balance:   decimal.MustParse("1000.0"),
+			Price:     decimal.MustParse("1.0"),
+			PrevPrice: decimal.MustParse("1.0"),
+			Value:     decimal.MustParse("1000.0"),
 		},
 	}
 
@@ -208,10 +226,10 @@ func TestDisplayWidth(t *testing.T) {
 
 			assets := []models.AssetValue{
 				{
-					Asset:    "ETH",
-					Balance:  1.0,
-					Price:    3000.0,
-					USDValue: 3000.0,
+					Asset:   "ETH",
+					Balance: decimal.MustParse("1.0"),
+					Price:   decimal.MustParse("3000.0"),
+					Value:   decimal.MustParse("3000.0"),
 				},
 			}
 
@@ -238,3 +256,458 @@ func TestDisplayWidth(t *testing.T) {
 func removeANSICodes(s string) string {
 	return strings.Join(strings.Split(s, "\033[")[0:1], "")
 }
+
+func TestFormatConnectionState(t *testing.T) {
+	display := ui.NewDisplayWithWriter(nil, 80)
+	tests := []struct {
+		name  string
+		state models.ConnectionState
+		want  string
+	}{
+		{"connecting", models.StateConnecting, "CONNECTING"},
+		{"connected", models.StateConnected, "LIVE"},
+		{"reconnecting", models.StateReconnecting, "RECONNECTING"},
+		{"stale", models.StateStale, "STALE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Contains(t, display.FormatConnectionState(tt.state), tt.want)
+		})
+	}
+}
+
+func TestRenderConnectionState(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+
+	display.SetConnectionState(models.StateReconnecting)
+	display.RenderPortfolio(nil)
+
+	assert.Contains(t, buf.String(), "RECONNECTING")
+}
+
+func TestRenderQuoteCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		decimals int
+		total    string
+	}{
+		{"euro symbol", "EUR", 2, "TOTAL VALUE: €1150.00"},
+		{"code without symbol", "CHF", 2, "TOTAL VALUE: 1150.00 CHF"},
+		{"crypto quote", "XBT", 5, "TOTAL VALUE: 1150.00000 XBT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			display := ui.NewDisplayWithWriter(&buf, 80)
+			display.SetQuoteCurrency(tt.currency, tt.decimals)
+
+			display.RenderPortfolio([]models.AssetValue{
+				{Asset: "ETH", Balance: decimal.MustParse("1"), Price: decimal.MustParse("1000"), Value: decimal.MustParse("1000")},
+				{Asset: tt.currency, Balance: decimal.MustParse("150"), Price: decimal.One, Value: decimal.MustParse("150")},
+				{Asset: "USD", Balance: decimal.MustParse("0"), Price: decimal.MustParse("0.9"), Value: decimal.Zero},
+			})
+			output := buf.String()
+
+			assert.Contains(t, output, "VALUE ("+tt.currency+")")
+			assert.Contains(t, output, tt.total)
+
+			// The quote currency row sits below the divider, after every
+			// other asset including USD.
+			assert.Less(t, strings.Index(output, "USD "), strings.LastIndex(output, "║ "+tt.currency))
+		})
+	}
+}
+
+func TestFooterWidthWithCurrencySymbol(t *testing.T) {
+	footerWidth := func(currency string) int {
+		var buf bytes.Buffer
+		display := ui.NewDisplayWithWriter(&buf, 80)
+		display.SetQuoteCurrency(currency, 2)
+		display.RenderPortfolio(nil)
+
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.Contains(line, "TOTAL VALUE") {
+				return utf8.RuneCountInString(removeAllANSICodes(line))
+			}
+		}
+		return 0
+	}
+
+	assert.Equal(t, footerWidth("USD"), footerWidth("EUR"))
+	assert.Equal(t, footerWidth("USD"), footerWidth("CHF"))
+}
+
+func removeAllANSICodes(s string) string {
+	for {
+		start := strings.Index(s, "\033[")
+		if start < 0 {
+			return s
+		}
+		end := strings.IndexByte(s[start:], 'm')
+		if end < 0 {
+			return s
+		}
+		s = s[:start] + s[start+end+1:]
+	}
+}
+
+func TestRenderCostBasis(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+
+	display.RenderPortfolio([]models.AssetValue{
+		{
+			Asset:         "ETH",
+			Balance:       decimal.MustParse("2"),
+			Price:         decimal.MustParse("3000"),
+			Value:         decimal.MustParse("6000"),
+			AvgCost:       decimal.MustParse("2500"),
+			CostBasis:     decimal.MustParse("5000"),
+			UnrealizedPnL: decimal.MustParse("1000"),
+			PnLPct:        decimal.MustParse("20"),
+		},
+		{
+			Asset:         "SOL",
+			Balance:       decimal.MustParse("10"),
+			Price:         decimal.MustParse("100"),
+			Value:         decimal.MustParse("1000"),
+			AvgCost:       decimal.MustParse("125"),
+			CostBasis:     decimal.MustParse("1250"),
+			UnrealizedPnL: decimal.MustParse("-250"),
+			PnLPct:        decimal.MustParse("-20"),
+		},
+		{Asset: "XBT", Balance: decimal.MustParse("0.01"), Price: decimal.MustParse("50000"), Value: decimal.MustParse("500")},
+	})
+	output := buf.String()
+
+	assert.Contains(t, output, "AVG COST")
+	assert.Contains(t, output, "P&L")
+	assert.Contains(t, output, "$2500.00")
+	assert.Contains(t, output, "\033[32m+1000.00 (+20.0%)")
+	assert.Contains(t, output, "\033[31m-250.00 (-20.0%)")
+}
+
+func TestRenderWithoutCostBasis(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+
+	display.RenderPortfolio([]models.AssetValue{
+		{Asset: "ETH", Balance: decimal.MustParse("1"), Price: decimal.MustParse("3000"), Value: decimal.MustParse("3000")},
+	})
+
+	assert.NotContains(t, buf.String(), "AVG COST")
+}
+
+func changeAssets() []models.AssetValue {
+	return []models.AssetValue{
+		{
+			Asset:            "ETH",
+			Balance:          decimal.MustParse("2"),
+			Price:            decimal.MustParse("3000"),
+			Value:            decimal.MustParse("6000"),
+			PriceDecimals:    2,
+			Open24h:          decimal.MustParse("2500"),
+			Change24hPct:     decimal.MustParse("20"),
+			Low24h:           decimal.MustParse("2400"),
+			High24h:          decimal.MustParse("3100"),
+			SessionOpen:      decimal.MustParse("3100"),
+			SessionChangePct: decimal.MustParse("-3.2258"),
+		},
+		{Asset: "SOL", Balance: decimal.MustParse("10"), Price: decimal.MustParse("100"), Value: decimal.MustParse("1000")},
+	}
+}
+
+func TestRenderChanges(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.RenderPortfolio(changeAssets())
+	output := buf.String()
+
+	assert.Contains(t, output, "24H")
+	assert.Contains(t, output, "SESSION")
+	assert.Contains(t, output, "\033[32m+20.00%")
+	assert.Contains(t, output, "\033[31m-3.23%")
+	assert.Contains(t, output, "2400.00-3100.00")
+
+	for _, line := range strings.Split(removeAllANSICodes(output), "\n") {
+		if strings.HasPrefix(line, "║ SOL") {
+			assert.Equal(t, 3, strings.Count(line, " - "), "missing figures should show a dash: %q", line)
+		}
+	}
+}
+
+func TestRenderChangesDropsRangeWhenNarrow(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+	display.RenderPortfolio(changeAssets())
+	output := buf.String()
+
+	assert.Contains(t, output, "+20.00%")
+	assert.NotContains(t, output, "LOW-HIGH")
+	assert.NotContains(t, output, "2400.00-3100.00")
+}
+
+func TestRenderEveryColumnFitsTheFrame(t *testing.T) {
+	asset := func(name, weight, target string) models.AssetValue {
+		a := changeAssets()[0]
+		a.Asset = name
+		a.AvgCost = decimal.MustParse("2500")
+		a.CostBasis = decimal.MustParse("5000")
+		a.UnrealizedPnL = decimal.MustParse("1000")
+		a.PnLPct = decimal.MustParse("20")
+		a.Weight = decimal.MustParse(weight)
+		a.Target = decimal.MustParse(target)
+		a.Drift = a.Weight.Sub(a.Target)
+		a.HasTarget = true
+		a.Sparkline = []decimal.Decimal{decimal.MustParse("2900"), decimal.MustParse("3000")}
+		return a
+	}
+	assets := []models.AssetValue{asset("ETH", "60", "50"), asset("SOL", "40", "50")}
+
+	for _, width := range []int{60, 80, 100, 120} {
+		var buf bytes.Buffer
+		display := ui.NewDisplayWithWriter(&buf, width)
+		display.RenderPortfolio(assets)
+		plain := removeAllANSICodes(buf.String())
+
+		frame := utf8.RuneCountInString(strings.SplitN(plain, "\n", 2)[0])
+		for _, line := range strings.Split(plain, "\n") {
+			assert.LessOrEqual(t, utf8.RuneCountInString(line), frame, "width %d: %q", width, line)
+		}
+		if width >= 100 {
+			assert.Contains(t, plain, "P&L")
+			assert.Contains(t, plain, "TARGET")
+			assert.NotContains(t, plain, "SESSION")
+		}
+	}
+}
+
+func TestRenderWithoutChanges(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.RenderPortfolio([]models.AssetValue{
+		{Asset: "ETH", Balance: decimal.MustParse("1"), Price: decimal.MustParse("3000"), Value: decimal.MustParse("3000")},
+	})
+
+	assert.NotContains(t, buf.String(), "SESSION")
+}
+
+func TestRenderAllocation(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.SetDriftTolerance(decimal.MustParse("5"))
+
+	display.RenderPortfolio([]models.AssetValue{
+		{
+			Asset: "ETH", Balance: decimal.MustParse("2"), Price: decimal.MustParse("3000"), Value: decimal.MustParse("6000"),
+			Weight: decimal.MustParse("60"), Target: decimal.MustParse("50"), Drift: decimal.MustParse("10"), HasTarget: true,
+		},
+		{
+			Asset: "SOL", Balance: decimal.MustParse("30"), Price: decimal.MustParse("100"), Value: decimal.MustParse("3000"),
+			Weight: decimal.MustParse("30"), Target: decimal.MustParse("33"), Drift: decimal.MustParse("-3"), HasTarget: true,
+		},
+		{
+			Asset: "USD", Balance: decimal.MustParse("1000"), Price: decimal.One, Value: decimal.MustParse("1000"),
+			Weight: decimal.MustParse("10"),
+		},
+	})
+	output := buf.String()
+
+	assert.Contains(t, output, "ALLOC")
+	assert.Contains(t, output, "TARGET")
+	assert.Contains(t, output, "60.0%")
+	assert.Contains(t, output, "\033[33m50.0% (+10.0)")
+	assert.Contains(t, output, "\033[0m33.0% (-3.0)")
+
+	plain := removeAllANSICodes(output)
+	assert.Contains(t, plain, "1000.00      10.0%   -")
+}
+
+func TestRenderAllocationWithoutTargets(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+	display.RenderPortfolio([]models.AssetValue{
+		{Asset: "ETH", Balance: decimal.MustParse("1"), Price: decimal.MustParse("3000"), Value: decimal.MustParse("3000"), Weight: decimal.MustParse("100")},
+	})
+
+	assert.Contains(t, buf.String(), "100.0%")
+	assert.NotContains(t, buf.String(), "TARGET")
+}
+
+func TestRenderOrders(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+	display.SetOrders([]models.OrderView{
+		{
+			Pair: "ETH/USD", Side: "sell", OrderType: "limit", Price: decimal.MustParse("3200"),
+			Volume: decimal.MustParse("0.5"), FilledPct: decimal.MustParse("50"), Last: decimal.MustParse("3000"),
+			DistancePct: decimal.MustParse("6.6667"), PriceDecimals: 2, LotDecimals: 8,
+		},
+		{
+			Pair: "XBT/USD", Side: "buy", OrderType: "limit", Price: decimal.MustParse("50000"),
+			Volume: decimal.MustParse("0.001"), PriceDecimals: 1, LotDecimals: 8,
+		},
+	}, []models.OrderView{
+		{Pair: "ETH/USD", Side: "sell", OrderType: "limit", Price: decimal.MustParse("2950"), Volume: decimal.MustParse("0.1"), FilledPct: decimal.MustParse("100"), LotDecimals: 8},
+	})
+
+	display.RenderPortfolio([]models.AssetValue{
+		{Asset: "ETH", Balance: decimal.MustParse("2"), Price: decimal.MustParse("3000"), Value: decimal.MustParse("6000"), BalanceDecimals: 5, Reserved: decimal.MustParse("0.25")},
+		{Asset: "USD", Balance: decimal.MustParse("100"), Price: decimal.One, Value: decimal.MustParse("100"), BalanceDecimals: 2, Reserved: decimal.MustParse("50")},
+	})
+	output := buf.String()
+	plain := removeAllANSICodes(output)
+
+	assert.Contains(t, plain, "OPEN ORDERS")
+	assert.Contains(t, plain, "RECENT FILLS")
+	assert.Contains(t, plain, "sell ETH/USD    limit  3200.00      0.50000000   50.0%   +6.67%")
+	assert.Contains(t, plain, "buy  XBT/USD    limit  50000.00     0.00100000   0.0%    -")
+	assert.Contains(t, plain, "Reserved: 0.25000 ETH, 50.00 USD")
+	assert.Contains(t, plain, "2950.00      0.10000000   100.0%")
+	assert.Contains(t, output, "\033[31msell")
+	assert.Contains(t, output, "\033[32mbuy ")
+}
+
+func TestRenderOrdersDropsTypeWhenNarrow(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 60)
+	display.SetOrders([]models.OrderView{
+		{Pair: "ETH/USD", Side: "sell", OrderType: "limit", Price: decimal.MustParse("3200"), Volume: decimal.MustParse("0.5"), LotDecimals: 8},
+	}, nil)
+	display.RenderPortfolio(nil)
+
+	plain := removeAllANSICodes(buf.String())
+	assert.Contains(t, plain, "OPEN ORDERS")
+	assert.NotContains(t, plain, "TYPE")
+	assert.NotContains(t, plain, "RECENT FILLS")
+}
+
+func TestRenderWithoutOrders(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+	display.RenderPortfolio([]models.AssetValue{
+		{Asset: "ETH", Balance: decimal.MustParse("1"), Price: decimal.MustParse("3000"), Value: decimal.MustParse("3000")},
+	})
+
+	assert.NotContains(t, buf.String(), "OPEN ORDERS")
+}
+
+func TestRenderAlert(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 80)
+	display.SetAlert("12:00:00 BTC above 70000: 70100")
+	display.RenderPortfolio(nil)
+	assert.Contains(t, buf.String(), "12:00:00 BTC above 70000: 70100")
+
+	buf.Reset()
+	display.SetAlert("")
+	display.RenderPortfolio(nil)
+	assert.NotContains(t, buf.String(), "BTC above")
+}
+
+func TestRenderPerformance(t *testing.T) {
+	summary := models.PerformanceSummary{
+		Period:      "30d",
+		TWR:         decimal.MustParse("5.2134"),
+		MWR:         decimal.MustParse("-1.5"),
+		HasMWR:      true,
+		MaxDrawdown: decimal.MustParse("3.1"),
+		Volatility:  decimal.MustParse("42.04"),
+		Sharpe:      decimal.MustParse("1.12"),
+		HasRisk:     true,
+	}
+
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.RenderPortfolio(nil)
+	assert.NotContains(t, buf.String(), "TWR")
+
+	buf.Reset()
+	display.SetPerformance(summary)
+	display.RenderPortfolio(nil)
+	assert.Contains(t, buf.String(), "30D  TWR +5.21%  MWR -1.50%  MAX DD -3.10%  VOL 42.0%  SHARPE 1.12")
+
+	// Narrow frames drop figures from the end.
+	buf.Reset()
+	display = ui.NewDisplayWithWriter(&buf, 60)
+	display.SetPerformance(summary)
+	display.RenderPortfolio(nil)
+	assert.Contains(t, buf.String(), "30D  TWR +5.21%  MWR -1.50%  MAX DD -3.10%  VOL 42.0%")
+	assert.NotContains(t, buf.String(), "SHARPE")
+}
+
+func sparkAssets(prices ...string) []models.AssetValue {
+	series := make([]decimal.Decimal, len(prices))
+	for i, p := range prices {
+		series[i] = decimal.MustParse(p)
+	}
+	return []models.AssetValue{
+		{Asset: "ETH", Balance: decimal.MustParse("1"), Price: series[len(series)-1], Value: series[len(series)-1], Sparkline: series},
+		{Asset: "SOL", Balance: decimal.MustParse("1"), Price: decimal.MustParse("100"), Value: decimal.MustParse("100")},
+	}
+}
+
+func TestRenderSparkline(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.RenderPortfolio(sparkAssets("0", "0", "10", "20", "30", "40", "50", "45", "40", "50"))
+	output := buf.String()
+
+	assert.Contains(t, output, "TREND")
+	// Unpriced buckets are blank, the rest scaled from lowest to highest.
+	assert.Contains(t, output, "\033[32m  ▁▂▃▅▇▇▅▇")
+	for _, line := range strings.Split(removeAllANSICodes(output), "\n") {
+		if strings.HasPrefix(line, "║ SOL") {
+			assert.True(t, strings.HasSuffix(line, "-          ║"), "no history should show a dash: %q", line)
+		}
+	}
+
+	buf.Reset()
+	display.RenderPortfolio(sparkAssets("50", "40", "40"))
+	assert.Contains(t, buf.String(), "\033[31m▇▁▁")
+
+	buf.Reset()
+	display.RenderPortfolio(sparkAssets("40", "40"))
+	assert.Contains(t, buf.String(), "▃▃")
+}
+
+func TestRenderSparklineScalesToWidth(t *testing.T) {
+	prices := make([]string, 60)
+	for i := range prices {
+		prices[i] = strconv.Itoa(100 + i)
+	}
+
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.RenderPortfolio(sparkAssets(prices...))
+	wide := sparkCells(buf.String())
+
+	buf.Reset()
+	display = ui.NewDisplayWithWriter(&buf, 70)
+	display.RenderPortfolio(sparkAssets(prices...))
+	narrow := sparkCells(buf.String())
+
+	assert.Greater(t, wide, narrow)
+	assert.GreaterOrEqual(t, narrow, 8)
+	assert.LessOrEqual(t, wide, 60)
+
+	// Too narrow for even the smallest trend.
+	buf.Reset()
+	display = ui.NewDisplayWithWriter(&buf, 60)
+	display.RenderPortfolio(append(sparkAssets(prices...), changeAssets()...))
+	assert.NotContains(t, buf.String(), "TREND")
+}
+
+func sparkCells(output string) int {
+	for _, line := range strings.Split(removeAllANSICodes(output), "\n") {
+		if strings.HasPrefix(line, "║ ETH") {
+			return strings.Count(line, "▁") + strings.Count(line, "▂") + strings.Count(line, "▃") +
+				strings.Count(line, "▅") + strings.Count(line, "▇")
+		}
+	}
+	return 0
+}
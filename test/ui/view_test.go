@@ -0,0 +1,184 @@
+package ui_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/umit144/kraken-portfolio/internal/models"
+	"github.com/umit144/kraken-portfolio/internal/ui"
+	"github.com/umit144/kraken-portfolio/pkg/decimal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys := ui.ParseKeys([]byte("\x1b[A\x1b[Bq\r\x03\x1bOC\x1b[1;5D\x1b[15~\x1b\ts"))
+	assert.Equal(t, []ui.Key{ui.KeyUp, ui.KeyDown, "q", ui.KeyEnter, ui.KeyCtrlC, ui.KeyRight, ui.KeyEsc, ui.KeyTab, "s"}, keys)
+
+	// A sequence cut off by the end of the read is dropped.
+	assert.Equal(t, []ui.Key{"j"}, ui.ParseKeys([]byte("j\x1b[1;")))
+}
+
+func viewAssets() []models.AssetValue {
+	return []models.AssetValue{
+		{
+			Asset: "ETH", Pair: "ETH/USD", Balance: decimal.MustParse("2"), BalanceDecimals: 4,
+			Price: decimal.MustParse("3000"), Value: decimal.MustParse("6000"), PriceDecimals: 2,
+			Open24h: decimal.MustParse("2500"), Change24hPct: decimal.MustParse("20"),
+			Low24h: decimal.MustParse("2400"), High24h: decimal.MustParse("3100"),
+			Volume24h: decimal.MustParse("1234.5"),
+		},
+		{
+			Asset: "SOL", Pair: "SOL/USD", Balance: decimal.MustParse("10"),
+			Price: decimal.MustParse("100"), Value: decimal.MustParse("1000"),
+			Open24h: decimal.MustParse("80"), Change24hPct: decimal.MustParse("25"),
+		},
+		{Asset: "ADA", Balance: decimal.MustParse("100"), Price: decimal.MustParse("0.5"), Value: decimal.MustParse("50")},
+	}
+}
+
+func interactiveDisplay(buf *bytes.Buffer) *ui.Display {
+	display := ui.NewDisplayWithWriter(buf, 100)
+	display.SetInteractive(true)
+	display.RenderPortfolio(viewAssets())
+	return display
+}
+
+// rows lists the asset column of the last frame's holdings in order, with
+// the selected one marked.
+func rows(output string) []string {
+	frames := strings.Split(output, "\033[H\033[2J")
+	var out []string
+	for _, line := range strings.Split(removeAllANSICodes(frames[len(frames)-1]), "\n") {
+		for _, asset := range []string{"ETH", "SOL", "ADA"} {
+			if strings.HasPrefix(line, "║ "+asset) {
+				out = append(out, asset)
+			}
+			if strings.HasPrefix(line, "║▸"+asset) {
+				out = append(out, ">"+asset)
+			}
+		}
+	}
+	return out
+}
+
+func TestHandleKeySorts(t *testing.T) {
+	var buf bytes.Buffer
+	display := interactiveDisplay(&buf)
+	assert.Equal(t, []string{">ETH", "SOL", "ADA"}, rows(buf.String()))
+	assert.Contains(t, buf.String(), "VALUE (USD)▼")
+
+	buf.Reset()
+	display.HandleKey(ui.KeyRight)
+	assert.Equal(t, []string{"ADA", ">ETH", "SOL"}, rows(buf.String()))
+	assert.Contains(t, buf.String(), "ASSET▲")
+
+	buf.Reset()
+	display.HandleKey("r")
+	assert.Equal(t, []string{"SOL", ">ETH", "ADA"}, rows(buf.String()))
+	assert.Contains(t, buf.String(), "ASSET▼")
+
+	// The 24 hour change, after price.
+	buf.Reset()
+	display.HandleKey(ui.KeyRight)
+	display.HandleKey(ui.KeyRight)
+	assert.Equal(t, []string{"SOL", ">ETH", "ADA"}, rows(buf.String()))
+	assert.Contains(t, buf.String(), "24H▼")
+
+	buf.Reset()
+	display.HandleKey(ui.KeyLeft)
+	assert.Contains(t, buf.String(), "PRICE▼")
+	assert.Equal(t, []string{">ETH", "SOL", "ADA"}, rows(buf.String()))
+}
+
+func TestHandleKeySelectsAndOpensDetail(t *testing.T) {
+	var buf bytes.Buffer
+	display := interactiveDisplay(&buf)
+	assert.Empty(t, display.DetailPair())
+
+	buf.Reset()
+	display.HandleKey(ui.KeyDown)
+	display.HandleKey(ui.KeyDown)
+	display.HandleKey(ui.KeyDown)
+	assert.Equal(t, []string{"ETH", "SOL", ">ADA"}, rows(buf.String()))
+
+	// ADA has no pair of its own, so there is no book to fetch.
+	display.HandleKey(ui.KeyEnter)
+	assert.Empty(t, display.DetailPair())
+	buf.Reset()
+	display.HandleKey("k")
+	display.HandleKey("k")
+	assert.Equal(t, "ETH/USD", display.DetailPair())
+
+	output := removeAllANSICodes(buf.String())
+	assert.Contains(t, output, "ETH  ETH/USD")
+	assert.Contains(t, output, "Last       3000.00        24H        +20.00%")
+	assert.Contains(t, output, "24H low    2400.00        24H high   3100.00")
+	assert.Contains(t, output, "Volume     1234.50 ETH    Balance    2.0000")
+	assert.Contains(t, output, "Loading order book...")
+
+	buf.Reset()
+	display.SetOrderBook(models.OrderBook{
+		Pair: "ETH/USD",
+		Bids: []models.BookLevel{
+			{Price: decimal.MustParse("2999.5"), Volume: decimal.MustParse("1.5")},
+			{Price: decimal.MustParse("2999"), Volume: decimal.MustParse("3")},
+		},
+		Asks: []models.BookLevel{{Price: decimal.MustParse("3000.5"), Volume: decimal.MustParse("0.25")}},
+	})
+	display.Refresh()
+	output = removeAllANSICodes(buf.String())
+	assert.Contains(t, output, "BID VOLUME   BID            ASK          ASK VOLUME")
+	assert.Contains(t, output, "1.5000       2999.50        3000.50      0.2500")
+	assert.Contains(t, output, "3.0000       2999.00")
+	assert.Contains(t, output, "Spread 1.00 (0.033%)")
+
+	buf.Reset()
+	display.HandleKey(ui.KeyEsc)
+	assert.NotContains(t, buf.String(), "BID VOLUME")
+	assert.Empty(t, display.DetailPair())
+}
+
+func TestHandleKeyTogglesPanels(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.SetInteractive(true)
+	display.SetOrders([]models.OrderView{{Side: "buy", Pair: "ETH/USD", Price: decimal.MustParse("2900")}}, nil)
+	display.SetPerformance(models.PerformanceSummary{Period: "30d"})
+	assets := viewAssets()
+	assets[0].Sparkline = []decimal.Decimal{decimal.MustParse("1"), decimal.MustParse("2")}
+	display.RenderPortfolio(assets)
+
+	output := buf.String()
+	require.Contains(t, output, "OPEN ORDERS")
+	require.Contains(t, output, "30D  TWR")
+	require.Contains(t, output, "TREND")
+
+	for key, panel := range map[ui.Key]string{"o": "OPEN ORDERS", "p": "30D  TWR", "t": "TREND"} {
+		buf.Reset()
+		display.HandleKey(key)
+		assert.NotContains(t, buf.String(), panel)
+		buf.Reset()
+		display.HandleKey(key)
+		assert.Contains(t, buf.String(), panel)
+	}
+}
+
+func TestHandleKeyQuits(t *testing.T) {
+	var buf bytes.Buffer
+	display := interactiveDisplay(&buf)
+	assert.False(t, display.HandleKey("x"))
+	assert.True(t, display.HandleKey("q"))
+	assert.True(t, display.HandleKey(ui.KeyCtrlC))
+}
+
+func TestStaticDisplayHasNoSelection(t *testing.T) {
+	var buf bytes.Buffer
+	display := ui.NewDisplayWithWriter(&buf, 100)
+	display.RenderPortfolio(viewAssets())
+	assert.Equal(t, []string{"ETH", "SOL", "ADA"}, rows(buf.String()))
+	assert.NotContains(t, buf.String(), "▼")
+	assert.Contains(t, buf.String(), "Press Ctrl+C to exit")
+}